@@ -19,6 +19,7 @@ func wireApp() *bootstrap.HTTPServer {
 		config.LoadConfig,
 		config.ProvideDefaultUserConfig,
 		config.ProviderMiddlewareConfig,
+		config.ProvideBrowserAgentConfig,
 		bootstrap.InitSet,
 		// 这里解释一下没有serviceProvider的原因:
 		// 	service总是只被对应的controller使用，但是repo可能被多个service使用
@@ -118,12 +118,19 @@ func wireApp() *bootstrap.HTTPServer {
 		AIProviderDB:    aiProviderDB,
 		AIProviderCache: aiProviderCache,
 	}
+	systemSettingDB := db.NewSystemSettingDB(gormDB)
+	systemSettingRepo := &repository.SystemSettingRepo{
+		SystemSettingDB: systemSettingDB,
+	}
 	aiModelClient := bootstrap.InitAIModelClient()
-	browserAgentService := service.NewBrowserAgentService(browserAgentRepo, aiModelRepo, aiProviderRepo, aiModelClient, gormTransactionManager)
+	browserAgent := config.ProvideBrowserAgentConfig()
+	hub := bootstrap.InitWebSocketHub(redisWrapper)
+	browserAgentService := service.NewBrowserAgentService(browserAgentRepo, aiModelRepo, aiProviderRepo, roleRepo, systemSettingRepo, aiModelClient, gormTransactionManager, ossClient, browserAgent, hub)
 	browserAgentDashboardService := &service.BrowserAgentDashboardService{
-		BrowserAgentRepo: browserAgentRepo,
+		BrowserAgentRepo:   browserAgentRepo,
+		RoleRepo:           roleRepo,
+		BrowserAgentConfig: browserAgent,
 	}
-	hub := bootstrap.InitWebSocketHub()
 	browserAgentController := controller.NewBrowserAgentController(engine, middlewares, browserAgentService, browserAgentDashboardService, hub)
 	knowledgeBaseDB := db.NewKnowledgeBaseDB(gormDB)
 	fileChunkDB := db.NewFileChunkDB(gormDB)
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"Art-Design-Backend/pkg/ai"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+// mockResponse 模拟模型的一次回复：Tool 非空时为工具调用，否则直接返回 Content 文本
+//
+// 请求未携带工具定义（模型被标记为不支持工具调用）时，工具调用改为以 JSON 文本返回
+type mockResponse struct {
+	Tool      string         `json:"tool,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Content   string         `json:"content,omitempty"`
+}
+
+// fakeLLM 使用 httptest 启动的 OpenAI 兼容接口，按顺序返回当前场景的脚本化回复
+type fakeLLM struct {
+	mu        sync.Mutex
+	responses []mockResponse
+	server    *httptest.Server
+}
+
+func newFakeLLM() *fakeLLM {
+	f := &fakeLLM{}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// reset 切换到下一个场景的脚本
+func (f *fakeLLM) reset(responses []mockResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = responses
+}
+
+func (f *fakeLLM) URL() string {
+	return f.server.URL
+}
+
+func (f *fakeLLM) Close() {
+	f.server.Close()
+}
+
+func (f *fakeLLM) next() (mockResponse, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.responses) == 0 {
+		return mockResponse{}, false
+	}
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+	return resp, true
+}
+
+func (f *fakeLLM) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 只解析用到的字段，文本与多模态请求均可解析
+	var req struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+		Tools  []any  `json:"tools"`
+	}
+	if err = sonic.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mock, ok := f.next()
+	if !ok {
+		http.Error(w, "模拟回复已用完", http.StatusInternalServerError)
+		return
+	}
+
+	msg := ai.ChatCompletionMessage{Role: "assistant", Content: mock.Content}
+	if mock.Tool != "" {
+		args, _ := sonic.MarshalString(mock.Arguments)
+		if len(req.Tools) > 0 {
+			msg.ToolCalls = []ai.ToolCall{{
+				ID:       "call_mock",
+				Type:     "function",
+				Function: ai.ToolCallFunction{Name: mock.Tool, Arguments: args},
+			}}
+		} else {
+			fields := map[string]any{"action": mock.Tool}
+			for k, v := range mock.Arguments {
+				fields[k] = v
+			}
+			msg.Content, _ = sonic.MarshalString(fields)
+		}
+	}
+
+	resp := ai.ChatCompletionResponse{
+		ID:      "mock",
+		Object:  "chat.completion",
+		Model:   req.Model,
+		Choices: []ai.ChatCompletionChoice{{Message: msg, FinishReason: "stop"}},
+	}
+
+	if req.Stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunk := ai.ChatCompletionStreamResponse{
+			ID:    resp.ID,
+			Model: resp.Model,
+			Choices: []ai.StreamChoice{{
+				Delta: ai.DeltaContent{Role: "assistant", Content: msg.Content, ToolCalls: toolCallDeltas(msg.ToolCalls)},
+			}},
+		}
+		data, _ := sonic.MarshalString(chunk)
+		_, _ = fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", data)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	data, _ := sonic.Marshal(resp)
+	_, _ = w.Write(data)
+}
+
+func toolCallDeltas(calls []ai.ToolCall) []ai.ToolCallDelta {
+	deltas := make([]ai.ToolCallDelta, len(calls))
+	for i, call := range calls {
+		deltas[i] = ai.ToolCallDelta{Index: i, ID: call.ID, Type: call.Type, Function: call.Function}
+	}
+	return deltas
+}
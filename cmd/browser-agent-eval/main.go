@@ -0,0 +1,146 @@
+// Package main 浏览器智能体离线评估工具
+//
+// 回放录制的页面状态与脚本化的页面跳转，统计每个场景的成功率、步数与无效操作率，
+// 用于对比提示词（prompt.BrowserSystemPrompt）或元素筛选调整前后的效果。
+//
+// 默认使用 httptest 启动的模拟模型，按场景文件中的 mock_responses 依次回复；
+// 指定 -base-url 时改为调用真实的 OpenAI 兼容接口：
+//
+//	go run ./cmd/browser-agent-eval -scenarios cmd/browser-agent-eval/scenarios
+//	go run ./cmd/browser-agent-eval -base-url https://api.example.com/v1 -api-key sk-xxx -model qwen-plus
+package main
+
+import (
+	"Art-Design-Backend/config"
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/service"
+	"Art-Design-Backend/pkg/ai"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+)
+
+// scenarioFile 场景文件，mock_responses 仅在使用模拟模型时生效
+type scenarioFile struct {
+	service.EvalScenario
+	MockResponses []mockResponse `json:"mock_responses"`
+}
+
+func main() {
+	var (
+		scenarioDir = flag.String("scenarios", "cmd/browser-agent-eval/scenarios", "场景文件目录（*.json）")
+		baseURL     = flag.String("base-url", "", "真实模型接口基础地址，为空时使用模拟模型")
+		apiKey      = flag.String("api-key", "", "真实模型接口密钥")
+		modelName   = flag.String("model", "mock-model", "模型名称")
+		apiPath     = flag.String("api-path", "/chat/completions", "模型接口路径")
+		maxContext  = flag.Int("max-context", 32000, "模型最大上下文长度（token），用于计算元素列表预算")
+		jsonOutput  = flag.Bool("json", false, "以 JSON 输出评估结果")
+		verbose     = flag.Bool("v", false, "输出智能体日志")
+	)
+	flag.Parse()
+
+	if *verbose {
+		logger, _ := zap.NewDevelopment()
+		zap.ReplaceGlobals(logger)
+	}
+
+	scenarios, err := loadScenarios(*scenarioDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var fake *fakeLLM
+	if *baseURL == "" {
+		fake = newFakeLLM()
+		defer fake.Close()
+		*baseURL = fake.URL()
+	}
+
+	evaluator := service.NewBrowserAgentEvaluator(
+		ai.NewAIModelClient(&http.Client{Timeout: 2 * time.Minute}),
+		config.DefaultBrowserAgent(),
+		&entity.AIModel{Model: *modelName, APIPath: *apiPath, MaxContextTokens: *maxContext},
+		&entity.AIProvider{BaseURL: *baseURL, APIKey: *apiKey},
+	)
+
+	results := make([]*service.EvalResult, 0, len(scenarios))
+	for _, sc := range scenarios {
+		if fake != nil {
+			fake.reset(sc.MockResponses)
+		}
+		results = append(results, evaluator.Run(context.Background(), &sc.EvalScenario))
+	}
+
+	if *jsonOutput {
+		data, _ := sonic.ConfigStd.MarshalIndent(results, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	printResults(results)
+}
+
+// loadScenarios 读取目录下的全部场景文件，按文件名排序
+func loadScenarios(dir string) ([]*scenarioFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("目录 %s 中没有场景文件", dir)
+	}
+	sort.Strings(paths)
+
+	scenarios := make([]*scenarioFile, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var sc scenarioFile
+		if err = sonic.Unmarshal(data, &sc); err != nil {
+			return nil, fmt.Errorf("解析场景文件 %s 失败: %w", path, err)
+		}
+		if sc.Name == "" {
+			sc.Name = filepath.Base(path)
+		}
+		scenarios = append(scenarios, &sc)
+	}
+	return scenarios, nil
+}
+
+func printResults(results []*service.EvalResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "场景\t结果\t步数\t无效操作率\t结束页面\t说明")
+
+	var succeeded, steps, invalid int
+	for _, r := range results {
+		status := "失败"
+		if r.Success {
+			status = "成功"
+			succeeded++
+		}
+		steps += r.Steps
+		invalid += r.InvalidActions
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%.1f%%\t%s\t%s\n",
+			r.Name, status, r.Steps, r.InvalidRate()*100, r.FinalPage, r.Error)
+	}
+	_ = w.Flush()
+
+	invalidRate := 0.0
+	if steps > 0 {
+		invalidRate = float64(invalid) / float64(steps) * 100
+	}
+	fmt.Printf("\n成功率 %d/%d（%.1f%%），平均步数 %.1f，无效操作率 %.1f%%\n",
+		succeeded, len(results), float64(succeeded)/float64(len(results))*100,
+		float64(steps)/float64(len(results)), invalidRate)
+}
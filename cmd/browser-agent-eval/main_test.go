@@ -0,0 +1,82 @@
+package main
+
+import (
+	"Art-Design-Backend/config"
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/service"
+	"Art-Design-Backend/pkg/ai"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	// minSuccessRate 内置场景使用模拟模型时的最低成功率
+	minSuccessRate = 1.0
+	// maxInvalidRate 内置场景使用模拟模型时的最高无效操作率
+	maxInvalidRate = 0.25
+)
+
+// TestScenarios 使用模拟模型回放内置场景，校验每个场景的结果与整体通过阈值
+func TestScenarios(t *testing.T) {
+	scenarios, err := loadScenarios("scenarios")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := newFakeLLM()
+	defer fake.Close()
+
+	evaluator := service.NewBrowserAgentEvaluator(
+		ai.NewAIModelClient(&http.Client{Timeout: 10 * time.Second}),
+		config.DefaultBrowserAgent(),
+		&entity.AIModel{Model: "mock-model", APIPath: "/chat/completions", MaxContextTokens: 32000},
+		&entity.AIProvider{BaseURL: fake.URL()},
+	)
+
+	expected := map[string]struct {
+		steps          int
+		invalidActions int
+		finalPage      string
+	}{
+		"登录表单（含一次无效操作）": {steps: 5, invalidActions: 1, finalPage: "dashboard"},
+		"商城搜索": {steps: 3, invalidActions: 0, finalPage: "results"},
+	}
+
+	var succeeded, steps, invalid int
+	for _, sc := range scenarios {
+		t.Run(sc.Name, func(t *testing.T) {
+			fake.reset(sc.MockResponses)
+			r := evaluator.Run(context.Background(), &sc.EvalScenario)
+
+			if !r.Success {
+				t.Errorf("场景未完成: %s", r.Error)
+			}
+			if sc.MaxSteps > 0 && r.Steps > sc.MaxSteps {
+				t.Errorf("步数 %d 超过上限 %d", r.Steps, sc.MaxSteps)
+			}
+			if want, ok := expected[sc.Name]; ok {
+				if r.Steps != want.steps || r.InvalidActions != want.invalidActions || r.FinalPage != want.finalPage {
+					t.Errorf("结果为 steps=%d invalid=%d page=%s，期望 steps=%d invalid=%d page=%s",
+						r.Steps, r.InvalidActions, r.FinalPage, want.steps, want.invalidActions, want.finalPage)
+				}
+			}
+
+			if r.Success {
+				succeeded++
+			}
+			steps += r.Steps
+			invalid += r.InvalidActions
+		})
+	}
+
+	if rate := float64(succeeded) / float64(len(scenarios)); rate < minSuccessRate {
+		t.Errorf("成功率 %.2f 低于阈值 %.2f", rate, minSuccessRate)
+	}
+	if steps > 0 {
+		if rate := float64(invalid) / float64(steps); rate > maxInvalidRate {
+			t.Errorf("无效操作率 %.2f 超过阈值 %.2f", rate, maxInvalidRate)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+// 浏览器智能体默认限制，配置缺省时使用
+const (
+	defaultMaxStepsPerMessage = 50
+	defaultRepeatActionLimit  = 3
+	defaultStalledPageLimit   = 4
+	defaultScheduleRunExpiry  = "6h"
+
+	defaultMaxRetriesPerStep    = 2
+	defaultMaxRetriesPerMessage = 5
+
+	defaultTraceRetentionDays = 7
+	defaultTraceMaxFieldBytes = 64 * 1024
+)
+
+// defaultConfirmKeywords 元素文本命中这些关键词时，点击前需要用户确认
+var defaultConfirmKeywords = []string{
+	"提交", "支付", "付款", "删除", "下单", "购买", "结算", "转账", "注销",
+	"submit", "pay", "delete", "remove", "buy", "order", "checkout", "purchase",
+}
+
+// defaultAdminRoleCodes 拥有这些角色的用户可访问所有用户的会话与任务
+var defaultAdminRoleCodes = []string{"R_SUPER"}
+
+type BrowserAgent struct {
+	MaxStepsPerMessage int `yaml:"max-steps-per-message" mapstructure:"max-steps-per-message"` // 单个任务最多允许生成的操作数
+	RepeatActionLimit  int `yaml:"repeat-action-limit" mapstructure:"repeat-action-limit"`     // 连续生成相同操作达到该次数视为陷入循环
+	StalledPageLimit   int `yaml:"stalled-page-limit" mapstructure:"stalled-page-limit"`       // 连续操作后页面指纹不变达到该次数视为停滞
+
+	MaxRetriesPerStep    int `yaml:"max-retries-per-step" mapstructure:"max-retries-per-step"`       // 同一步操作执行失败后最多重新规划的次数
+	MaxRetriesPerMessage int `yaml:"max-retries-per-message" mapstructure:"max-retries-per-message"` // 单个任务累计最多重新规划的次数
+
+	ConfirmKeywords       []string `yaml:"confirm-keywords" mapstructure:"confirm-keywords"`               // 点击文本包含这些关键词的元素前需要用户确认
+	ConfirmAllowedDomains []string `yaml:"confirm-allowed-domains" mapstructure:"confirm-allowed-domains"` // 跳转到列表以外的域名前需要用户确认，为空时不校验域名
+
+	SecretKey string `yaml:"secret-key" mapstructure:"secret-key"` // 用户凭据加密密钥，为空时不可使用凭据
+
+	ScheduleRunExpiry string `yaml:"schedule-run-expiry" mapstructure:"schedule-run-expiry"` // 客户端离线时定时任务的排队有效期，超时未下发则过期
+
+	DisableStreaming bool `yaml:"disable-streaming" mapstructure:"disable-streaming"` // 关闭流式调用模型，不再向客户端推送思考过程
+
+	AdminRoleCodes []string `yaml:"admin-role-codes" mapstructure:"admin-role-codes"` // 拥有这些角色编码的用户可访问其他用户的会话
+
+	DisableTrace       bool `yaml:"disable-trace" mapstructure:"disable-trace"`                 // 不保存决策轨迹（页面状态快照与模型输入输出）
+	TraceRetentionDays int  `yaml:"trace-retention-days" mapstructure:"trace-retention-days"`   // 决策轨迹保留天数，过期后由定时任务清理
+	TraceMaxFieldBytes int  `yaml:"trace-max-field-bytes" mapstructure:"trace-max-field-bytes"` // 轨迹中页面状态、提示词与模型响应各自的最大保存字节数，超出部分截断
+}
+
+// DefaultBrowserAgent 返回全部使用默认值的配置，供离线评估等不加载配置文件的场景使用
+func DefaultBrowserAgent() *BrowserAgent {
+	b := &BrowserAgent{}
+	b.applyDefaults()
+	return b
+}
+
+// applyDefaults 为未配置的字段填充默认值
+func (b *BrowserAgent) applyDefaults() {
+	if b.MaxStepsPerMessage <= 0 {
+		b.MaxStepsPerMessage = defaultMaxStepsPerMessage
+	}
+	if b.RepeatActionLimit <= 0 {
+		b.RepeatActionLimit = defaultRepeatActionLimit
+	}
+	if b.StalledPageLimit <= 0 {
+		b.StalledPageLimit = defaultStalledPageLimit
+	}
+	if b.MaxRetriesPerStep <= 0 {
+		b.MaxRetriesPerStep = defaultMaxRetriesPerStep
+	}
+	if b.MaxRetriesPerMessage <= 0 {
+		b.MaxRetriesPerMessage = defaultMaxRetriesPerMessage
+	}
+	if len(b.ConfirmKeywords) == 0 {
+		b.ConfirmKeywords = defaultConfirmKeywords
+	}
+	if b.TraceRetentionDays <= 0 {
+		b.TraceRetentionDays = defaultTraceRetentionDays
+	}
+	if b.TraceMaxFieldBytes <= 0 {
+		b.TraceMaxFieldBytes = defaultTraceMaxFieldBytes
+	}
+	if len(b.AdminRoleCodes) == 0 {
+		b.AdminRoleCodes = defaultAdminRoleCodes
+	}
+	if b.ScheduleRunExpiry == "" {
+		b.ScheduleRunExpiry = defaultScheduleRunExpiry
+	}
+}
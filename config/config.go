@@ -24,6 +24,7 @@ type Config struct {
 	Slicer       Slicer            `yaml:"slicer" mapstructure:"slicer"`
 	DefaultUser  DefaultUserConfig `yaml:"default_user" mapstructure:"default_user"`
 	Middleware   Middleware        `yaml:"middleware" mapstructure:"middleware"`
+	BrowserAgent BrowserAgent      `yaml:"browser_agent" mapstructure:"browser_agent"`
 }
 
 var globalConfig *Config
@@ -36,6 +37,11 @@ func ProviderMiddlewareConfig() *Middleware {
 	return &globalConfig.Middleware
 }
 
+func ProvideBrowserAgentConfig() *BrowserAgent {
+	globalConfig.BrowserAgent.applyDefaults()
+	return &globalConfig.BrowserAgent
+}
+
 func setGlobalConfig(cfg *Config) {
 	globalConfig = cfg
 }
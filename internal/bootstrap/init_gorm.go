@@ -46,6 +46,13 @@ func AutoMigrate(db *gorm.DB) {
 	_ = db.AutoMigrate(&entity.BrowserAgentConversation{})
 	_ = db.AutoMigrate(&entity.BrowserAgentMessage{})
 	_ = db.AutoMigrate(&entity.BrowserAgentAction{})
+	_ = db.AutoMigrate(&entity.BrowserAgentURLPolicy{})
+	_ = db.AutoMigrate(&entity.BrowserAgentSecret{})
+	_ = db.AutoMigrate(&entity.BrowserAgentWorkflow{})
+	_ = db.AutoMigrate(&entity.BrowserAgentSchedule{})
+	_ = db.AutoMigrate(&entity.BrowserAgentActionTrace{})
+	// 11. 系统配置
+	_ = db.AutoMigrate(&entity.SystemSetting{})
 }
 
 // snowflakeIDFieldsMap 存储类型和对应的ID字段名（缓存，提高效率）
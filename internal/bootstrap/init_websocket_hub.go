@@ -1,11 +1,13 @@
 package bootstrap
 
 import (
+	"Art-Design-Backend/pkg/redisx"
 	"Art-Design-Backend/pkg/ws"
 )
 
-func InitWebSocketHub() *ws.Hub {
-	hub := ws.NewHub()
+// InitWebSocketHub 初始化基于 Redis 共享在线信息的 WebSocket Hub，支持多副本部署
+func InitWebSocketHub(redis *redisx.RedisWrapper) *ws.Hub {
+	hub := ws.NewClusterHub(redis)
 	go hub.Run()
 	return hub
 }
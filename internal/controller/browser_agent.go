@@ -9,8 +9,10 @@ import (
 	"Art-Design-Backend/pkg/result"
 	"Art-Design-Backend/pkg/ws"
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -50,8 +52,31 @@ func NewBrowserAgentController(
 		agent.GET("/conversation/list", browserAgentCtrl.ListConversations)
 		agent.POST("/conversation/rename", browserAgentCtrl.RenameConversation)
 		agent.DELETE("/conversation/delete", browserAgentCtrl.DeleteConversation)
+		agent.POST("/conversation/model", browserAgentCtrl.UpdateConversationModel)
+		agent.GET("/setting/default-model", browserAgentCtrl.GetDefaultModel)
+		agent.POST("/setting/default-model", browserAgentCtrl.SetDefaultModel)
+		agent.GET("/policy/list", browserAgentCtrl.ListURLPolicies)
+		agent.POST("/policy/save", browserAgentCtrl.SaveURLPolicy)
+		agent.DELETE("/policy/delete", browserAgentCtrl.DeleteURLPolicy)
+		agent.GET("/secret/list", browserAgentCtrl.ListSecrets)
+		agent.POST("/secret/save", browserAgentCtrl.SaveSecret)
+		agent.DELETE("/secret/delete", browserAgentCtrl.DeleteSecret)
+		agent.POST("/workflow/create", browserAgentCtrl.CreateWorkflow)
+		agent.GET("/workflow/list", browserAgentCtrl.ListWorkflows)
+		agent.DELETE("/workflow/delete", browserAgentCtrl.DeleteWorkflow)
+		agent.POST("/workflow/run", browserAgentCtrl.RunWorkflow)
+		agent.POST("/schedule/save", browserAgentCtrl.SaveSchedule)
+		agent.GET("/schedule/list", browserAgentCtrl.ListSchedules)
+		agent.DELETE("/schedule/delete", browserAgentCtrl.DeleteSchedule)
+		agent.GET("/clients", browserAgentCtrl.ListClients)
 		agent.GET("/messages", browserAgentCtrl.ListMessages)
 		agent.POST("/message/create", browserAgentCtrl.CreateMessage)
+		agent.POST("/message/cancel", browserAgentCtrl.CancelMessage)
+		agent.POST("/message/pause", browserAgentCtrl.PauseMessage)
+		agent.POST("/message/resume", browserAgentCtrl.ResumeMessage)
+		agent.GET("/message/result/download", browserAgentCtrl.DownloadExtractResult)
+		agent.GET("/message/trace", browserAgentCtrl.GetMessageTrace)
+		agent.GET("/message/trace/export", browserAgentCtrl.ExportMessageTrace)
 		agent.GET("/actions", browserAgentCtrl.ListActions)
 	}
 
@@ -61,7 +86,7 @@ func NewBrowserAgentController(
 			Group("/browser-agent").
 			Group("/dashboard").
 			Group("/admin")
-		adminDashboard.Use(mws.AuthMiddleware())
+		adminDashboard.Use(mws.AuthMiddleware(), browserAgentCtrl.requireAdmin())
 		adminDashboard.GET("/summary", browserAgentCtrl.GetAdminSummary)
 		adminDashboard.GET("/weekly-task-volume", browserAgentCtrl.GetAdminWeeklyTaskVolume)
 		adminDashboard.GET("/weekly-task-success-rate", browserAgentCtrl.GetAdminWeeklyTaskSuccessRate)
@@ -73,7 +98,9 @@ func NewBrowserAgentController(
 		adminDashboard.GET("/annual-task-stats", browserAgentCtrl.GetAdminAnnualTaskStats)
 		adminDashboard.GET("/hot-task-list", browserAgentCtrl.GetAdminHotTaskList)
 		adminDashboard.POST("/messages", browserAgentCtrl.GetMessagePage)
+		adminDashboard.POST("/policy-violations", browserAgentCtrl.GetPolicyViolationPage)
 		adminDashboard.GET("/actions", browserAgentCtrl.GetActionsByMessageID)
+		adminDashboard.GET("/token-usage", browserAgentCtrl.GetAdminTokenUsage)
 	}
 
 	{
@@ -88,6 +115,7 @@ func NewBrowserAgentController(
 		userDashboard.GET("/weekly-task-success-rate", browserAgentCtrl.GetUserWeeklyTaskSuccessRate)
 		userDashboard.GET("/task-overview", browserAgentCtrl.GetUserTaskOverview)
 		userDashboard.GET("/task-trend", browserAgentCtrl.GetUserTaskTrend)
+		userDashboard.GET("/token-usage", browserAgentCtrl.GetUserTokenUsage)
 	}
 
 	{
@@ -98,6 +126,18 @@ func NewBrowserAgentController(
 	return browserAgentCtrl
 }
 
+// requireAdmin 管理员仪表盘接口的权限校验，非管理员返回 403
+func (ctrl *BrowserAgentController) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ctrl.browserAgentDashboardService.CheckAdmin(c, authutils.GetUserID(c)); err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 func (ctrl *BrowserAgentController) CreateConversation(c *gin.Context) {
 	var req request.CreateConversationRequest
 	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
@@ -161,6 +201,234 @@ func (ctrl *BrowserAgentController) DeleteConversation(c *gin.Context) {
 	result.OkWithMessage("删除成功", c)
 }
 
+func (ctrl *BrowserAgentController) UpdateConversationModel(c *gin.Context) {
+	var req request.UpdateConversationModelRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	if err := ctrl.browserAgentService.UpdateConversationModel(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("修改成功", c)
+}
+
+func (ctrl *BrowserAgentController) GetDefaultModel(c *gin.Context) {
+	resp, err := ctrl.browserAgentService.GetDefaultModel(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) SetDefaultModel(c *gin.Context) {
+	var req request.SetDefaultModelRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	if err := ctrl.browserAgentService.SetDefaultModel(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("设置成功", c)
+}
+
+func (ctrl *BrowserAgentController) ListURLPolicies(c *gin.Context) {
+	var req request.ListURLPolicyRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentService.ListURLPolicies(c, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) SaveURLPolicy(c *gin.Context) {
+	var req request.SaveURLPolicyRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	if err := ctrl.browserAgentService.SaveURLPolicy(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("保存成功", c)
+}
+
+func (ctrl *BrowserAgentController) DeleteURLPolicy(c *gin.Context) {
+	idStr := c.Query("id")
+	policyID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		result.FailWithMessage("无效的ID", c)
+		return
+	}
+
+	if err = ctrl.browserAgentService.DeleteURLPolicy(c, policyID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("删除成功", c)
+}
+
+func (ctrl *BrowserAgentController) ListSecrets(c *gin.Context) {
+	resp, err := ctrl.browserAgentService.ListSecrets(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) SaveSecret(c *gin.Context) {
+	var req request.SaveSecretRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	if err := ctrl.browserAgentService.SaveSecret(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("保存成功", c)
+}
+
+func (ctrl *BrowserAgentController) DeleteSecret(c *gin.Context) {
+	idStr := c.Query("id")
+	secretID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		result.FailWithMessage("无效的ID", c)
+		return
+	}
+
+	if err = ctrl.browserAgentService.DeleteSecret(c, secretID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("删除成功", c)
+}
+
+func (ctrl *BrowserAgentController) CreateWorkflow(c *gin.Context) {
+	var req request.CreateWorkflowRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentService.CreateWorkflow(c, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) ListWorkflows(c *gin.Context) {
+	resp, err := ctrl.browserAgentService.ListWorkflows(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) DeleteWorkflow(c *gin.Context) {
+	idStr := c.Query("id")
+	workflowID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		result.FailWithMessage("无效的ID", c)
+		return
+	}
+
+	if err = ctrl.browserAgentService.DeleteWorkflow(c, workflowID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("删除成功", c)
+}
+
+func (ctrl *BrowserAgentController) RunWorkflow(c *gin.Context) {
+	var req request.RunWorkflowRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentService.RunWorkflow(c, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) SaveSchedule(c *gin.Context) {
+	var req request.SaveScheduleRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentService.SaveSchedule(c, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) ListSchedules(c *gin.Context) {
+	resp, err := ctrl.browserAgentService.ListSchedules(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) DeleteSchedule(c *gin.Context) {
+	idStr := c.Query("id")
+	scheduleID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		result.FailWithMessage("无效的ID", c)
+		return
+	}
+
+	if err = ctrl.browserAgentService.DeleteSchedule(c, scheduleID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("删除成功", c)
+}
+
 func (ctrl *BrowserAgentController) CreateMessage(c *gin.Context) {
 	var req request.CreateMessageRequest
 	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
@@ -193,6 +461,102 @@ func (ctrl *BrowserAgentController) ListMessages(c *gin.Context) {
 	result.OkWithData(resp, c)
 }
 
+func (ctrl *BrowserAgentController) CancelMessage(c *gin.Context) {
+	var req request.MessageControlRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+	if err := ctrl.browserAgentService.CancelTask(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("任务已取消", c)
+}
+
+func (ctrl *BrowserAgentController) PauseMessage(c *gin.Context) {
+	var req request.MessageControlRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+	if err := ctrl.browserAgentService.PauseTask(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("任务已暂停", c)
+}
+
+func (ctrl *BrowserAgentController) ResumeMessage(c *gin.Context) {
+	var req request.MessageControlRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+	if err := ctrl.browserAgentService.ResumeTask(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithMessage("已通知客户端恢复任务", c)
+}
+
+func (ctrl *BrowserAgentController) DownloadExtractResult(c *gin.Context) {
+	var req request.DownloadExtractResultRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	file, err := ctrl.browserAgentService.ExportExtractResult(c, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, file.FileName))
+	c.Data(http.StatusOK, file.ContentType, file.Content)
+}
+
+func (ctrl *BrowserAgentController) GetMessageTrace(c *gin.Context) {
+	var req request.GetActionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentService.GetMessageTrace(c, req.MessageID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) ExportMessageTrace(c *gin.Context) {
+	var req request.ExportMessageTraceRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	file, err := ctrl.browserAgentService.ExportMessageTrace(c, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, file.FileName))
+	c.Data(http.StatusOK, file.ContentType, file.Content)
+}
+
+func (ctrl *BrowserAgentController) ListClients(c *gin.Context) {
+	result.OkWithData(ctrl.browserAgentService.ListClients(c), c)
+}
+
 func (ctrl *BrowserAgentController) ListActions(c *gin.Context) {
 	var req request.GetActionsRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
@@ -218,6 +582,20 @@ func (ctrl *BrowserAgentController) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
+	// client_id 用于重连时替换旧连接，缺失时同一客户端的重复连接会不断累积
+	info := parseClientInfo(c)
+	if info.ClientID == "" {
+		result.FailWithMessage("缺少客户端标识 client_id", c)
+		return
+	}
+
+	// 升级前校验会话归属，拒绝时按普通 HTTP 请求返回错误
+	userID := authutils.GetUserID(c)
+	if err = ctrl.browserAgentService.CheckConversationAccess(c, userID, conversationID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
 	conn, err := ctrl.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		zap.L().Error("升级为WebSocket失败", zap.Error(err))
@@ -229,11 +607,12 @@ func (ctrl *BrowserAgentController) HandleWebSocket(c *gin.Context) {
 		Hub:            ctrl.hub,
 		Conn:           conn,
 		ConversationID: conversationID,
-		UserID:         authutils.GetUserID(c),
+		UserID:         userID,
 		Send:           make(chan []byte, 256),
 		Service:        ctrl.browserAgentService,
 		Ctx:            clientCtx,
 		Cancel:         cancel,
+		Info:           info,
 	}
 
 	ctrl.hub.Register(client)
@@ -242,7 +621,13 @@ func (ctrl *BrowserAgentController) HandleWebSocket(c *gin.Context) {
 }
 
 func (ctrl *BrowserAgentController) GetAdminSummary(c *gin.Context) {
-	resp, err := ctrl.browserAgentDashboardService.GetAdminSummary(c)
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminSummary(c, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -251,7 +636,13 @@ func (ctrl *BrowserAgentController) GetAdminSummary(c *gin.Context) {
 }
 
 func (ctrl *BrowserAgentController) GetAdminWeeklyTaskVolume(c *gin.Context) {
-	resp, err := ctrl.browserAgentDashboardService.GetAdminWeeklyTaskVolume(c)
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminWeeklyTaskVolume(c, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -260,7 +651,13 @@ func (ctrl *BrowserAgentController) GetAdminWeeklyTaskVolume(c *gin.Context) {
 }
 
 func (ctrl *BrowserAgentController) GetAdminWeeklyTaskSuccessRate(c *gin.Context) {
-	resp, err := ctrl.browserAgentDashboardService.GetAdminWeeklyTaskSuccessRate(c)
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminWeeklyTaskSuccessRate(c, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -269,7 +666,13 @@ func (ctrl *BrowserAgentController) GetAdminWeeklyTaskSuccessRate(c *gin.Context
 }
 
 func (ctrl *BrowserAgentController) GetAdminTotalTaskVolume(c *gin.Context) {
-	resp, err := ctrl.browserAgentDashboardService.GetAdminTotalTaskVolume(c)
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminTotalTaskVolume(c, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -278,7 +681,13 @@ func (ctrl *BrowserAgentController) GetAdminTotalTaskVolume(c *gin.Context) {
 }
 
 func (ctrl *BrowserAgentController) GetAdminTaskClassification(c *gin.Context) {
-	resp, err := ctrl.browserAgentDashboardService.GetAdminTaskClassification(c)
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminTaskClassification(c, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -287,7 +696,13 @@ func (ctrl *BrowserAgentController) GetAdminTaskClassification(c *gin.Context) {
 }
 
 func (ctrl *BrowserAgentController) GetAdminWeeklyOperationVolume(c *gin.Context) {
-	resp, err := ctrl.browserAgentDashboardService.GetAdminWeeklyOperationVolume(c)
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminWeeklyOperationVolume(c, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -296,7 +711,13 @@ func (ctrl *BrowserAgentController) GetAdminWeeklyOperationVolume(c *gin.Context
 }
 
 func (ctrl *BrowserAgentController) GetAdminWeeklyOperationSuccessRate(c *gin.Context) {
-	resp, err := ctrl.browserAgentDashboardService.GetAdminWeeklyOperationSuccessRate(c)
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminWeeklyOperationSuccessRate(c, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -305,7 +726,13 @@ func (ctrl *BrowserAgentController) GetAdminWeeklyOperationSuccessRate(c *gin.Co
 }
 
 func (ctrl *BrowserAgentController) GetAdminActiveSessions(c *gin.Context) {
-	resp, err := ctrl.browserAgentDashboardService.GetAdminActiveSessions(c)
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminActiveSessions(c, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -322,7 +749,13 @@ func (ctrl *BrowserAgentController) GetAdminAnnualTaskStats(c *gin.Context) {
 		req.Year = time.Now().Year()
 	}
 
-	resp, err := ctrl.browserAgentDashboardService.GetAdminAnnualTaskStats(c, req.Year)
+	var rangeReq request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&rangeReq); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminAnnualTaskStats(c, req.Year, &rangeReq)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -336,7 +769,35 @@ func (ctrl *BrowserAgentController) GetAdminHotTaskList(c *gin.Context) {
 		req.Limit = 6
 	}
 
-	resp, err := ctrl.browserAgentDashboardService.GetAdminHotTaskList(c, req.Limit)
+	var rangeReq request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&rangeReq); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminHotTaskList(c, req.Limit, &rangeReq)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) GetAdminTokenUsage(c *gin.Context) {
+	// limit 未传时默认 10，传入非法值时返回参数错误
+	req := request.LimitRequest{Limit: 10}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	var rangeReq request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&rangeReq); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetAdminTokenUsage(c, req.Limit, &rangeReq)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -359,9 +820,51 @@ func (ctrl *BrowserAgentController) GetMessagePage(c *gin.Context) {
 	result.OkWithData(resp, c)
 }
 
+func (ctrl *BrowserAgentController) GetPolicyViolationPage(c *gin.Context) {
+	var queryParam query.BrowserAgentPolicyViolation
+	if err := c.ShouldBindBodyWithJSON(&queryParam); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetPolicyViolationPage(c, &queryParam)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	result.OkWithData(resp, c)
+}
+
 func (ctrl *BrowserAgentController) GetUserSummary(c *gin.Context) {
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
 	userID := authutils.GetUserID(c)
-	resp, err := ctrl.browserAgentDashboardService.GetUserSummary(c, userID)
+	resp, err := ctrl.browserAgentDashboardService.GetUserSummary(c, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	result.OkWithData(resp, c)
+}
+
+func (ctrl *BrowserAgentController) GetUserTokenUsage(c *gin.Context) {
+	req := request.LimitRequest{Limit: 10}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	var rangeReq request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&rangeReq); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	resp, err := ctrl.browserAgentDashboardService.GetUserTokenUsage(c, authutils.GetUserID(c), req.Limit, &rangeReq)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -370,8 +873,14 @@ func (ctrl *BrowserAgentController) GetUserSummary(c *gin.Context) {
 }
 
 func (ctrl *BrowserAgentController) GetUserWeeklyTaskVolume(c *gin.Context) {
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
 	userID := authutils.GetUserID(c)
-	resp, err := ctrl.browserAgentDashboardService.GetUserWeeklyTaskVolume(c, userID)
+	resp, err := ctrl.browserAgentDashboardService.GetUserWeeklyTaskVolume(c, userID, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -380,8 +889,14 @@ func (ctrl *BrowserAgentController) GetUserWeeklyTaskVolume(c *gin.Context) {
 }
 
 func (ctrl *BrowserAgentController) GetUserWeeklyTaskSuccessRate(c *gin.Context) {
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
 	userID := authutils.GetUserID(c)
-	resp, err := ctrl.browserAgentDashboardService.GetUserWeeklyTaskSuccessRate(c, userID)
+	resp, err := ctrl.browserAgentDashboardService.GetUserWeeklyTaskSuccessRate(c, userID, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -390,8 +905,14 @@ func (ctrl *BrowserAgentController) GetUserWeeklyTaskSuccessRate(c *gin.Context)
 }
 
 func (ctrl *BrowserAgentController) GetUserTaskOverview(c *gin.Context) {
+	var req request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
 	userID := authutils.GetUserID(c)
-	resp, err := ctrl.browserAgentDashboardService.GetUserTaskOverview(c, userID)
+	resp, err := ctrl.browserAgentDashboardService.GetUserTaskOverview(c, userID, &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -408,8 +929,14 @@ func (ctrl *BrowserAgentController) GetUserTaskTrend(c *gin.Context) {
 		req.Year = time.Now().Year()
 	}
 
+	var rangeReq request.DashboardRangeRequest
+	if err := c.ShouldBindQuery(&rangeReq); err != nil {
+		result.FailWithMessage(err.Error(), c)
+		return
+	}
+
 	userID := authutils.GetUserID(c)
-	resp, err := ctrl.browserAgentDashboardService.GetUserTaskTrend(c, userID, req.Year)
+	resp, err := ctrl.browserAgentDashboardService.GetUserTaskTrend(c, userID, req.Year, &rangeReq)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -432,3 +959,17 @@ func (ctrl *BrowserAgentController) GetActionsByMessageID(c *gin.Context) {
 
 	result.OkWithData(resp, c)
 }
+
+// parseClientInfo 读取客户端建立连接时通过查询参数上报的能力元数据
+func parseClientInfo(c *gin.Context) ws.ClientInfo {
+	info := ws.ClientInfo{
+		ClientID:       c.Query("client_id"),
+		BrowserType:    c.Query("browser_type"),
+		BrowserVersion: c.Query("browser_version"),
+		ConnectedAt:    time.Now(),
+	}
+	if capabilities := c.Query("capabilities"); capabilities != "" {
+		info.Capabilities = strings.Split(capabilities, ",")
+	}
+	return info
+}
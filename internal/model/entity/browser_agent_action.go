@@ -3,6 +3,8 @@ package entity
 import (
 	"Art-Design-Backend/pkg/constant/tablename"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 // 操作状态常量
@@ -12,23 +14,52 @@ const (
 	ActionStatusSuccess = "success"
 	ActionStatusFailed  = "failed"
 	ActionStatusSkipped = "skipped"
+
+	ActionStatusAwaitingConfirm = "awaiting_confirm" // 敏感操作，等待用户确认
+	ActionStatusRejected        = "rejected"         // 用户拒绝执行
+)
+
+// 用户确认结果常量
+const (
+	ConfirmDecisionApproved = "approved"
+	ConfirmDecisionRejected = "rejected"
+	ConfirmDecisionEdited   = "edited"
 )
 
 // BrowserAgentAction 浏览器代理操作实体
 type BrowserAgentAction struct {
-	ID            int64     `gorm:"type:bigint;primaryKey;comment:雪花ID"`
-	MessageID     int64     `gorm:"column:message_id;not null;index;comment:消息ID"`
-	ActionType    string    `gorm:"column:action_type;type:varchar(30);not null;comment:操作类型(goto/click/input/select/scroll/wait)"`
-	Status        string    `gorm:"column:status;type:varchar(20);default:pending;comment:状态"`
-	URL           *string   `gorm:"column:url;type:varchar(500);comment:URL(goto)"`
-	Selector      *string   `gorm:"column:selector;type:varchar(500);comment:选择器(click/input/select)"`
-	Value         *string   `gorm:"column:value;type:text;comment:值(input/select)"`
-	Distance      *int      `gorm:"column:distance;comment:滚动距离"`
-	Timeout       *int      `gorm:"column:timeout;comment:等待时间"`
-	ErrorMessage  *string   `gorm:"column:error_message;type:text;comment:错误信息"`
-	ExecutionTime *int      `gorm:"column:execution_time;comment:执行耗时(毫秒)"`
-	CreatedAt     time.Time `gorm:"type:timestamp;column:created_at;autoCreateTime"`
-	UpdatedAt     time.Time `gorm:"type:timestamp;column:updated_at;autoUpdateTime"`
+	ID              int64      `gorm:"type:bigint;primaryKey;comment:雪花ID"`
+	MessageID       int64      `gorm:"column:message_id;not null;index;comment:消息ID"`
+	ActionType      string     `gorm:"column:action_type;type:varchar(30);not null;comment:操作类型(goto/click/input/select/scroll/wait/hover/press_key/switch_tab/open_tab/close_tab/go_back/upload_file/extract/screenshot)"`
+	Status          string     `gorm:"column:status;type:varchar(20);default:pending;comment:状态"`
+	URL             *string    `gorm:"column:url;type:varchar(500);comment:URL(goto)"`
+	Selector        *string    `gorm:"column:selector;type:varchar(500);comment:选择器(click/input/select)"`
+	Value           *string    `gorm:"column:value;type:text;comment:值(input/select)"`
+	Distance        *int       `gorm:"column:distance;comment:滚动距离"`
+	Timeout         *int       `gorm:"column:timeout;comment:等待时间"`
+	Key             *string    `gorm:"column:key;type:varchar(50);comment:按键(press_key)"`
+	TabIndex        *int       `gorm:"column:tab_index;comment:标签页序号(switch_tab/close_tab)"`
+	FileURL         *string    `gorm:"column:file_url;type:varchar(500);comment:上传文件地址(upload_file)"`
+	Extracted       *string    `gorm:"column:extracted;type:text;comment:提取内容(extract)"`
+	ScreenshotURL   *string    `gorm:"column:screenshot_url;type:varchar(500);comment:截图地址(screenshot)"`
+	ErrorMessage    *string    `gorm:"column:error_message;type:text;comment:错误信息"`
+	ExecutionTime   *int       `gorm:"column:execution_time;comment:执行耗时(毫秒)"`
+	ResultURL       *string    `gorm:"column:result_url;type:varchar(500);comment:执行后页面URL"`
+	PageFingerprint *string    `gorm:"column:page_fingerprint;type:varchar(32);comment:执行后页面指纹"`
+	RetryCount      int        `gorm:"column:retry_count;default:0;comment:同一步第几次重新规划(0 为首次执行)"`
+	Rationale       *string    `gorm:"column:rationale;type:varchar(500);comment:模型选择该操作的理由"`
+	PolicyViolation bool       `gorm:"column:policy_violation;default:false;index;comment:是否因违反 URL 策略被拦截"`
+	ConfirmReason   *string    `gorm:"column:confirm_reason;type:varchar(200);comment:需要用户确认的原因"`
+	ConfirmDecision *string    `gorm:"column:confirm_decision;type:varchar(20);comment:用户确认结果(approved/rejected/edited)"`
+	ConfirmedBy     *int64     `gorm:"column:confirmed_by;type:bigint;comment:确认人ID"`
+	ConfirmedAt     *time.Time `gorm:"column:confirmed_at;type:timestamp;comment:确认时间"`
+
+	PromptTokens     int             `gorm:"column:prompt_tokens;default:0;comment:决策该操作消耗的输入 token"`
+	CompletionTokens int             `gorm:"column:completion_tokens;default:0;comment:决策该操作消耗的输出 token"`
+	Cost             decimal.Decimal `gorm:"column:cost;type:numeric(20,8);default:0;comment:决策该操作的模型费用"`
+
+	CreatedAt time.Time `gorm:"type:timestamp;column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"type:timestamp;column:updated_at;autoUpdateTime"`
 }
 
 // TableName 指定操作表名
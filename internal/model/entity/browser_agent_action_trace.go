@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"Art-Design-Backend/pkg/constant/tablename"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// LLMCall 一次模型调用的输入输出
+type LLMCall struct {
+	Model      string    `json:"model"`
+	Vision     bool      `json:"vision"`             // 是否为携带截图的多模态调用
+	Prompt     string    `json:"prompt"`             // 用户提示词，系统提示词为固定内容不重复保存
+	Response   string    `json:"response,omitempty"` // 模型原始响应（流式调用时为拼接后的完整响应）
+	Error      string    `json:"error,omitempty"`    // 调用或解析失败的原因
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int       `json:"duration_ms"`
+
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	Cost             decimal.Decimal `json:"cost"` // 按模型单价计算的费用，币种为模型的计价币种
+}
+
+// BrowserAgentActionTrace 操作的决策轨迹：决策时客户端上报的页面状态与模型调用记录，用于排查失败的任务
+//
+// 一次决策可能包含多次模型调用（多模态降级、URL 策略拦截后重新规划等），按调用顺序保存；
+// 轨迹数据量较大，超过保留天数后由定时任务清理
+type BrowserAgentActionTrace struct {
+	ID        int64     `gorm:"type:bigint;primaryKey;comment:雪花ID"`
+	MessageID int64     `gorm:"column:message_id;not null;index;comment:消息ID"`
+	ActionID  int64     `gorm:"column:action_id;not null;index;comment:操作ID"`
+	PageState string    `gorm:"column:page_state;type:text;comment:决策时的页面状态 JSON(不含截图)"`
+	ImageURL  *string   `gorm:"column:image_url;type:text;comment:决策使用的标注截图地址"`
+	LLMCalls  []LLMCall `gorm:"column:llm_calls;type:jsonb;serializer:json;comment:模型调用记录"`
+	CreatedAt time.Time `gorm:"type:timestamp;column:created_at;autoCreateTime;index"`
+}
+
+// TableName 指定操作轨迹表名
+func (b *BrowserAgentActionTrace) TableName() string {
+	return tablename.BrowserAgentActionTraceTableName
+}
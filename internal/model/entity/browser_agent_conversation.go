@@ -10,6 +10,7 @@ type BrowserAgentConversation struct {
 	common.BaseModel
 	Title       string `gorm:"column:title;type:varchar(100);not null;comment:会话标题"`
 	BrowserType string `gorm:"column:browser_type;type:varchar(30);default:chrome;comment:浏览器类型"`
+	ModelID     int64  `gorm:"column:model_id;type:bigint;default:0;comment:决策使用的AI模型ID，0 表示使用系统默认模型"`
 }
 
 // TableName 指定会话表名
@@ -3,21 +3,50 @@ package entity
 import (
 	"Art-Design-Backend/pkg/constant/tablename"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 // 会话状态常量
 const (
-	MessageStateRunning  = "running"
-	MessageStateFinished = "finished"
-	MessageStateError    = "error"
+	MessageStateRunning     = "running"
+	MessageStateFinished    = "finished"
+	MessageStateError       = "error"
+	MessageStateAbortedLoop = "aborted_loop" // 检测到循环、停滞或超出步数上限而终止
+	MessageStateQueued      = "queued"       // 定时任务已生成，等待下发给客户端
+	MessageStateExpired     = "expired"      // 客户端长时间离线，排队的定时任务已过期
+	MessageStateCancelled   = "cancelled"    // 用户取消
+	MessageStatePaused      = "paused"       // 用户暂停，恢复后从当前页面继续规划
 )
 
+// PlanStep 任务计划中的一个子目标
+type PlanStep struct {
+	Goal string `json:"goal"`
+	Done bool   `json:"done"`
+}
+
 type BrowserAgentMessage struct {
-	ID             int64     `gorm:"type:bigint;primaryKey;comment:雪花ID"`
-	ConversationID int64     `gorm:"column:conversation_id;not null;index;comment:会话ID"`
-	Content        string    `gorm:"column:content;type:text;comment:用户任务描述"`
-	State          string    `gorm:"column:state;type:varchar(30);default:running;comment:状态"`
-	CreatedAt      time.Time `gorm:"type:timestamp;column:created_at;autoCreateTime"`
+	ID             int64             `gorm:"type:bigint;primaryKey;comment:雪花ID"`
+	ConversationID int64             `gorm:"column:conversation_id;not null;index;comment:会话ID"`
+	Content        string            `gorm:"column:content;type:text;comment:用户任务描述"`
+	State          string            `gorm:"column:state;type:varchar(30);default:running;comment:状态"`
+	ErrorMessage   *string           `gorm:"column:error_message;type:text;comment:失败或终止原因"`
+	ExtractSchema  map[string]any    `gorm:"column:extract_schema;type:jsonb;serializer:json;comment:数据采集 JSON Schema"`
+	ExtractResult  any               `gorm:"column:extract_result;type:jsonb;serializer:json;comment:数据采集结果"`
+	WorkflowID     int64             `gorm:"column:workflow_id;type:bigint;default:0;comment:回放的工作流ID，0 表示普通任务"`
+	WorkflowParams map[string]string `gorm:"column:workflow_params;type:jsonb;serializer:json;comment:工作流回放参数"`
+	WorkflowStep   int               `gorm:"column:workflow_step;default:0;comment:工作流下一步要执行的步骤序号"`
+	ScheduleID     int64             `gorm:"column:schedule_id;type:bigint;default:0;comment:触发的定时任务ID，0 表示用户手动发起"`
+	PlanEnabled    bool              `gorm:"column:plan_enabled;default:false;comment:是否先拆解子目标再执行"`
+	Plan           []PlanStep        `gorm:"column:plan;type:jsonb;serializer:json;comment:按顺序完成的子目标"`
+	PlanRevisions  int               `gorm:"column:plan_revisions;default:0;comment:计划修订次数"`
+
+	PromptTokens     int             `gorm:"column:prompt_tokens;default:0;comment:任务累计输入 token（含未生成操作的模型调用）"`
+	CompletionTokens int             `gorm:"column:completion_tokens;default:0;comment:任务累计输出 token"`
+	Cost             decimal.Decimal `gorm:"column:cost;type:numeric(20,8);default:0;comment:任务累计模型费用"`
+
+	StartedAt *time.Time `gorm:"column:started_at;type:timestamp;comment:排队的定时任务下发给客户端的时间，为空时以创建时间为准"`
+	CreatedAt time.Time  `gorm:"type:timestamp;column:created_at;autoCreateTime"`
 }
 
 func (b *BrowserAgentMessage) TableName() string {
@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"Art-Design-Backend/internal/model/common"
+	"Art-Design-Backend/pkg/constant/tablename"
+	"time"
+)
+
+// BrowserAgentSchedule 浏览器智能体定时任务，按 cron 表达式在目标会话中执行任务或工作流
+type BrowserAgentSchedule struct {
+	common.BaseModel
+	Name           string            `gorm:"column:name;type:varchar(100);not null;comment:定时任务名称"`
+	ConversationID int64             `gorm:"column:conversation_id;type:bigint;not null;index;comment:目标会话ID"`
+	CronExpr       string            `gorm:"column:cron_expr;type:varchar(100);not null;comment:cron 表达式（分 时 日 月 周）"`
+	Task           string            `gorm:"column:task;type:text;comment:任务描述，与工作流二选一"`
+	ExtractSchema  map[string]any    `gorm:"column:extract_schema;type:jsonb;serializer:json;comment:数据采集 JSON Schema"`
+	WorkflowID     int64             `gorm:"column:workflow_id;type:bigint;default:0;comment:执行的工作流ID，0 表示执行任务描述"`
+	WorkflowParams map[string]string `gorm:"column:workflow_params;type:jsonb;serializer:json;comment:工作流参数"`
+	Enabled        bool              `gorm:"column:enabled;default:true;comment:是否启用"`
+	NextRunAt      *time.Time        `gorm:"column:next_run_at;type:timestamp;index;comment:下次执行时间"`
+	LastRunAt      *time.Time        `gorm:"column:last_run_at;type:timestamp;comment:上次执行时间"`
+	LastMessageID  int64             `gorm:"column:last_message_id;type:bigint;default:0;comment:上次执行生成的任务ID"`
+}
+
+// TableName 指定定时任务表名
+func (b *BrowserAgentSchedule) TableName() string {
+	return tablename.BrowserAgentScheduleTableName
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"Art-Design-Backend/internal/model/common"
+	"Art-Design-Backend/pkg/constant/tablename"
+)
+
+// BrowserAgentSecret 浏览器智能体用户凭据
+//
+// 任务与模型只接触 {{secret:name}} 占位符，真实值仅在下发给客户端的操作中替换
+type BrowserAgentSecret struct {
+	common.BaseModel
+	UserID     int64  `gorm:"column:user_id;type:bigint;not null;uniqueIndex:idx_secret_user_name;comment:所属用户ID"`
+	Name       string `gorm:"column:name;type:varchar(64);not null;uniqueIndex:idx_secret_user_name;comment:凭据名称，即占位符中的 name"`
+	Ciphertext string `gorm:"column:ciphertext;type:text;not null;comment:AES-GCM 加密后的凭据值"`
+	Remark     string `gorm:"column:remark;type:varchar(200);comment:备注"`
+}
+
+// TableName 指定凭据表名
+func (b *BrowserAgentSecret) TableName() string {
+	return tablename.BrowserAgentSecretTableName
+}
@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"Art-Design-Backend/internal/model/common"
+	"Art-Design-Backend/pkg/constant/tablename"
+)
+
+// URL 策略作用范围常量
+const (
+	URLPolicyScopeRole         = "role"
+	URLPolicyScopeConversation = "conversation"
+)
+
+// BrowserAgentURLPolicy 浏览器智能体 URL 访问策略，按角色或会话配置
+//
+// 同一任务命中的所有策略需同时满足：任一策略拒绝即拒绝访问
+type BrowserAgentURLPolicy struct {
+	common.BaseModel
+	Scope           string   `gorm:"column:scope;type:varchar(20);not null;uniqueIndex:idx_url_policy_scope;comment:作用范围(role/conversation)"`
+	ScopeID         int64    `gorm:"column:scope_id;type:bigint;not null;uniqueIndex:idx_url_policy_scope;comment:角色ID或会话ID"`
+	AllowedDomains  []string `gorm:"column:allowed_domains;type:jsonb;serializer:json;comment:允许访问的域名（含子域名），与允许规则均为空表示不限制"`
+	DeniedDomains   []string `gorm:"column:denied_domains;type:jsonb;serializer:json;comment:禁止访问的域名（含子域名）"`
+	AllowedPatterns []string `gorm:"column:allowed_patterns;type:jsonb;serializer:json;comment:允许访问的 URL 正则"`
+	DeniedPatterns  []string `gorm:"column:denied_patterns;type:jsonb;serializer:json;comment:禁止访问的 URL 正则"`
+	BlockPrivateIP  bool     `gorm:"column:block_private_ip;default:false;comment:是否禁止访问内网地址及非 http(s) 协议"`
+	Remark          string   `gorm:"column:remark;type:varchar(200);comment:备注"`
+}
+
+// TableName 指定 URL 策略表名
+func (b *BrowserAgentURLPolicy) TableName() string {
+	return tablename.BrowserAgentURLPolicyTableName
+}
@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"Art-Design-Backend/internal/model/common"
+	"Art-Design-Backend/pkg/constant/tablename"
+)
+
+// WorkflowStep 工作流中的一步操作，value/url 中可包含 {{param:name}} 参数占位符
+type WorkflowStep struct {
+	Action   string  `json:"action"`
+	URL      *string `json:"url,omitempty"`
+	Selector *string `json:"selector,omitempty"`
+	Value    *string `json:"value,omitempty"`
+	Distance *int    `json:"distance,omitempty"`
+	Timeout  *int    `json:"timeout,omitempty"`
+	Key      *string `json:"key,omitempty"`
+	TabIndex *int    `json:"tab_index,omitempty"`
+	FileURL  *string `json:"file_url,omitempty"`
+}
+
+// WorkflowParam 工作流参数，回放时未传入的参数使用录制时的原始值
+type WorkflowParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+}
+
+// BrowserAgentWorkflow 由成功任务录制而成的可复用工作流
+type BrowserAgentWorkflow struct {
+	common.BaseModel
+	Name            string          `gorm:"column:name;type:varchar(100);not null;comment:工作流名称"`
+	Description     string          `gorm:"column:description;type:varchar(500);comment:工作流描述"`
+	Task            string          `gorm:"column:task;type:text;comment:录制来源任务描述，回放需重新规划时作为模型的任务目标"`
+	SourceMessageID int64           `gorm:"column:source_message_id;type:bigint;comment:录制来源任务ID"`
+	Steps           []WorkflowStep  `gorm:"column:steps;type:jsonb;serializer:json;comment:按顺序执行的操作"`
+	Params          []WorkflowParam `gorm:"column:params;type:jsonb;serializer:json;comment:参数定义"`
+}
+
+// TableName 指定工作流表名
+func (b *BrowserAgentWorkflow) TableName() string {
+	return tablename.BrowserAgentWorkflowTableName
+}
@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"Art-Design-Backend/pkg/constant/tablename"
+	"time"
+)
+
+// SystemSetting 系统配置项（键值对），保存可在运行时调整的全局设置
+type SystemSetting struct {
+	Key       string    `gorm:"column:setting_key;type:varchar(100);primaryKey;comment:配置键"`
+	Value     string    `gorm:"column:setting_value;type:text;not null;comment:配置值"`
+	Remark    string    `gorm:"column:remark;type:varchar(200);comment:配置说明"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp;autoUpdateTime"`
+	UpdateBy  int64     `gorm:"column:updated_by;type:bigint"`
+}
+
+// TableName 指定系统配置表名
+func (s *SystemSetting) TableName() string {
+	return tablename.SystemSettingTableName
+}
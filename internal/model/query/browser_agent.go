@@ -8,6 +8,11 @@ type BrowserAgentConversation struct {
 	common.PaginationReq
 }
 
+type BrowserAgentPolicyViolation struct {
+	MessageID common.LongStringID `json:"message_id" form:"message_id"`
+	common.PaginationReq
+}
+
 type BrowserAgentMessage struct {
 	ConversationID common.LongStringID `json:"conversation_id" form:"conversation_id"`
 	State          string              `json:"state" form:"state"`
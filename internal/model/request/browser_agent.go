@@ -3,8 +3,64 @@ package request
 import "Art-Design-Backend/internal/model/common"
 
 type CreateConversationRequest struct {
-	Title       string `json:"title" binding:"required,max=100"`
-	BrowserType string `json:"browser_type" binding:"required"`
+	Title       string              `json:"title" binding:"required,max=100"`
+	BrowserType string              `json:"browser_type" binding:"required"`
+	ModelID     common.LongStringID `json:"model_id"` // 可选，缺省使用系统默认模型
+}
+
+type UpdateConversationModelRequest struct {
+	ID      common.LongStringID `json:"id" binding:"required"`
+	ModelID common.LongStringID `json:"model_id"` // 传 0 或空表示恢复为系统默认模型
+}
+
+type SaveURLPolicyRequest struct {
+	Scope           string              `json:"scope" binding:"required,oneof=role conversation"`
+	ScopeID         common.LongStringID `json:"scope_id" binding:"required"`
+	AllowedDomains  []string            `json:"allowed_domains"`
+	DeniedDomains   []string            `json:"denied_domains"`
+	AllowedPatterns []string            `json:"allowed_patterns"`
+	DeniedPatterns  []string            `json:"denied_patterns"`
+	BlockPrivateIP  bool                `json:"block_private_ip"`
+	Remark          string              `json:"remark" binding:"max=200"`
+}
+
+type ListURLPolicyRequest struct {
+	Scope   string `form:"scope" binding:"omitempty,oneof=role conversation"`
+	ScopeID int64  `form:"scope_id"`
+}
+
+type SaveSecretRequest struct {
+	Name   string `json:"name" binding:"required,max=64"`
+	Value  string `json:"value" binding:"required"`
+	Remark string `json:"remark" binding:"max=200"`
+}
+
+type CreateWorkflowRequest struct {
+	MessageID   common.LongStringID `json:"message_id" binding:"required"`
+	Name        string              `json:"name" binding:"required,max=100"`
+	Description string              `json:"description" binding:"max=500"`
+}
+
+type RunWorkflowRequest struct {
+	WorkflowID     common.LongStringID `json:"workflow_id" binding:"required"`
+	ConversationID common.LongStringID `json:"conversation_id" binding:"required"`
+	Params         map[string]string   `json:"params"` // 未传入的参数使用录制时的原始值
+}
+
+type SaveScheduleRequest struct {
+	ID             common.LongStringID `json:"id"` // 为空时新建
+	Name           string              `json:"name" binding:"required,max=100"`
+	ConversationID common.LongStringID `json:"conversation_id" binding:"required"`
+	CronExpr       string              `json:"cron_expr" binding:"required,max=100"` // 标准 5 段 cron 表达式或 @daily 等描述符
+	Task           string              `json:"task"`                                 // 与 workflow_id 二选一
+	ExtractSchema  map[string]any      `json:"extract_schema,omitempty"`
+	WorkflowID     common.LongStringID `json:"workflow_id"`
+	WorkflowParams map[string]string   `json:"workflow_params"`
+	Enabled        bool                `json:"enabled"`
+}
+
+type SetDefaultModelRequest struct {
+	ModelID common.LongStringID `json:"model_id" binding:"required"`
 }
 
 type RenameConversationRequest struct {
@@ -17,8 +73,15 @@ type DeleteConversationRequest struct {
 }
 
 type CreateMessageRequest struct {
-	ConversationID int64  `json:"conversation_id,string" binding:"required"`
-	Content        string `json:"content" binding:"required"`
+	ConversationID int64          `json:"conversation_id,string" binding:"required"`
+	Content        string         `json:"content" binding:"required"`
+	ExtractSchema  map[string]any `json:"extract_schema,omitempty"` // 数据采集任务的结果 JSON Schema
+	Plan           bool           `json:"plan,omitempty"`           // 先将任务拆解为子目标再逐步执行，适用于步骤较多的复杂任务
+}
+
+// MessageControlRequest 取消 / 暂停 / 恢复任务
+type MessageControlRequest struct {
+	MessageID common.LongStringID `json:"message_id" binding:"required"`
 }
 
 type GetMessagesRequest struct {
@@ -27,13 +90,26 @@ type GetMessagesRequest struct {
 
 type CreateActionRequest struct {
 	MessageID  int64   `json:"message_id,string" binding:"required"`
-	ActionType string  `json:"action_type" binding:"required,oneof=goto click input select scroll wait"`
+	ActionType string  `json:"action_type" binding:"required,oneof=goto click input select scroll wait hover press_key switch_tab open_tab close_tab go_back upload_file extract screenshot"`
 	Sequence   int     `json:"sequence"`
 	URL        *string `json:"url,omitempty"`
 	Selector   *string `json:"selector,omitempty"`
 	Value      *string `json:"value,omitempty"`
 	Distance   *int    `json:"distance,omitempty"`
 	Timeout    *int    `json:"timeout,omitempty"`
+	Key        *string `json:"key,omitempty"`
+	TabIndex   *int    `json:"tab_index,omitempty"`
+	FileURL    *string `json:"file_url,omitempty"`
+}
+
+type DownloadExtractResultRequest struct {
+	MessageID int64  `form:"message_id" binding:"required"`
+	Format    string `form:"format" binding:"omitempty,oneof=json csv"`
+}
+
+type ExportMessageTraceRequest struct {
+	MessageID int64  `form:"message_id" binding:"required"`
+	Format    string `form:"format" binding:"omitempty,oneof=json zip"`
 }
 
 type GetActionsRequest struct {
@@ -47,3 +123,13 @@ type YearRequest struct {
 type LimitRequest struct {
 	Limit int `form:"limit" binding:"min=1,max=10"`
 }
+
+// DashboardRangeRequest 仪表盘统计的时间范围与粒度，未传的参数使用各接口的默认值
+//
+// start / end 支持 2006-01-02（按 timezone 解释，end 包含当天）或 RFC3339（end 不含）
+type DashboardRangeRequest struct {
+	Start       string `form:"start" label:"开始时间"`
+	End         string `form:"end" label:"结束时间"`
+	Granularity string `form:"granularity" binding:"omitempty,oneof=hour day week month" label:"统计粒度"`
+	Timezone    string `form:"timezone" label:"时区"` // IANA 时区，如 Asia/Shanghai，缺省为服务器时区
+}
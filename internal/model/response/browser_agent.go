@@ -1,37 +1,85 @@
 package response
 
-import "time"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type ConversationResponse struct {
 	ID          int64     `json:"id,string"`
 	Title       string    `json:"title"`
 	State       string    `json:"state"`
 	BrowserType string    `json:"browser_type"`
+	ModelID     int64     `json:"model_id,string"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+type DefaultModelResponse struct {
+	ModelID int64  `json:"model_id,string"`
+	Model   string `json:"model"`
+}
+
 type MessageResponse struct {
-	ID             int64     `json:"id,string"`
-	ConversationID int64     `json:"conversation_id,string"`
-	Content        string    `json:"content"`
-	State          string    `json:"state"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID             int64          `json:"id,string"`
+	ConversationID int64          `json:"conversation_id,string"`
+	Content        string         `json:"content"`
+	State          string         `json:"state"`
+	ErrorMessage   *string        `json:"error_message,omitempty"`
+	ExtractSchema  map[string]any `json:"extract_schema,omitempty"`
+	ExtractResult  any            `json:"extract_result,omitempty"`
+	WorkflowID     int64          `json:"workflow_id,string,omitempty"`
+	ScheduleID     int64          `json:"schedule_id,string,omitempty"`
+	PlanEnabled    bool           `json:"plan_enabled,omitempty"`
+	Plan           []PlanStep     `json:"plan,omitempty"`
+	PlanRevisions  int            `json:"plan_revisions,omitempty"`
+
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	Cost             decimal.Decimal `json:"cost"`
+
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PlanStep 任务计划中的一个子目标
+type PlanStep struct {
+	Goal string `json:"goal"`
+	Done bool   `json:"done"`
 }
 
 type ActionResponse struct {
-	ID            int64     `json:"id,string"`
-	MessageID     int64     `json:"message_id,string"`
-	ActionType    string    `json:"action_type"`
-	Status        string    `json:"status"`
-	URL           *string   `json:"url,omitempty"`
-	Selector      *string   `json:"selector,omitempty"`
-	Value         *string   `json:"value,omitempty"`
-	Distance      *int      `json:"distance,omitempty"`
-	Timeout       *int      `json:"timeout,omitempty"`
-	ErrorMessage  *string   `json:"error_message,omitempty"`
-	ExecutionTime *int      `json:"execution_time,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID              int64      `json:"id,string"`
+	MessageID       int64      `json:"message_id,string"`
+	ActionType      string     `json:"action_type"`
+	Status          string     `json:"status"`
+	URL             *string    `json:"url,omitempty"`
+	Selector        *string    `json:"selector,omitempty"`
+	Value           *string    `json:"value,omitempty"`
+	Distance        *int       `json:"distance,omitempty"`
+	Timeout         *int       `json:"timeout,omitempty"`
+	Key             *string    `json:"key,omitempty"`
+	TabIndex        *int       `json:"tab_index,omitempty"`
+	FileURL         *string    `json:"file_url,omitempty"`
+	Extracted       *string    `json:"extracted,omitempty"`
+	ScreenshotURL   *string    `json:"screenshot_url,omitempty"`
+	ErrorMessage    *string    `json:"error_message,omitempty"`
+	ExecutionTime   *int       `json:"execution_time,omitempty"`
+	ResultURL       *string    `json:"result_url,omitempty"`
+	Rationale       *string    `json:"rationale,omitempty"`
+	RetryCount      int        `json:"retry_count,omitempty"`
+	PolicyViolation bool       `json:"policy_violation,omitempty"`
+	ConfirmReason   *string    `json:"confirm_reason,omitempty"`
+	ConfirmDecision *string    `json:"confirm_decision,omitempty"`
+	ConfirmedBy     *int64     `json:"confirmed_by,string,omitempty"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty"`
+
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	Cost             decimal.Decimal `json:"cost"`
+
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type MessageWithActionsResponse struct {
@@ -39,23 +87,139 @@ type MessageWithActionsResponse struct {
 	Actions []ActionResponse `json:"actions"`
 }
 
+// MessageTraceResponse 任务的完整执行轨迹，可独立用于排查问题
+type MessageTraceResponse struct {
+	Message      MessageResponse     `json:"message"`
+	SystemPrompt string              `json:"system_prompt"`
+	Steps        []TraceStepResponse `json:"steps"`
+	ExportedAt   time.Time           `json:"exported_at"`
+}
+
+// TraceStepResponse 一步操作：决策时的页面状态、模型调用、解析出的操作及执行结果
+//
+// 轨迹已过保留期或未保存时只有 Action
+type TraceStepResponse struct {
+	Index        int               `json:"index"`
+	Action       ActionResponse    `json:"action"`
+	PageState    any               `json:"page_state,omitempty"` // 页面状态 JSON，被截断时为原始字符串
+	ImageURL     *string           `json:"image_url,omitempty"`
+	LLMCalls     []LLMCallResponse `json:"llm_calls,omitempty"`
+	DecisionTime int               `json:"decision_time,omitempty"` // 模型调用总耗时(毫秒)
+}
+
+type LLMCallResponse struct {
+	Model      string    `json:"model"`
+	Vision     bool      `json:"vision,omitempty"`
+	Prompt     string    `json:"prompt"`
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int       `json:"duration_ms"`
+
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	Cost             decimal.Decimal `json:"cost"`
+}
+
 type AdminSummaryResponse struct {
-	TodayTasks    int64  `json:"todayTasks"`
-	TodayGrowth   string `json:"todayGrowth"`
-	SuccessRate   int    `json:"successRate"`
-	SuccessGrowth string `json:"successGrowth"`
+	TodayTasks       int64  `json:"todayTasks"`
+	TodayGrowth      string `json:"todayGrowth"`
+	SuccessRate      int    `json:"successRate"`
+	SuccessGrowth    string `json:"successGrowth"`
+	PolicyViolations int64  `json:"policyViolations"` // 时间范围内被 URL 策略拦截的操作数，默认今日
+}
+
+type URLPolicyResponse struct {
+	ID              int64     `json:"id,string"`
+	Scope           string    `json:"scope"`
+	ScopeID         int64     `json:"scope_id,string"`
+	AllowedDomains  []string  `json:"allowed_domains"`
+	DeniedDomains   []string  `json:"denied_domains"`
+	AllowedPatterns []string  `json:"allowed_patterns"`
+	DeniedPatterns  []string  `json:"denied_patterns"`
+	BlockPrivateIP  bool      `json:"block_private_ip"`
+	Remark          string    `json:"remark"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SecretResponse 凭据信息，不返回凭据值
+type SecretResponse struct {
+	ID          int64     `json:"id,string"`
+	Name        string    `json:"name"`
+	Placeholder string    `json:"placeholder"`
+	Remark      string    `json:"remark"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type WorkflowResponse struct {
+	ID              int64                   `json:"id,string"`
+	Name            string                  `json:"name"`
+	Description     string                  `json:"description"`
+	Task            string                  `json:"task"`
+	SourceMessageID int64                   `json:"source_message_id,string"`
+	Steps           []WorkflowStepResponse  `json:"steps"`
+	Params          []WorkflowParamResponse `json:"params"`
+	CreatedAt       time.Time               `json:"created_at"`
+}
+
+type WorkflowStepResponse struct {
+	Action   string  `json:"action"`
+	URL      *string `json:"url,omitempty"`
+	Selector *string `json:"selector,omitempty"`
+	Value    *string `json:"value,omitempty"`
+	Distance *int    `json:"distance,omitempty"`
+	Timeout  *int    `json:"timeout,omitempty"`
+	Key      *string `json:"key,omitempty"`
+	TabIndex *int    `json:"tab_index,omitempty"`
+	FileURL  *string `json:"file_url,omitempty"`
+}
+
+type WorkflowParamResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+}
+
+type ScheduleResponse struct {
+	ID             int64             `json:"id,string"`
+	Name           string            `json:"name"`
+	ConversationID int64             `json:"conversation_id,string"`
+	CronExpr       string            `json:"cron_expr"`
+	Task           string            `json:"task"`
+	ExtractSchema  map[string]any    `json:"extract_schema,omitempty"`
+	WorkflowID     int64             `json:"workflow_id,string"`
+	WorkflowParams map[string]string `json:"workflow_params,omitempty"`
+	Enabled        bool              `json:"enabled"`
+	NextRunAt      *time.Time        `json:"next_run_at"`
+	LastRunAt      *time.Time        `json:"last_run_at"`
+	LastMessageID  int64             `json:"last_message_id,string"`
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// ClientResponse 在线的浏览器客户端
+type ClientResponse struct {
+	ClientID        string    `json:"client_id"`
+	ConversationID  int64     `json:"conversation_id,string"`
+	BrowserType     string    `json:"browser_type"`
+	BrowserVersion  string    `json:"browser_version"`
+	Capabilities    []string  `json:"capabilities"`
+	ConnectedAt     time.Time `json:"connected_at"`
+	Busy            bool      `json:"busy"`
+	ActiveMessageID int64     `json:"active_message_id,string"`
 }
 
 type VolumeDataResponse struct {
-	Volume    int64   `json:"volume"`
-	Growth    string  `json:"growth"`
-	ChartData []int64 `json:"chartData"`
+	Volume    int64    `json:"volume"`
+	Growth    string   `json:"growth"` // 相比上一个等长时间范围
+	XAxis     []string `json:"xAxis"`  // 每个时间桶的起点
+	ChartData []int64  `json:"chartData"`
 }
 
 type RateDataResponse struct {
-	Rate      int     `json:"rate"`
-	Growth    string  `json:"growth"`
-	ChartData []int64 `json:"chartData"`
+	Rate      int      `json:"rate"`
+	Growth    string   `json:"growth"`
+	XAxis     []string `json:"xAxis"`
+	ChartData []int64  `json:"chartData"`
 }
 
 type TotalTaskVolumeResponse struct {
@@ -79,15 +243,18 @@ type ClassificationItem struct {
 }
 
 type ActiveSessionsResponse struct {
-	Count     int64   `json:"count"`
-	Growth    string  `json:"growth"`
-	ChartData []int64 `json:"chartData"`
+	Count     int64    `json:"count"`
+	Growth    string   `json:"growth"`
+	XAxis     []string `json:"xAxis"`
+	ChartData []int64  `json:"chartData"`
 }
 
 type AnnualTaskStatsResponse struct {
-	Year        int     `json:"year"`
-	MonthlyData []int64 `json:"monthlyData"`
-	QuarterData []int64 `json:"quarterData"`
+	Year        int      `json:"year"`
+	XAxis       []string `json:"xAxis"`
+	MonthlyData []int64  `json:"monthlyData"` // 按 granularity 分桶，默认按月
+	QuarterAxis []string `json:"quarterAxis"`
+	QuarterData []int64  `json:"quarterData"`
 }
 
 type HotTaskItemResponse struct {
@@ -99,6 +266,38 @@ type HotTaskItemResponse struct {
 	Color       string `json:"color"`
 }
 
+// TokenUsageResponse 模型调用用量，费用按模型单价计算，币种为模型配置的计价币种
+type TokenUsageResponse struct {
+	PromptTokens     int64           `json:"promptTokens"`
+	CompletionTokens int64           `json:"completionTokens"`
+	TotalTokens      int64           `json:"totalTokens"`
+	Cost             decimal.Decimal `json:"cost"`
+	TaskCount        int64           `json:"taskCount"`
+	AvgTaskCost      decimal.Decimal `json:"avgTaskCost"`
+}
+
+type ConversationTokenUsageItem struct {
+	ConversationID int64  `json:"conversationId,string"`
+	Title          string `json:"title"`
+	TokenUsageResponse
+}
+
+type UserTokenUsageItem struct {
+	UserID   int64  `json:"userId,string"`
+	Username string `json:"username"`
+	TokenUsageResponse
+}
+
+type UserTokenUsageResponse struct {
+	TokenUsageResponse
+	Conversations []ConversationTokenUsageItem `json:"conversations"` // 费用最高的会话
+}
+
+type AdminTokenUsageResponse struct {
+	TokenUsageResponse
+	Users []UserTokenUsageItem `json:"users"` // 费用最高的用户
+}
+
 type UserSummaryResponse struct {
 	SessionCount  int64  `json:"sessionCount"`
 	SessionGrowth string `json:"sessionGrowth"`
@@ -120,7 +319,8 @@ type UserTaskChart struct {
 }
 
 type UserTaskTrendResponse struct {
-	Year        int     `json:"year"`
-	Growth      string  `json:"growth"`
-	MonthlyData []int64 `json:"monthlyData"`
+	Year        int      `json:"year"`
+	Growth      string   `json:"growth"`
+	XAxis       []string `json:"xAxis"`
+	MonthlyData []int64  `json:"monthlyData"` // 按 granularity 分桶，默认按月
 }
@@ -7,9 +7,12 @@ import (
 	"Art-Design-Backend/pkg/errors"
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BrowserAgentDB struct {
@@ -33,6 +36,9 @@ func (r *BrowserAgentDB) CreateConversation(ctx context.Context, conv *entity.Br
 
 func (r *BrowserAgentDB) GetConversationByID(ctx context.Context, id int64) (conv *entity.BrowserAgentConversation, err error) {
 	if err = DB(ctx, r.db).Where("id = ?", id).First(&conv).Error; err != nil {
+		if gorm.ErrRecordNotFound == err {
+			return nil, errors.NewNotFoundError("会话不存在")
+		}
 		return nil, errors.WrapDBError(err, "查询浏览器智能体会话失败")
 	}
 	return
@@ -111,7 +117,7 @@ func (r *BrowserAgentDB) CreateMessage(ctx context.Context, msg *entity.BrowserA
 func (r *BrowserAgentDB) GetMessageByID(ctx context.Context, id int64) (msg *entity.BrowserAgentMessage, err error) {
 	if err = DB(ctx, r.db).Where("id = ?", id).First(&msg).Error; err != nil {
 		if gorm.ErrRecordNotFound == err {
-			return nil, errors.NewDBError("消息不存在")
+			return nil, errors.NewNotFoundError("消息不存在")
 		}
 		return nil, errors.WrapDBError(err, "查询浏览器智能体消息失败")
 	}
@@ -127,6 +133,19 @@ func (r *BrowserAgentDB) ListMessagesByConversationID(ctx context.Context, conve
 	return
 }
 
+// ListPreviousMessagesByConversationID 查询同一会话中早于指定消息的最近 limit 条任务（按时间正序返回）
+func (r *BrowserAgentDB) ListPreviousMessagesByConversationID(ctx context.Context, conversationID, beforeID int64, limit int) (messages []*entity.BrowserAgentMessage, err error) {
+	if err = DB(ctx, r.db).Model(&entity.BrowserAgentMessage{}).
+		Where("conversation_id = ? AND id < ?", conversationID, beforeID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&messages).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询历史任务失败")
+	}
+	slices.Reverse(messages)
+	return
+}
+
 func (r *BrowserAgentDB) ListMessagesPage(ctx context.Context, queryParam *query.BrowserAgentMessage) (messages []*entity.BrowserAgentMessage, total int64, err error) {
 	db := DB(ctx, r.db).Model(&entity.BrowserAgentMessage{})
 
@@ -166,16 +185,41 @@ func (r *BrowserAgentDB) UpdateMessageState(ctx context.Context, id int64, state
 	return nil
 }
 
+// UpdateMessagePlan 保存任务计划（JSON 字符串），revised 为 true 时计划修订次数加一
+func (r *BrowserAgentDB) UpdateMessagePlan(ctx context.Context, id int64, plan string, revised bool) error {
+	updates := map[string]any{"plan": plan}
+	if revised {
+		updates["plan_revisions"] = gorm.Expr("plan_revisions + 1")
+	}
+	if err := DB(ctx, r.db).Model(&entity.BrowserAgentMessage{}).
+		Where("id = ?", id).Updates(updates).Error; err != nil {
+		return errors.WrapDBError(err, "保存任务计划失败")
+	}
+	return nil
+}
+
+// FinishMessageWithResult 仅当任务运行中时标记为完成并保存采集结果（JSON 字符串），返回是否更新成功
+func (r *BrowserAgentDB) FinishMessageWithResult(ctx context.Context, id int64, result string) (bool, error) {
+	res := DB(ctx, r.db).Model(&entity.BrowserAgentMessage{}).
+		Where("id = ? AND state = ?", id, entity.MessageStateRunning).
+		Updates(map[string]any{"state": entity.MessageStateFinished, "extract_result": result})
+	if res.Error != nil {
+		return false, errors.WrapDBError(res.Error, "保存采集结果失败")
+	}
+	return res.RowsAffected == 1, nil
+}
+
 func (r *BrowserAgentDB) MarkStaleAndFailedMessages(ctx context.Context, duration time.Duration) error {
 	cutoff := time.Now().Add(-duration)
 
 	// ===============================
-	// 1️⃣ 一个小时前的不成功 message 直接更新
+	// 1️⃣ 一个小时前开始执行的不成功 message 直接更新
 	// ===============================
+	// 排队的定时任务从下发时开始计时，避免客户端长时间离线后补发的任务刚开始就被终止
 	if err := DB(ctx, r.db).
 		Model(&entity.BrowserAgentMessage{}).
-		Where("created_at < ?", cutoff).
-		Where("state != ?", entity.MessageStateFinished). // 避免覆盖已经成功的
+		Where("COALESCE(started_at, created_at) < ?", cutoff).
+		Where("state = ?", entity.MessageStateRunning). // 只处理仍在运行的，避免覆盖已完成或已终止的
 		Update("state", entity.MessageStateError).Error; err != nil {
 		return errors.WrapDBError(err, "更新一小时前不成功的 message 失败")
 	}
@@ -193,10 +237,18 @@ func (r *BrowserAgentDB) MarkStaleAndFailedMessages(ctx context.Context, duratio
 	// 数据库直接批量更新，不拉 ID 到内存
 	if err := DB(ctx, r.db).
 		Model(&entity.BrowserAgentMessage{}).
+		Where("state = ?", entity.MessageStateRunning).
 		Where("id IN (?)", DB(ctx, r.db).
 			Model(&entity.BrowserAgentAction{}).
 			Where("id IN (?)", subQuery).
-			Where("status != ?", entity.ActionStatusSuccess).
+			// 等待用户确认的操作不算失败；超时未执行的操作已由 MarkStaleActionsFailed 标记为失败，
+			// 仍为待执行的是刚下发或暂停恢复后重新下发的操作
+			Where("status NOT IN ?", []string{
+				entity.ActionStatusSuccess,
+				entity.ActionStatusAwaitingConfirm,
+				entity.ActionStatusPending,
+				entity.ActionStatusRunning,
+			}).
 			Select("message_id"),
 		).
 		Update("state", entity.MessageStateError).Error; err != nil {
@@ -230,7 +282,7 @@ func (r *BrowserAgentDB) CreateActions(ctx context.Context, actions []*entity.Br
 func (r *BrowserAgentDB) GetActionByID(ctx context.Context, id int64) (action *entity.BrowserAgentAction, err error) {
 	if err = DB(ctx, r.db).Where("id = ?", id).First(&action).Error; err != nil {
 		if gorm.ErrRecordNotFound == err {
-			return nil, errors.NewDBError("操作不存在")
+			return nil, errors.NewNotFoundError("操作不存在")
 		}
 		return nil, errors.WrapDBError(err, "查询浏览器智能体操作失败")
 	}
@@ -240,19 +292,44 @@ func (r *BrowserAgentDB) GetActionByID(ctx context.Context, id int64) (action *e
 func (r *BrowserAgentDB) ListActionsByMessageID(ctx context.Context, messageID int64) (actions []*entity.BrowserAgentAction, err error) {
 	if err = DB(ctx, r.db).Model(&entity.BrowserAgentAction{}).
 		Where("message_id = ?", messageID).
+		Order("id ASC").
 		Find(&actions).Error; err != nil {
 		return nil, errors.WrapDBError(err, "查询浏览器智能体操作列表失败")
 	}
 	return
 }
 
-func (r *BrowserAgentDB) UpdateActionStatus(ctx context.Context, id int64, status string, errMsg *string, execTime *int) error {
+// ActionResult 客户端回传的操作执行结果，字段为 nil 时不更新
+type ActionResult struct {
+	ErrorMessage    *string
+	ExecutionTime   *int
+	ResultURL       *string
+	PageFingerprint *string
+	Extracted       *string
+	ScreenshotURL   *string
+}
+
+func (r *BrowserAgentDB) UpdateActionStatus(ctx context.Context, id int64, status string, result *ActionResult) error {
 	updates := map[string]any{"status": status}
-	if errMsg != nil {
-		updates["error_message"] = *errMsg
-	}
-	if execTime != nil {
-		updates["execution_time"] = *execTime
+	if result != nil {
+		if result.ErrorMessage != nil {
+			updates["error_message"] = *result.ErrorMessage
+		}
+		if result.ExecutionTime != nil {
+			updates["execution_time"] = *result.ExecutionTime
+		}
+		if result.ResultURL != nil {
+			updates["result_url"] = *result.ResultURL
+		}
+		if result.PageFingerprint != nil {
+			updates["page_fingerprint"] = *result.PageFingerprint
+		}
+		if result.Extracted != nil {
+			updates["extracted"] = *result.Extracted
+		}
+		if result.ScreenshotURL != nil {
+			updates["screenshot_url"] = *result.ScreenshotURL
+		}
 	}
 
 	if err := DB(ctx, r.db).Model(&entity.BrowserAgentAction{}).
@@ -262,10 +339,63 @@ func (r *BrowserAgentDB) UpdateActionStatus(ctx context.Context, id int64, statu
 	return nil
 }
 
+// ActionConfirmation 用户对敏感操作的确认记录
+type ActionConfirmation struct {
+	Decision    string
+	ConfirmedBy int64
+	ConfirmedAt time.Time
+}
+
+// ConfirmAction 记录用户确认结果并更新操作状态；edited 不为 nil 时同时覆盖操作参数
+func (r *BrowserAgentDB) ConfirmAction(ctx context.Context, id int64, status string, confirmation *ActionConfirmation, edited *entity.BrowserAgentAction) error {
+	updates := map[string]any{
+		"status":           status,
+		"confirm_decision": confirmation.Decision,
+		"confirmed_by":     confirmation.ConfirmedBy,
+		"confirmed_at":     confirmation.ConfirmedAt,
+	}
+	if edited != nil {
+		updates["action_type"] = edited.ActionType
+		updates["url"] = edited.URL
+		updates["selector"] = edited.Selector
+		updates["value"] = edited.Value
+		updates["distance"] = edited.Distance
+		updates["timeout"] = edited.Timeout
+		updates["key"] = edited.Key
+		updates["tab_index"] = edited.TabIndex
+		updates["file_url"] = edited.FileURL
+	}
+
+	// 仅处理仍在等待确认的操作，避免重复确认
+	result := DB(ctx, r.db).Model(&entity.BrowserAgentAction{}).
+		Where("id = ? AND status = ?", id, entity.ActionStatusAwaitingConfirm).
+		Updates(updates)
+	if result.Error != nil {
+		return errors.WrapDBError(result.Error, "记录操作确认结果失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewDBError("操作不在待确认状态")
+	}
+	return nil
+}
+
+// UpdateActionRetryCount 记录操作是同一步的第几次重新规划
+func (r *BrowserAgentDB) UpdateActionRetryCount(ctx context.Context, id int64, retryCount int) error {
+	if err := DB(ctx, r.db).Model(&entity.BrowserAgentAction{}).
+		Where("id = ?", id).Update("retry_count", retryCount).Error; err != nil {
+		return errors.WrapDBError(err, "更新操作重试次数失败")
+	}
+	return nil
+}
+
+// GetPendingActionsByMessageID 查询任务中已下发但尚未回传结果的操作（含待确认的操作），按下发顺序排列
 func (r *BrowserAgentDB) GetPendingActionsByMessageID(ctx context.Context, messageID int64) (actions []*entity.BrowserAgentAction, err error) {
 	if err = DB(ctx, r.db).Model(&entity.BrowserAgentAction{}).
-		Where("message_id = ? AND status = ?", messageID, entity.ActionStatusPending).
-		Order("sequence ASC").
+		Where("message_id = ? AND status IN ?", messageID, []string{
+			entity.ActionStatusPending,
+			entity.ActionStatusAwaitingConfirm,
+		}).
+		Order("id ASC").
 		Find(&actions).Error; err != nil {
 		return nil, errors.WrapDBError(err, "查询待执行操作失败")
 	}
@@ -274,29 +404,416 @@ func (r *BrowserAgentDB) GetPendingActionsByMessageID(ctx context.Context, messa
 
 func (r *BrowserAgentDB) MarkStaleActionsFailed(ctx context.Context, duration time.Duration) error {
 	cutoff := time.Now().Add(-duration)
+	// 暂停任务的未执行操作在恢复时重新下发，不标记为失败
+	pausedMessages := DB(ctx, r.db).
+		Model(&entity.BrowserAgentMessage{}).
+		Where("state = ?", entity.MessageStatePaused).
+		Select("id")
 	result := DB(ctx, r.db).Model(&entity.BrowserAgentAction{}).
-		Where("status != ?", entity.ActionStatusSuccess). // 没有成功的任务
-		Where("created_at < ?", cutoff).                  // 创建时间超过阈值
+		Where("status IN ?", []string{ // 尚未结束的操作，已拒绝/已跳过的操作保持原状态，待确认的操作等待用户处理
+			entity.ActionStatusPending,
+			entity.ActionStatusRunning,
+		}).
+		Where("created_at < ?", cutoff). // 创建时间超过阈值
+		Where("message_id NOT IN (?)", pausedMessages).
 		Update("status", entity.ActionStatusFailed)
 	return result.Error
 }
 
+// =========================
+// URL Policy
+// =========================
+
+// ListApplicableURLPolicies 查询会话策略及指定角色的策略
+func (r *BrowserAgentDB) ListApplicableURLPolicies(ctx context.Context, conversationID int64, roleIDs []int64) (policies []*entity.BrowserAgentURLPolicy, err error) {
+	queryCond := DB(ctx, r.db).
+		Where("scope = ? AND scope_id = ?", entity.URLPolicyScopeConversation, conversationID)
+	if len(roleIDs) > 0 {
+		queryCond = queryCond.Or("scope = ? AND scope_id IN ?", entity.URLPolicyScopeRole, roleIDs)
+	}
+	if err = queryCond.Find(&policies).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询 URL 策略失败")
+	}
+	return
+}
+
+// ownConversationPolicies 限定为 ownerID 所拥有会话的会话策略
+func ownConversationPolicies(db *gorm.DB, ownerID int64) *gorm.DB {
+	return db.Where("scope = ? AND scope_id IN (SELECT id FROM browser_agent_conversation WHERE created_by = ?)",
+		entity.URLPolicyScopeConversation, ownerID)
+}
+
+// ListURLPolicies 查询 URL 策略列表，ownerID 不为 0 时只查询该用户会话的策略
+func (r *BrowserAgentDB) ListURLPolicies(ctx context.Context, scope string, scopeID, ownerID int64) (policies []*entity.BrowserAgentURLPolicy, err error) {
+	queryCond := DB(ctx, r.db).Model(&entity.BrowserAgentURLPolicy{})
+	if scope != "" {
+		queryCond = queryCond.Where("scope = ?", scope)
+	}
+	if scopeID != 0 {
+		queryCond = queryCond.Where("scope_id = ?", scopeID)
+	}
+	if ownerID != 0 {
+		queryCond = ownConversationPolicies(queryCond, ownerID)
+	}
+	if err = queryCond.Order("updated_at DESC").Find(&policies).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询 URL 策略列表失败")
+	}
+	return
+}
+
+// SaveURLPolicy 保存 URL 策略，同一作用范围已存在策略时覆盖
+func (r *BrowserAgentDB) SaveURLPolicy(ctx context.Context, policy *entity.BrowserAgentURLPolicy) error {
+	if err := DB(ctx, r.db).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "scope"}, {Name: "scope_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"allowed_domains", "denied_domains", "allowed_patterns", "denied_patterns",
+			"block_private_ip", "remark", "updated_at", "updated_by",
+		}),
+	}).Create(policy).Error; err != nil {
+		return errors.WrapDBError(err, "保存 URL 策略失败")
+	}
+	return nil
+}
+
+// DeleteURLPolicy 删除 URL 策略，ownerID 不为 0 时只能删除该用户会话的策略
+func (r *BrowserAgentDB) DeleteURLPolicy(ctx context.Context, id, ownerID int64) error {
+	queryCond := DB(ctx, r.db).Where("id = ?", id)
+	if ownerID != 0 {
+		queryCond = ownConversationPolicies(queryCond, ownerID)
+	}
+	result := queryCond.Delete(&entity.BrowserAgentURLPolicy{})
+	if result.Error != nil {
+		return errors.WrapDBError(result.Error, "删除 URL 策略失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("URL 策略不存在")
+	}
+	return nil
+}
+
+// =========================
+// Secret
+// =========================
+
+func (r *BrowserAgentDB) ListSecretsByUserID(ctx context.Context, userID int64) (secrets []*entity.BrowserAgentSecret, err error) {
+	if err = DB(ctx, r.db).
+		Where("user_id = ?", userID).
+		Order("name ASC").
+		Find(&secrets).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询凭据列表失败")
+	}
+	return
+}
+
+// SaveSecret 保存凭据，同一用户同名凭据已存在时覆盖
+func (r *BrowserAgentDB) SaveSecret(ctx context.Context, secret *entity.BrowserAgentSecret) error {
+	if err := DB(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ciphertext", "remark", "updated_at", "updated_by"}),
+	}).Create(secret).Error; err != nil {
+		return errors.WrapDBError(err, "保存凭据失败")
+	}
+	return nil
+}
+
+func (r *BrowserAgentDB) DeleteSecret(ctx context.Context, userID, id int64) error {
+	if err := DB(ctx, r.db).
+		Where("id = ? AND user_id = ?", id, userID).
+		Delete(&entity.BrowserAgentSecret{}).Error; err != nil {
+		return errors.WrapDBError(err, "删除凭据失败")
+	}
+	return nil
+}
+
+// =========================
+// Workflow
+// =========================
+
+func (r *BrowserAgentDB) CreateWorkflow(ctx context.Context, workflow *entity.BrowserAgentWorkflow) error {
+	if err := DB(ctx, r.db).Create(workflow).Error; err != nil {
+		return errors.WrapDBError(err, "创建工作流失败")
+	}
+	return nil
+}
+
+func (r *BrowserAgentDB) GetWorkflowByID(ctx context.Context, id int64) (workflow *entity.BrowserAgentWorkflow, err error) {
+	if err = DB(ctx, r.db).Where("id = ?", id).First(&workflow).Error; err != nil {
+		if gorm.ErrRecordNotFound == err {
+			return nil, errors.NewNotFoundError("工作流不存在")
+		}
+		return nil, errors.WrapDBError(err, "查询工作流失败")
+	}
+	return
+}
+
+func (r *BrowserAgentDB) ListWorkflowsByUserID(ctx context.Context, userID int64) (workflows []*entity.BrowserAgentWorkflow, err error) {
+	if err = DB(ctx, r.db).
+		Where("created_by = ?", userID).
+		Order("created_at DESC").
+		Find(&workflows).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询工作流列表失败")
+	}
+	return
+}
+
+func (r *BrowserAgentDB) DeleteWorkflow(ctx context.Context, userID, id int64) error {
+	result := DB(ctx, r.db).
+		Where("id = ? AND created_by = ?", id, userID).
+		Delete(&entity.BrowserAgentWorkflow{})
+	if result.Error != nil {
+		return errors.WrapDBError(result.Error, "删除工作流失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("工作流不存在")
+	}
+	return nil
+}
+
+// UpdateMessageWorkflowStep 记录工作流回放进度（下一步要执行的步骤序号）
+func (r *BrowserAgentDB) UpdateMessageWorkflowStep(ctx context.Context, id int64, step int) error {
+	if err := DB(ctx, r.db).Model(&entity.BrowserAgentMessage{}).
+		Where("id = ?", id).Update("workflow_step", step).Error; err != nil {
+		return errors.WrapDBError(err, "更新工作流回放进度失败")
+	}
+	return nil
+}
+
+// =========================
+// Schedule
+// =========================
+
+func (r *BrowserAgentDB) CreateSchedule(ctx context.Context, schedule *entity.BrowserAgentSchedule) error {
+	if err := DB(ctx, r.db).Create(schedule).Error; err != nil {
+		return errors.WrapDBError(err, "创建定时任务失败")
+	}
+	return nil
+}
+
+func (r *BrowserAgentDB) UpdateSchedule(ctx context.Context, schedule *entity.BrowserAgentSchedule) error {
+	if err := DB(ctx, r.db).Save(schedule).Error; err != nil {
+		return errors.WrapDBError(err, "更新定时任务失败")
+	}
+	return nil
+}
+
+func (r *BrowserAgentDB) GetScheduleByID(ctx context.Context, id int64) (schedule *entity.BrowserAgentSchedule, err error) {
+	if err = DB(ctx, r.db).Where("id = ?", id).First(&schedule).Error; err != nil {
+		if gorm.ErrRecordNotFound == err {
+			return nil, errors.NewNotFoundError("定时任务不存在")
+		}
+		return nil, errors.WrapDBError(err, "查询定时任务失败")
+	}
+	return
+}
+
+func (r *BrowserAgentDB) ListSchedulesByUserID(ctx context.Context, userID int64) (schedules []*entity.BrowserAgentSchedule, err error) {
+	if err = DB(ctx, r.db).
+		Where("created_by = ?", userID).
+		Order("created_at DESC").
+		Find(&schedules).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询定时任务列表失败")
+	}
+	return
+}
+
+func (r *BrowserAgentDB) DeleteSchedule(ctx context.Context, userID, id int64) error {
+	result := DB(ctx, r.db).
+		Where("id = ? AND created_by = ?", id, userID).
+		Delete(&entity.BrowserAgentSchedule{})
+	if result.Error != nil {
+		return errors.WrapDBError(result.Error, "删除定时任务失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("定时任务不存在")
+	}
+	return nil
+}
+
+// ListDueSchedules 查询已启用且到达执行时间的定时任务
+func (r *BrowserAgentDB) ListDueSchedules(ctx context.Context, now time.Time) (schedules []*entity.BrowserAgentSchedule, err error) {
+	if err = DB(ctx, r.db).
+		Where("enabled = ? AND next_run_at <= ?", true, now).
+		Find(&schedules).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询到期定时任务失败")
+	}
+	return
+}
+
+// ClaimScheduleRun 以下次执行时间为条件推进定时任务，返回是否抢占成功
+//
+// 多实例部署时同一次执行只有一个实例能抢占成功
+func (r *BrowserAgentDB) ClaimScheduleRun(ctx context.Context, id int64, dueAt, nextRunAt, now time.Time) (bool, error) {
+	res := DB(ctx, r.db).Model(&entity.BrowserAgentSchedule{}).
+		Where("id = ? AND next_run_at = ?", id, dueAt).
+		Updates(map[string]any{"next_run_at": nextRunAt, "last_run_at": now})
+	if res.Error != nil {
+		return false, errors.WrapDBError(res.Error, "更新定时任务执行时间失败")
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *BrowserAgentDB) UpdateScheduleLastMessage(ctx context.Context, id, messageID int64) error {
+	if err := DB(ctx, r.db).Model(&entity.BrowserAgentSchedule{}).
+		Where("id = ?", id).Update("last_message_id", messageID).Error; err != nil {
+		return errors.WrapDBError(err, "更新定时任务最近执行记录失败")
+	}
+	return nil
+}
+
+// ListQueuedMessagesByUserID 查询用户各会话中在 since 之后生成、仍在排队的任务（按时间正序）
+func (r *BrowserAgentDB) ListQueuedMessagesByUserID(ctx context.Context, userID int64, since time.Time) (messages []*entity.BrowserAgentMessage, err error) {
+	if err = DB(ctx, r.db).Table("browser_agent_message m").
+		Select("m.*").
+		Joins("JOIN browser_agent_conversation c ON m.conversation_id = c.id").
+		Where("c.created_by = ? AND m.state = ? AND m.created_at >= ?", userID, entity.MessageStateQueued, since).
+		Order("m.id ASC").
+		Find(&messages).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询排队任务失败")
+	}
+	return
+}
+
+// CompareAndUpdateMessageState 仅当任务处于 from 状态时更新为 to，返回是否更新成功
+func (r *BrowserAgentDB) CompareAndUpdateMessageState(ctx context.Context, id int64, from, to string) (bool, error) {
+	res := DB(ctx, r.db).Model(&entity.BrowserAgentMessage{}).
+		Where("id = ? AND state = ?", id, from).
+		Update("state", to)
+	if res.Error != nil {
+		return false, errors.WrapDBError(res.Error, "更新任务状态失败")
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// StartQueuedMessage 仅当任务处于排队状态时更新为运行中并记录开始时间，返回是否更新成功
+func (r *BrowserAgentDB) StartQueuedMessage(ctx context.Context, id int64) (bool, error) {
+	res := DB(ctx, r.db).Model(&entity.BrowserAgentMessage{}).
+		Where("id = ? AND state = ?", id, entity.MessageStateQueued).
+		Updates(map[string]any{"state": entity.MessageStateRunning, "started_at": time.Now()})
+	if res.Error != nil {
+		return false, errors.WrapDBError(res.Error, "更新任务状态失败")
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// TransitionMessageState 仅当任务处于 from 中任一状态时更新为 to，返回是否更新成功
+func (r *BrowserAgentDB) TransitionMessageState(ctx context.Context, id int64, from []string, to, errMsg string) (bool, error) {
+	updates := map[string]any{"state": to}
+	if errMsg != "" {
+		updates["error_message"] = errMsg
+	}
+	res := DB(ctx, r.db).Model(&entity.BrowserAgentMessage{}).
+		Where("id = ? AND state IN ?", id, from).
+		Updates(updates)
+	if res.Error != nil {
+		return false, errors.WrapDBError(res.Error, "更新任务状态失败")
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// AddMessageUsage 累加任务的 token 用量与费用
+func (r *BrowserAgentDB) AddMessageUsage(ctx context.Context, id int64, promptTokens, completionTokens int, cost decimal.Decimal) error {
+	if err := DB(ctx, r.db).Model(&entity.BrowserAgentMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"prompt_tokens":     gorm.Expr("prompt_tokens + ?", promptTokens),
+			"completion_tokens": gorm.Expr("completion_tokens + ?", completionTokens),
+			"cost":              gorm.Expr("cost + ?", cost),
+		}).Error; err != nil {
+		return errors.WrapDBError(err, "累计任务 token 用量失败")
+	}
+	return nil
+}
+
+// SkipOpenActions 将任务中尚未结束的操作标记为已跳过
+func (r *BrowserAgentDB) SkipOpenActions(ctx context.Context, messageID int64) error {
+	if err := DB(ctx, r.db).Model(&entity.BrowserAgentAction{}).
+		Where("message_id = ? AND status IN ?", messageID, []string{
+			entity.ActionStatusPending,
+			entity.ActionStatusRunning,
+			entity.ActionStatusAwaitingConfirm,
+		}).
+		Update("status", entity.ActionStatusSkipped).Error; err != nil {
+		return errors.WrapDBError(err, "更新未结束操作失败")
+	}
+	return nil
+}
+
+// ExpireQueuedMessages 将 cutoff 之前生成仍未下发的排队任务标记为过期
+func (r *BrowserAgentDB) ExpireQueuedMessages(ctx context.Context, cutoff time.Time) error {
+	if err := DB(ctx, r.db).Model(&entity.BrowserAgentMessage{}).
+		Where("state = ? AND created_at < ?", entity.MessageStateQueued, cutoff).
+		Updates(map[string]any{
+			"state":         entity.MessageStateExpired,
+			"error_message": "客户端长时间离线，定时任务已过期",
+		}).Error; err != nil {
+		return errors.WrapDBError(err, "更新过期排队任务失败")
+	}
+	return nil
+}
+
+// =========================
+// Action Trace
+// =========================
+
+func (r *BrowserAgentDB) CreateActionTrace(ctx context.Context, trace *entity.BrowserAgentActionTrace) error {
+	if err := DB(ctx, r.db).Create(trace).Error; err != nil {
+		return errors.WrapDBError(err, "保存操作决策轨迹失败")
+	}
+	return nil
+}
+
+func (r *BrowserAgentDB) ListActionTracesByMessageID(ctx context.Context, messageID int64) (traces []*entity.BrowserAgentActionTrace, err error) {
+	if err = DB(ctx, r.db).
+		Where("message_id = ?", messageID).
+		Order("id ASC").
+		Find(&traces).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询操作决策轨迹失败")
+	}
+	return
+}
+
+// DeleteActionTracesBefore 删除 cutoff 之前保存的决策轨迹，返回删除条数
+func (r *BrowserAgentDB) DeleteActionTracesBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res := DB(ctx, r.db).Where("created_at < ?", cutoff).Delete(&entity.BrowserAgentActionTrace{})
+	if res.Error != nil {
+		return 0, errors.WrapDBError(res.Error, "清理过期决策轨迹失败")
+	}
+	return res.RowsAffected, nil
+}
+
+func (r *BrowserAgentDB) DeleteActionTracesByMessageIDList(ctx context.Context, messageIDList []int64) error {
+	if err := DB(ctx, r.db).
+		Where("message_id IN (?)", messageIDList).
+		Delete(&entity.BrowserAgentActionTrace{}).Error; err != nil {
+		return errors.WrapDBError(err, "批量删除操作决策轨迹失败")
+	}
+	return nil
+}
+
 // =========================
 // Dashboard - 用户维度统计
 // =========================
 
+// whereTimeRange 限定 column 在 [startTime, endTime) 内，零值表示不限
+func whereTimeRange(db *gorm.DB, column string, startTime, endTime time.Time) *gorm.DB {
+	if !startTime.IsZero() {
+		db = db.Where(column+" >= ?", startTime)
+	}
+	if !endTime.IsZero() {
+		db = db.Where(column+" < ?", endTime)
+	}
+	return db
+}
+
 func (r *BrowserAgentDB) CountConversationsByTimeRange(ctx context.Context, userID int64, startTime, endTime time.Time) (int64, error) {
 	var count int64
 	queryCond := DB(ctx, r.db).
 		Table(tablename.BrowserAgentConversationTableName).
 		Where("created_by = ?", userID)
-	if !startTime.IsZero() {
-		queryCond = queryCond.Where("created_at >= ?", startTime)
-	}
-	if !endTime.IsZero() {
-		queryCond = queryCond.Where("created_at < ?", endTime)
+	if err := whereTimeRange(queryCond, "created_at", startTime, endTime).Count(&count).Error; err != nil {
+		return 0, errors.WrapDBError(err, "统计会话数失败")
 	}
-	return count, queryCond.Count(&count).Error
+	return count, nil
 }
 
 func (r *BrowserAgentDB) CountMessagesByTimeRange(ctx context.Context, userID int64, startTime, endTime time.Time) (int64, error) {
@@ -304,13 +821,10 @@ func (r *BrowserAgentDB) CountMessagesByTimeRange(ctx context.Context, userID in
 	queryCond := DB(ctx, r.db).Table("browser_agent_message m").
 		Joins("JOIN browser_agent_conversation c ON m.conversation_id = c.id").
 		Where("c.created_by = ?", userID)
-	if !startTime.IsZero() {
-		queryCond = queryCond.Where("m.created_at >= ?", startTime)
-	}
-	if !endTime.IsZero() {
-		queryCond = queryCond.Where("m.created_at < ?", endTime)
+	if err := whereTimeRange(queryCond, "m.created_at", startTime, endTime).Count(&count).Error; err != nil {
+		return 0, errors.WrapDBError(err, "统计任务数失败")
 	}
-	return count, queryCond.Count(&count).Error
+	return count, nil
 }
 
 func (r *BrowserAgentDB) CountActionsByTimeRange(ctx context.Context, userID int64, status string, startTime, endTime time.Time) (int64, error) {
@@ -322,41 +836,10 @@ func (r *BrowserAgentDB) CountActionsByTimeRange(ctx context.Context, userID int
 	if status != "" {
 		queryCond = queryCond.Where("a.status = ?", status)
 	}
-	if !startTime.IsZero() {
-		queryCond = queryCond.Where("a.created_at >= ?", startTime)
+	if err := whereTimeRange(queryCond, "a.created_at", startTime, endTime).Count(&count).Error; err != nil {
+		return 0, errors.WrapDBError(err, "统计操作数失败")
 	}
-	if !endTime.IsZero() {
-		queryCond = queryCond.Where("a.created_at < ?", endTime)
-	}
-	return count, queryCond.Count(&count).Error
-}
-
-func (r *BrowserAgentDB) CountMessagesByDays(ctx context.Context, userID int64, days []time.Time) ([]int64, error) {
-	result := make([]int64, len(days))
-	for i, day := range days {
-		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
-		dayEnd := dayStart.Add(24 * time.Hour)
-		count, err := r.CountMessagesByTimeRange(ctx, userID, dayStart, dayEnd)
-		if err != nil {
-			return nil, err
-		}
-		result[i] = count
-	}
-	return result, nil
-}
-
-func (r *BrowserAgentDB) CountMessagesByMonths(ctx context.Context, userID int64, year int) ([]int64, error) {
-	result := make([]int64, 12)
-	for month := 1; month <= 12; month++ {
-		monthStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-		monthEnd := monthStart.AddDate(0, 1, 0)
-		count, err := r.CountMessagesByTimeRange(ctx, userID, monthStart, monthEnd)
-		if err != nil {
-			return nil, err
-		}
-		result[month-1] = count
-	}
-	return result, nil
+	return count, nil
 }
 
 func (r *BrowserAgentDB) GetRecentConversations(ctx context.Context, userID int64, limit int) ([]*entity.BrowserAgentConversation, error) {
@@ -400,13 +883,10 @@ func (r *BrowserAgentDB) CountAllConversationsByTimeRange(ctx context.Context, s
 func (r *BrowserAgentDB) CountAllMessagesByTimeRange(ctx context.Context, startTime, endTime time.Time) (int64, error) {
 	var count int64
 	queryCond := DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName)
-	if !startTime.IsZero() {
-		queryCond = queryCond.Where("created_at >= ?", startTime)
-	}
-	if !endTime.IsZero() {
-		queryCond = queryCond.Where("created_at < ?", endTime)
+	if err := whereTimeRange(queryCond, "created_at", startTime, endTime).Count(&count).Error; err != nil {
+		return 0, errors.WrapDBError(err, "统计任务数失败")
 	}
-	return count, queryCond.Count(&count).Error
+	return count, nil
 }
 
 func (r *BrowserAgentDB) CountAllActionsByTimeRange(ctx context.Context, status string, startTime, endTime time.Time) (int64, error) {
@@ -415,13 +895,38 @@ func (r *BrowserAgentDB) CountAllActionsByTimeRange(ctx context.Context, status
 	if status != "" {
 		queryCond = queryCond.Where("status = ?", status)
 	}
-	if !startTime.IsZero() {
-		queryCond = queryCond.Where("created_at >= ?", startTime)
+	if err := whereTimeRange(queryCond, "created_at", startTime, endTime).Count(&count).Error; err != nil {
+		return 0, errors.WrapDBError(err, "统计操作数失败")
 	}
-	if !endTime.IsZero() {
-		queryCond = queryCond.Where("created_at < ?", endTime)
+	return count, nil
+}
+
+func (r *BrowserAgentDB) CountPolicyViolationsByTimeRange(ctx context.Context, startTime, endTime time.Time) (int64, error) {
+	var count int64
+	queryCond := DB(ctx, r.db).Table(tablename.BrowserAgentActionTableName).
+		Where("policy_violation = ?", true)
+	if err := whereTimeRange(queryCond, "created_at", startTime, endTime).Count(&count).Error; err != nil {
+		return 0, errors.WrapDBError(err, "统计策略拦截次数失败")
 	}
-	return count, queryCond.Count(&count).Error
+	return count, nil
+}
+
+// ListPolicyViolationsPage 分页查询被 URL 策略拦截的操作
+func (r *BrowserAgentDB) ListPolicyViolationsPage(ctx context.Context, queryParam *query.BrowserAgentPolicyViolation) (actions []*entity.BrowserAgentAction, total int64, err error) {
+	queryCond := DB(ctx, r.db).Model(&entity.BrowserAgentAction{}).
+		Where("policy_violation = ?", true)
+	if queryParam.MessageID != 0 {
+		queryCond = queryCond.Where("message_id = ?", int64(queryParam.MessageID))
+	}
+
+	if err = queryCond.Count(&total).Error; err != nil {
+		return nil, 0, errors.WrapDBError(err, "统计策略拦截记录失败")
+	}
+
+	if err = queryCond.Scopes(queryParam.Paginate()).Order("created_at DESC").Find(&actions).Error; err != nil {
+		return nil, 0, errors.WrapDBError(err, "查询策略拦截记录失败")
+	}
+	return
 }
 
 func (r *BrowserAgentDB) CountTotalConversations(ctx context.Context) (int64, error) {
@@ -430,12 +935,13 @@ func (r *BrowserAgentDB) CountTotalConversations(ctx context.Context) (int64, er
 	return count, err
 }
 
-func (r *BrowserAgentDB) GetMessageStateStats(ctx context.Context) (map[string]int64, error) {
+func (r *BrowserAgentDB) GetMessageStateStats(ctx context.Context, startTime, endTime time.Time) (map[string]int64, error) {
 	var items []struct {
 		State string
 		Count int64
 	}
-	err := DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName).
+	queryCond := DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName)
+	err := whereTimeRange(queryCond, "created_at", startTime, endTime).
 		Select("state, COUNT(*) as count").
 		Group("state").
 		Scan(&items).Error
@@ -449,228 +955,23 @@ func (r *BrowserAgentDB) GetMessageStateStats(ctx context.Context) (map[string]i
 	return result, nil
 }
 
-func (r *BrowserAgentDB) CountAllMessagesByMonths(ctx context.Context, months []time.Time) (thisYear []int64, lastYear []int64, err error) {
-	thisYear = make([]int64, len(months))
-	lastYear = make([]int64, len(months))
-	for i, month := range months {
-		monthStart := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
-		monthEnd := monthStart.AddDate(0, 1, 0)
-
-		var count int64
-		if err = DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName).
-			Where("created_at >= ? AND created_at < ?", monthStart, monthEnd).
-			Count(&count).Error; err != nil {
-			return
-		}
-		thisYear[i] = count
-
-		lastYearStart := monthStart.AddDate(-1, 0, 0)
-		lastYearEnd := monthEnd.AddDate(-1, 0, 0)
-		if err = DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName).
-			Where("created_at >= ? AND created_at < ?", lastYearStart, lastYearEnd).
-			Count(&count).Error; err != nil {
-			return nil, nil, err
-		}
-		lastYear[i] = count
-	}
-	return
-}
-
-func (r *BrowserAgentDB) CountAllMessagesByDays(ctx context.Context, days []time.Time) ([]int64, error) {
-	result := make([]int64, len(days))
-	for i, day := range days {
-		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
-		dayEnd := dayStart.Add(24 * time.Hour)
-		var count int64
-		if err := DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName).
-			Where("created_at >= ? AND created_at < ?", dayStart, dayEnd).
-			Count(&count).Error; err != nil {
-			return nil, err
-		}
-		result[i] = count
-	}
-	return result, nil
-}
-
-type UserRankingRow struct {
-	UserID    int64
-	Username  string
-	TaskCount int64
-}
-
-func (r *BrowserAgentDB) GetUserTaskRanking(ctx context.Context, limit int) ([]UserRankingRow, error) {
-	var results []UserRankingRow
-	err := DB(ctx, r.db).
-		Table(`"user" AS u`).
-		Select(`u.id AS user_id, u.username, COUNT(m.id) AS task_count`).
-		Joins(`JOIN browser_agent_conversation c ON c.created_by = u.id`).
-		Joins(`JOIN browser_agent_message m ON m.conversation_id = c.id`).
-		Group(`u.id, u.username`).
-		Order(`task_count DESC`).
-		Limit(limit).
-		Scan(&results).Error
-	return results, err
-}
-
-type HotTaskRow struct {
-	Content string
-	Count   int64
-}
-
-func (r *BrowserAgentDB) GetHotTaskContents(ctx context.Context, limit int) ([]HotTaskRow, error) {
-	var results []HotTaskRow
-	err := DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName).
-		Select("content, COUNT(*) as count").
-		Group("content").
-		Order("count DESC").
-		Limit(limit).
-		Scan(&results).Error
-	return results, err
-}
-
-func (r *BrowserAgentDB) CountAllActionsByDays(ctx context.Context, days []time.Time) ([]int64, error) {
-	result := make([]int64, len(days))
-	for i, day := range days {
-		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
-		dayEnd := dayStart.Add(24 * time.Hour)
-		var count int64
-		if err := DB(ctx, r.db).Table(tablename.BrowserAgentActionTableName).
-			Where("created_at >= ? AND created_at < ?", dayStart, dayEnd).
-			Count(&count).Error; err != nil {
-			return nil, err
-		}
-		result[i] = count
-	}
-	return result, nil
-}
-
-type DayCount struct {
-	Day     time.Time
-	Total   int64
-	Success int64
-}
-
-func (r *BrowserAgentDB) CountAllByDays(ctx context.Context, days []time.Time) ([]DayCount, error) {
-	start := days[0]
-	end := days[len(days)-1].Add(24 * time.Hour)
-
-	var rows []DayCount
-	err := DB(ctx, r.db).
-		Table(tablename.BrowserAgentActionTableName).
-		Select(`
-			DATE(created_at) as day,
-			COUNT(*) as total,
-			SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END) as success
-		`).
-		Where("created_at >= ? AND created_at < ?", start, end).
-		Group("DATE(created_at)").
-		Scan(&rows).Error
-
-	return rows, err
-}
-
-func (r *BrowserAgentDB) CountAllMessageSuccessRateByDays(ctx context.Context, days []time.Time) ([]DayCount, error) {
-	start := days[0]
-	end := days[len(days)-1].Add(24 * time.Hour)
-
-	var rows []DayCount
-	err := DB(ctx, r.db).
-		Table(tablename.BrowserAgentMessageTableName).
-		Select(`
-			DATE(created_at) as day,
-			COUNT(*) as total,
-			SUM(CASE WHEN state = 'finished' THEN 1 ELSE 0 END) as success
-		`).
-		Where("created_at >= ? AND created_at < ?", start, end).
-		Group("DATE(created_at)").
-		Scan(&rows).Error
-
-	return rows, err
-}
-
-func (r *BrowserAgentDB) CountAllConversationsByDays(
-	ctx context.Context,
-	days []time.Time,
-) ([]int64, error) {
-
-	if len(days) == 0 {
-		return nil, nil
-	}
-
-	// 计算整体时间范围
-	start := time.Date(days[0].Year(), days[0].Month(), days[0].Day(), 0, 0, 0, 0, days[0].Location())
-	end := time.Date(days[len(days)-1].Year(), days[len(days)-1].Month(), days[len(days)-1].Day(), 0, 0, 0, 0, days[len(days)-1].Location()).
-		Add(24 * time.Hour)
-
-	// 查询结果结构
-	type dayCount struct {
-		Day   time.Time
-		Count int64
-	}
-
-	var rows []dayCount
-
-	err := DB(ctx, r.db).
-		Table(tablename.BrowserAgentConversationTableName).
-		Select("DATE(created_at) as day, COUNT(*) as count").
-		Where("created_at >= ? AND created_at < ?", start, end).
-		Group("DATE(created_at)").
-		Scan(&rows).Error
-
-	if err != nil {
-		return nil, err
-	}
-
-	// 构建 map 方便按天填充
-	countMap := make(map[string]int64, len(rows))
-	for _, row := range rows {
-		key := row.Day.Format("2006-01-02")
-		countMap[key] = row.Count
-	}
-
-	// 按传入 days 顺序填充结果（保证顺序稳定）
-	result := make([]int64, len(days))
-	for i, day := range days {
-		key := day.Format("2006-01-02")
-		result[i] = countMap[key]
-	}
-
-	return result, nil
-}
-
-func (r *BrowserAgentDB) CountAllMessagesByYearWithQuarters(ctx context.Context, year int) (monthly []int64, quarterly []int64, err error) {
-	monthly = make([]int64, 12)
-	quarterly = make([]int64, 4)
-
-	for month := 1; month <= 12; month++ {
-		monthStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-		monthEnd := monthStart.AddDate(0, 1, 0)
-		var count int64
-		if err = DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName).
-			Where("created_at >= ? AND created_at < ?", monthStart, monthEnd).
-			Count(&count).Error; err != nil {
-			return
-		}
-		monthly[month-1] = count
-		quarterly[(month-1)/3] += count
-	}
-	return
-}
-
 type TaskClassificationRow struct {
 	Content string
 	Count   int64
 }
 
-func (r *BrowserAgentDB) GetTaskClassification(ctx context.Context) ([]TaskClassificationRow, error) {
+func (r *BrowserAgentDB) GetTaskClassification(ctx context.Context, startTime, endTime time.Time) ([]TaskClassificationRow, error) {
 	var results []TaskClassificationRow
-	err := DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName).
+	queryCond := DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName)
+	if err := whereTimeRange(queryCond, "created_at", startTime, endTime).
 		Select("content, COUNT(*) as count").
 		Group("content").
 		Order("count DESC").
 		Limit(10).
-		Scan(&results).Error
-	return results, err
+		Scan(&results).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询任务分类统计失败")
+	}
+	return results, nil
 }
 
 type HotTaskDetailRow struct {
@@ -684,9 +985,11 @@ type HotTaskDetailRow struct {
 // GetHotTasksWithDetails 查询热门任务及执行详情
 //
 // 返回结果包含每条任务内容、执行次数、平均执行时间、成功次数以及总动作数
+// startTime, endTime: 任务创建时间范围，零值表示不限
 // limit: 返回条数上限
 func (r *BrowserAgentDB) GetHotTasksWithDetails(
 	ctx context.Context,
+	startTime, endTime time.Time,
 	limit int,
 ) ([]HotTaskDetailRow, error) {
 
@@ -699,8 +1002,8 @@ func (r *BrowserAgentDB) GetHotTasksWithDetails(
 	//  - COALESCE(AVG(a.execution_time), 0) as avg_exec_time: 平均执行时间，如果没有动作则为 0
 	//  - SUM(CASE WHEN a.status = 'success' THEN 1 ELSE 0 END) as success_count: 成功动作数
 	//  - COUNT(a.id) as total_actions: 总动作数（包括失败动作）
-	err := DB(ctx, r.db).
-		Table(tablename.BrowserAgentMessageTableName + " AS m").
+	queryCond := DB(ctx, r.db).Table(tablename.BrowserAgentMessageTableName + " AS m")
+	err := whereTimeRange(queryCond, "m.created_at", startTime, endTime).
 		Select(`
 			m.content,
 			COUNT(DISTINCT m.id) AS count,
@@ -713,61 +1016,181 @@ func (r *BrowserAgentDB) GetHotTasksWithDetails(
 		Order("count DESC").
 		Limit(limit).
 		Scan(&results).Error
+	if err != nil {
+		return nil, errors.WrapDBError(err, "查询热门任务失败")
+	}
+	return results, nil
+}
 
-	return results, err
+// =========================
+// Dashboard - 时间分桶统计
+// =========================
+
+// StatsRange 分桶统计的时间范围 [Start, End)
+//
+// Granularity 为 date_trunc 的粒度（hour / day / week / month / quarter），Timezone 为分桶使用的 IANA 时区
+type StatsRange struct {
+	Start       time.Time
+	End         time.Time
+	Granularity string
+	Timezone    string
 }
 
-func (r *BrowserAgentDB) CountConversationsByDays(ctx context.Context, userID int64, days []time.Time) ([]int64, error) {
-	result := make([]int64, len(days))
-	for i, day := range days {
-		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
-		dayEnd := dayStart.Add(24 * time.Hour)
-		var count int64
-		if err := DB(ctx, r.db).Table(tablename.BrowserAgentConversationTableName).
-			Where("created_by = ? AND created_at >= ? AND created_at < ?", userID, dayStart, dayEnd).
-			Count(&count).Error; err != nil {
-			return nil, err
-		}
-		result[i] = count
+// TimezoneExists 判断数据库是否支持该时区名称，用于分桶统计前校验请求的时区
+func (r *BrowserAgentDB) TimezoneExists(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	if err := DB(ctx, r.db).
+		Raw("SELECT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = ?)", name).
+		Scan(&exists).Error; err != nil {
+		return false, errors.WrapDBError(err, "查询数据库时区失败")
 	}
-	return result, nil
+	return exists, nil
 }
 
-func (r *BrowserAgentDB) CountActionsSuccessRateByDays(
-	ctx context.Context, userID int64, days []time.Time,
-) (result []int64, totalSum int64, successSum int64, err error) {
-	result = make([]int64, len(days))
+// BucketCount 单个时间桶的统计结果，Bucket 为桶起点在 Timezone 时区下的本地时间（不带时区）
+type BucketCount struct {
+	Bucket  time.Time
+	Total   int64
+	Success int64
+}
 
-	for i, day := range days {
-		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
-		dayEnd := dayStart.Add(24 * time.Hour)
+// countByBucket 按 column 所在时间桶统计记录数与满足 successCond 的记录数，不返回空桶
+//
+// created_at 为不带时区的 timestamp，按连接时区（TimeZone=Asia/Shanghai）写入，先转为 timestamptz 再换算到目标时区分桶
+func countByBucket(db *gorm.DB, column, successCond string, sr *StatsRange) ([]BucketCount, error) {
+	if successCond == "" {
+		successCond = "FALSE"
+	}
+	var rows []BucketCount
+	err := db.
+		Select(fmt.Sprintf(`
+			date_trunc(?, timezone(?, %s::timestamptz)) AS bucket,
+			COUNT(*) AS total,
+			COALESCE(SUM(CASE WHEN %s THEN 1 ELSE 0 END), 0) AS success
+		`, column, successCond), sr.Granularity, sr.Timezone).
+		Where(column+" >= ? AND "+column+" < ?", sr.Start, sr.End).
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// CountMessagesByBucket 按时间桶统计任务数与完成数，userID 为 0 时统计全部用户
+func (r *BrowserAgentDB) CountMessagesByBucket(ctx context.Context, userID int64, sr *StatsRange) ([]BucketCount, error) {
+	db := DB(ctx, r.db).Table("browser_agent_message m")
+	if userID != 0 {
+		db = db.Joins("JOIN browser_agent_conversation c ON m.conversation_id = c.id").
+			Where("c.created_by = ?", userID)
+	}
+	rows, err := countByBucket(db, "m.created_at", "m.state = 'finished'", sr)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "按时间统计任务数失败")
+	}
+	return rows, nil
+}
 
-		var total, success int64
-		db := DB(ctx, r.db).
-			Table(fmt.Sprintf("%s a", tablename.BrowserAgentActionTableName)).
-			Joins("JOIN browser_agent_message m ON a.message_id = m.id").
+// CountActionsByBucket 按时间桶统计操作数与成功数，userID 为 0 时统计全部用户
+func (r *BrowserAgentDB) CountActionsByBucket(ctx context.Context, userID int64, sr *StatsRange) ([]BucketCount, error) {
+	db := DB(ctx, r.db).Table("browser_agent_action a")
+	if userID != 0 {
+		db = db.Joins("JOIN browser_agent_message m ON a.message_id = m.id").
 			Joins("JOIN browser_agent_conversation c ON m.conversation_id = c.id").
-			Where("c.created_by = ? AND a.created_at >= ? AND a.created_at < ?", userID, dayStart, dayEnd)
+			Where("c.created_by = ?", userID)
+	}
+	rows, err := countByBucket(db, "a.created_at", "a.status = 'success'", sr)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "按时间统计操作数失败")
+	}
+	return rows, nil
+}
 
-		// 总动作数
-		if err := db.Count(&total).Error; err != nil {
-			return nil, 0, 0, err
-		}
-		// 成功动作数
-		if err := db.Where("a.status = ?", "success").Count(&success).Error; err != nil {
-			return nil, 0, 0, err
-		}
+// CountConversationsByBucket 按时间桶统计新建会话数，userID 为 0 时统计全部用户
+func (r *BrowserAgentDB) CountConversationsByBucket(ctx context.Context, userID int64, sr *StatsRange) ([]BucketCount, error) {
+	db := DB(ctx, r.db).Table("browser_agent_conversation c")
+	if userID != 0 {
+		db = db.Where("c.created_by = ?", userID)
+	}
+	rows, err := countByBucket(db, "c.created_at", "", sr)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "按时间统计会话数失败")
+	}
+	return rows, nil
+}
 
-		if total > 0 {
-			result[i] = success * 100 / total
-		} else {
-			result[i] = 0
-		}
+// =========================
+// Dashboard - 模型用量统计
+// =========================
+
+// TokenUsageRow 任务累计的 token 用量与费用
+type TokenUsageRow struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	Cost             decimal.Decimal
+	TaskCount        int64
+}
 
-		// 累加整周总数
-		totalSum += total
-		successSum += success
+type ConversationTokenUsageRow struct {
+	ConversationID int64
+	Title          string
+	TokenUsageRow
+}
+
+type UserTokenUsageRow struct {
+	UserID   int64
+	Username string
+	TokenUsageRow
+}
+
+const tokenUsageColumns = `COALESCE(SUM(m.prompt_tokens), 0) AS prompt_tokens,
+	COALESCE(SUM(m.completion_tokens), 0) AS completion_tokens,
+	COALESCE(SUM(m.cost), 0) AS cost,
+	COUNT(m.id) AS task_count`
+
+// SumTokenUsage 汇总时间范围内任务的 token 用量与费用，userID 为 0 时统计全部用户
+func (r *BrowserAgentDB) SumTokenUsage(ctx context.Context, userID int64, startTime, endTime time.Time) (*TokenUsageRow, error) {
+	db := DB(ctx, r.db).Table("browser_agent_message m").Select(tokenUsageColumns)
+	db = whereTimeRange(db, "m.created_at", startTime, endTime)
+	if userID != 0 {
+		db = db.Joins("JOIN browser_agent_conversation c ON m.conversation_id = c.id").
+			Where("c.created_by = ?", userID)
+	}
+	var row TokenUsageRow
+	if err := db.Scan(&row).Error; err != nil {
+		return nil, errors.WrapDBError(err, "统计模型用量失败")
+	}
+	return &row, nil
+}
+
+// ListConversationTokenUsage 查询时间范围内用户费用最高的会话，按任务创建时间统计
+func (r *BrowserAgentDB) ListConversationTokenUsage(ctx context.Context, userID int64, startTime, endTime time.Time, limit int) ([]ConversationTokenUsageRow, error) {
+	var rows []ConversationTokenUsageRow
+	queryCond := DB(ctx, r.db).Table("browser_agent_conversation c")
+	if err := whereTimeRange(queryCond, "m.created_at", startTime, endTime).
+		Select("c.id AS conversation_id, c.title, "+tokenUsageColumns).
+		Joins("JOIN browser_agent_message m ON m.conversation_id = c.id").
+		Where("c.created_by = ?", userID).
+		Group("c.id, c.title").
+		Order("cost DESC, SUM(m.prompt_tokens + m.completion_tokens) DESC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, errors.WrapDBError(err, "统计会话模型用量失败")
 	}
+	return rows, nil
+}
 
-	return result, totalSum, successSum, nil
+// GetUserTokenUsageRanking 查询时间范围内费用最高的用户，按任务创建时间统计
+func (r *BrowserAgentDB) GetUserTokenUsageRanking(ctx context.Context, startTime, endTime time.Time, limit int) ([]UserTokenUsageRow, error) {
+	var rows []UserTokenUsageRow
+	queryCond := DB(ctx, r.db).Table(`"user" AS u`)
+	if err := whereTimeRange(queryCond, "m.created_at", startTime, endTime).
+		Select("u.id AS user_id, u.username, " + tokenUsageColumns).
+		Joins("JOIN browser_agent_conversation c ON c.created_by = u.id").
+		Joins("JOIN browser_agent_message m ON m.conversation_id = c.id").
+		Group("u.id, u.username").
+		Order("cost DESC, SUM(m.prompt_tokens + m.completion_tokens) DESC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, errors.WrapDBError(err, "统计用户模型用量失败")
+	}
+	return rows, nil
 }
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/errors"
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SystemSettingDB struct {
+	db *gorm.DB
+}
+
+func NewSystemSettingDB(db *gorm.DB) *SystemSettingDB {
+	return &SystemSettingDB{db: db}
+}
+
+// GetSetting 读取配置项，配置不存在时返回 nil
+func (s *SystemSettingDB) GetSetting(ctx context.Context, key string) (setting *entity.SystemSetting, err error) {
+	var settings []*entity.SystemSetting
+	if err = DB(ctx, s.db).Where("setting_key = ?", key).Limit(1).Find(&settings).Error; err != nil {
+		return nil, errors.WrapDBError(err, "查询系统配置失败")
+	}
+	if len(settings) == 0 {
+		return nil, nil
+	}
+	return settings[0], nil
+}
+
+// SaveSetting 保存配置项，已存在时覆盖
+func (s *SystemSettingDB) SaveSetting(ctx context.Context, setting *entity.SystemSetting) error {
+	if err := DB(ctx, s.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "setting_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"setting_value", "remark", "updated_at", "updated_by"}),
+	}).Create(setting).Error; err != nil {
+		return errors.WrapDBError(err, "保存系统配置失败")
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package repository
+
+import (
+	"Art-Design-Backend/internal/repository/db"
+)
+
+type SystemSettingRepo struct {
+	*db.SystemSettingDB
+}
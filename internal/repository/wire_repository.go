@@ -34,6 +34,7 @@ var DBSet = wire.NewSet(
 	db.NewMessageDB,
 	db.NewBrowserAgentDB,
 	db.NewOperationLogDB,
+	db.NewSystemSettingDB,
 )
 
 var RepositorySet = wire.NewSet(
@@ -50,4 +51,5 @@ var RepositorySet = wire.NewSet(
 	wire.Struct(new(ConversationRepo), "*"),
 	wire.Struct(new(BrowserAgentRepo), "*"),
 	wire.Struct(new(OperationLogRepo), "*"),
+	wire.Struct(new(SystemSettingRepo), "*"),
 )
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"Art-Design-Backend/config"
 	"Art-Design-Backend/internal/model/common"
 	"Art-Design-Backend/internal/model/entity"
 	"Art-Design-Backend/internal/model/query"
@@ -9,14 +10,20 @@ import (
 	"Art-Design-Backend/internal/repository"
 	"Art-Design-Backend/internal/repository/db"
 	"Art-Design-Backend/pkg/ai"
-	"Art-Design-Backend/pkg/constant/llmid"
+	"Art-Design-Backend/pkg/aliyun"
+	"Art-Design-Backend/pkg/authutils"
 	"Art-Design-Backend/pkg/constant/prompt"
 	"Art-Design-Backend/pkg/constant/scheduler"
 	"Art-Design-Backend/pkg/ws"
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/bytedance/sonic"
 	"github.com/gin-gonic/gin"
@@ -25,28 +32,61 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// historyMessageLimit 提示词中携带的同会话历史任务条数
+	historyMessageLimit = 5
+	// historyContentMaxLen 历史任务描述的最大展示字符数
+	historyContentMaxLen = 100
+	// actionHistoryTokenBudget 操作轨迹在提示词中允许占用的 token 估算上限
+	actionHistoryTokenBudget = 1500
+	// actionValueMaxLen 操作轨迹中 value、错误信息的最大展示字符数
+	actionValueMaxLen = 50
+	// extractedContentMaxLen 操作轨迹中提取内容的最大展示字符数
+	extractedContentMaxLen = 200
+)
+
 type BrowserAgentService struct {
-	BrowserAgentRepo *repository.BrowserAgentRepo
-	AIModelRepo      *repository.AIModelRepo
-	AIProviderRepo   *repository.AIProviderRepo
-	AIModelClient    *ai.AIModelClient
-	GormTX           *db.GormTransactionManager
+	BrowserAgentRepo   *repository.BrowserAgentRepo
+	AIModelRepo        *repository.AIModelRepo
+	AIProviderRepo     *repository.AIProviderRepo
+	RoleRepo           *repository.RoleRepo
+	SystemSettingRepo  *repository.SystemSettingRepo
+	AIModelClient      *ai.AIModelClient
+	GormTX             *db.GormTransactionManager
+	OssClient          *aliyun.OssClient
+	BrowserAgentConfig *config.BrowserAgent
+	Hub                *ws.Hub
+
+	// toolCallUnsupported 记录不支持原生工具调用的模型ID，命中后直接走 JSON 输出
+	toolCallUnsupported sync.Map
+	// fixedProvider 离线评估时使用的固定供应商，非 nil 时不查询数据库
+	fixedProvider *entity.AIProvider
 }
 
 func NewBrowserAgentService(
 	browserAgentRepo *repository.BrowserAgentRepo,
 	aiModelRepo *repository.AIModelRepo,
 	aiProviderRepo *repository.AIProviderRepo,
+	roleRepo *repository.RoleRepo,
+	systemSettingRepo *repository.SystemSettingRepo,
 	aiModelClient *ai.AIModelClient,
 	gormTX *db.GormTransactionManager,
+	ossClient *aliyun.OssClient,
+	browserAgentConfig *config.BrowserAgent,
+	hub *ws.Hub,
 ) *BrowserAgentService {
 	c := cron.New()
 	b := &BrowserAgentService{
-		BrowserAgentRepo: browserAgentRepo,
-		AIModelRepo:      aiModelRepo,
-		AIProviderRepo:   aiProviderRepo,
-		AIModelClient:    aiModelClient,
-		GormTX:           gormTX,
+		BrowserAgentRepo:   browserAgentRepo,
+		AIModelRepo:        aiModelRepo,
+		AIProviderRepo:     aiProviderRepo,
+		RoleRepo:           roleRepo,
+		SystemSettingRepo:  systemSettingRepo,
+		AIModelClient:      aiModelClient,
+		GormTX:             gormTX,
+		OssClient:          ossClient,
+		BrowserAgentConfig: browserAgentConfig,
+		Hub:                hub,
 	}
 	_ = c.AddFunc(scheduler.BrowserAgentStaleActionCron, func() {
 		if err := b.BrowserAgentRepo.
@@ -59,7 +99,11 @@ func NewBrowserAgentService(
 		}
 		zap.L().Info("未完成旧任务状态已更新为失败")
 	})
+	_ = c.AddFunc(scheduler.BrowserAgentScheduleTickCron, b.runDueSchedules)
+	_ = c.AddFunc(scheduler.BrowserAgentTraceCleanupCron, b.cleanupActionTraces)
 	c.Start()
+	hub.OnRegister(b.deliverQueuedRuns)
+	hub.OnIdle(b.deliverQueuedRuns)
 	return b
 }
 
@@ -71,6 +115,12 @@ func (s *BrowserAgentService) CreateConversation(c *gin.Context, req *request.Cr
 	conv := &entity.BrowserAgentConversation{
 		Title:       req.Title,
 		BrowserType: req.BrowserType,
+		ModelID:     int64(req.ModelID),
+	}
+	if conv.ModelID != 0 {
+		if _, err := s.getBrowserModel(c, conv.ModelID); err != nil {
+			return nil, err
+		}
 	}
 	if err := s.BrowserAgentRepo.CreateConversation(c, conv); err != nil {
 		return nil, err
@@ -81,7 +131,7 @@ func (s *BrowserAgentService) CreateConversation(c *gin.Context, req *request.Cr
 }
 
 func (s *BrowserAgentService) GetConversationByID(c *gin.Context, id int64) (*response.ConversationResponse, error) {
-	conv, err := s.BrowserAgentRepo.GetConversationByID(c, id)
+	conv, err := s.getOwnConversation(c, authutils.GetUserID(c), id)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +156,7 @@ func (s *BrowserAgentService) ListConversations(c *gin.Context, userID int64, qu
 }
 
 func (s *BrowserAgentService) RenameConversation(c *gin.Context, req *request.RenameConversationRequest) error {
-	conv, err := s.BrowserAgentRepo.GetConversationByID(c, int64(req.ID))
+	conv, err := s.getOwnerConversation(c, authutils.GetUserID(c), int64(req.ID))
 	if err != nil {
 		return err
 	}
@@ -115,6 +165,9 @@ func (s *BrowserAgentService) RenameConversation(c *gin.Context, req *request.Re
 }
 
 func (s *BrowserAgentService) DeleteConversation(c *gin.Context, conversationID int64) error {
+	if _, err := s.getOwnerConversation(c, authutils.GetUserID(c), conversationID); err != nil {
+		return err
+	}
 	err := s.GormTX.Transaction(c, func(ctx context.Context) (err error) {
 		messageIDList, err := s.BrowserAgentRepo.ListMessagesIDListByConversationID(ctx, conversationID)
 		if err != nil {
@@ -123,6 +176,9 @@ func (s *BrowserAgentService) DeleteConversation(c *gin.Context, conversationID
 		if err = s.BrowserAgentRepo.DeleteActionsByMessageIDList(ctx, messageIDList); err != nil {
 			return
 		}
+		if err = s.BrowserAgentRepo.DeleteActionTracesByMessageIDList(ctx, messageIDList); err != nil {
+			return
+		}
 		if err = s.BrowserAgentRepo.DeleteMessagesByConversationID(ctx, conversationID); err != nil {
 			return
 		}
@@ -139,9 +195,24 @@ func (s *BrowserAgentService) DeleteConversation(c *gin.Context, conversationID
 // =========================
 
 func (s *BrowserAgentService) CreateMessage(c *gin.Context, req *request.CreateMessageRequest) (*response.MessageResponse, error) {
+	userID := authutils.GetUserID(c)
+	if _, err := s.getOwnerConversation(c, userID, req.ConversationID); err != nil {
+		return nil, err
+	}
+	secrets, err := s.loadUserSecrets(c, userID)
+	if err != nil {
+		return nil, err
+	}
 	msg := &entity.BrowserAgentMessage{
 		ConversationID: req.ConversationID,
-		Content:        req.Content,
+		Content:        maskSecrets(req.Content, secrets),
+		PlanEnabled:    req.Plan,
+	}
+	if len(req.ExtractSchema) > 0 {
+		if err := validateExtractSchema(req.ExtractSchema); err != nil {
+			return nil, err
+		}
+		msg.ExtractSchema = req.ExtractSchema
 	}
 	if err := s.BrowserAgentRepo.CreateMessage(c, msg); err != nil {
 		return nil, err
@@ -152,6 +223,9 @@ func (s *BrowserAgentService) CreateMessage(c *gin.Context, req *request.CreateM
 }
 
 func (s *BrowserAgentService) ListMessages(c *gin.Context, req *request.GetMessagesRequest) ([]response.MessageResponse, error) {
+	if _, err := s.getOwnConversation(c, authutils.GetUserID(c), req.ConversationID); err != nil {
+		return nil, err
+	}
 	messages, err := s.BrowserAgentRepo.ListMessagesByConversationID(c, req.ConversationID)
 	if err != nil {
 		return nil, err
@@ -170,6 +244,9 @@ func (s *BrowserAgentService) ListMessages(c *gin.Context, req *request.GetMessa
 // =========================
 
 func (s *BrowserAgentService) ListActions(c *gin.Context, req *request.GetActionsRequest) ([]response.ActionResponse, error) {
+	if _, _, err := s.getOwnMessage(c, authutils.GetUserID(c), req.MessageID); err != nil {
+		return nil, err
+	}
 	actions, err := s.BrowserAgentRepo.ListActionsByMessageID(c, req.MessageID)
 	if err != nil {
 		return nil, err
@@ -184,12 +261,35 @@ func (s *BrowserAgentService) ListActions(c *gin.Context, req *request.GetAction
 	return responses, nil
 }
 
+// ListClients 列出当前用户在所有节点上在线的浏览器客户端
+func (s *BrowserAgentService) ListClients(c *gin.Context) []response.ClientResponse {
+	presences := s.Hub.Presences(authutils.GetUserID(c))
+	slices.SortFunc(presences, func(a, b *ws.Presence) int {
+		return a.Info.ConnectedAt.Compare(b.Info.ConnectedAt)
+	})
+
+	responses := make([]response.ClientResponse, len(presences))
+	for i, p := range presences {
+		responses[i] = response.ClientResponse{
+			ClientID:        p.Info.ClientID,
+			ConversationID:  p.ConversationID,
+			BrowserType:     p.Info.BrowserType,
+			BrowserVersion:  p.Info.BrowserVersion,
+			Capabilities:    p.Info.Capabilities,
+			ConnectedAt:     p.Info.ConnectedAt,
+			Busy:            p.Busy(),
+			ActiveMessageID: p.ActiveMessageID,
+		}
+	}
+	return responses
+}
+
 // =========================
 // 4. 任务处理
 // =========================
 
-func (s *BrowserAgentService) HandleTask(c context.Context, messageID int64, pageState *ws.PageState) (*ws.Action, error) {
-	msg, err := s.BrowserAgentRepo.GetMessageByID(c, messageID)
+func (s *BrowserAgentService) HandleTask(c context.Context, userID, messageID int64, pageState *ws.PageState) (*ws.Action, error) {
+	msg, _, err := s.getOwnerMessage(c, userID, messageID)
 	if err != nil {
 		return nil, err
 	}
@@ -200,10 +300,20 @@ func (s *BrowserAgentService) HandleTask(c context.Context, messageID int64, pag
 		zap.String("task", msg.Content),
 	)
 
+	// 只执行运行中的任务：排队的定时任务由服务端下发时才开始运行，已结束、取消或暂停的任务不能重新开始
+	if err = checkMessageRunnable(msg); err != nil {
+		return nil, err
+	}
+
 	if pageState == nil {
 		return nil, errors.New("页面状态为空")
 	}
 
+	input, err := s.loadDecisionInput(c, msg, msg.Content, pageState)
+	if err != nil {
+		return nil, err
+	}
+
 	elementsCount := len(pageState.Elements)
 
 	zap.L().Info("页面状态",
@@ -220,24 +330,49 @@ func (s *BrowserAgentService) HandleTask(c context.Context, messageID int64, pag
 
 	zap.L().Info("可交互元素", zap.Strings("elements", elements))
 
-	action, err := s.decideAction(c, msg.Content, pageState)
+	if msg.WorkflowID != 0 {
+		action, finished, err := s.nextWorkflowStep(c, msg, input)
+		if err != nil {
+			return nil, err
+		}
+		if finished {
+			return &ws.Action{Action: "finish_task"}, nil
+		}
+		return action, nil
+	}
+
+	s.ensurePlan(c, msg, input)
+
+	action, _, err := s.decideWithPolicy(c, input, func(c context.Context, input *decisionInput) (*ws.Action, bool, error) {
+		action, err := s.decideAction(c, input)
+		return action, false, err
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	dbAction := s.wsActionToEntity(messageID, action)
-	if err = s.BrowserAgentRepo.CreateAction(c, dbAction); err != nil {
+	if err = s.updatePlan(c, input, action, action.Action == "submit_result"); err != nil {
 		return nil, err
 	}
 
-	action.ActionID = dbAction.ID
+	if err = s.createAction(c, input, action, "首次决策"); err != nil {
+		return nil, err
+	}
 
-	s.logAction("首次决策", action)
+	// 页面上已能直接获取采集结果，保存后通知客户端结束
+	if action.Action == "submit_result" {
+		if err = s.finishMessage(c, messageID, action); err != nil {
+			return nil, err
+		}
+		if err = s.BrowserAgentRepo.UpdateActionStatus(c, action.ActionID, entity.ActionStatusSuccess, nil); err != nil {
+			return nil, err
+		}
+		return &ws.Action{ActionID: action.ActionID, Action: "finish_task"}, nil
+	}
 
-	return action, nil
+	return revealSecrets(action, input.Secrets)
 }
 
-func (s *BrowserAgentService) HandleResult(c context.Context, msg *ws.ClientMessage) (*ws.Action, bool, error) {
+func (s *BrowserAgentService) HandleResult(c context.Context, userID int64, msg *ws.ClientMessage) (*ws.Action, bool, error) {
 	zap.L().Info("========== 收到执行结果 ==========",
 		zap.Int64("actionID", msg.ActionID),
 		zap.Int64("messageID", msg.MessageID),
@@ -245,42 +380,77 @@ func (s *BrowserAgentService) HandleResult(c context.Context, msg *ws.ClientMess
 		zap.Int("executionTime(ms)", msg.ExecutionTime),
 	)
 
-	var errPtr *string
+	action, message, err := s.getOwnerAction(c, userID, msg.MessageID, msg.ActionID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	secrets, err := s.loadMessageSecrets(c, message.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	maskClientMessage(msg, secrets)
+
+	actionResult := &db.ActionResult{ExecutionTime: &msg.ExecutionTime}
 	if msg.Error != "" {
-		errPtr = &msg.Error
+		actionResult.ErrorMessage = &msg.Error
+	}
+	if msg.Extracted != "" {
+		actionResult.Extracted = &msg.Extracted
+	}
+	if msg.Screenshot != "" {
+		screenshotURL, err := s.uploadScreenshot(c, msg.Screenshot)
+		if err != nil {
+			zap.L().Error("上传截图失败", zap.Int64("actionID", msg.ActionID), zap.Error(err))
+		} else {
+			actionResult.ScreenshotURL = &screenshotURL
+		}
+	}
+	if msg.PageState != nil {
+		fingerprint := msg.PageState.Fingerprint()
+		actionResult.PageFingerprint = &fingerprint
+		if msg.PageState.URL != "" {
+			actionResult.ResultURL = &msg.PageState.URL
+		}
+	}
+
+	// 任务已取消或暂停：只记录本次操作的执行结果，不再下发后续操作
+	if stopErr := checkMessageRunnable(message); stopErr != nil {
+		status := entity.ActionStatusSuccess
+		if !msg.Success {
+			status = entity.ActionStatusFailed
+		}
+		if err = s.BrowserAgentRepo.UpdateActionStatus(c, action.ID, status, actionResult); err != nil {
+			return nil, false, err
+		}
+		return nil, false, stopErr
 	}
-	execTimePtr := &msg.ExecutionTime
 
 	if !msg.Success {
 		zap.L().Error("操作执行失败",
 			zap.Int64("actionID", msg.ActionID),
 			zap.String("error", msg.Error),
 		)
-		if err := s.GormTX.Transaction(c, func(ctx context.Context) (err error) {
-			if err = s.BrowserAgentRepo.UpdateActionStatus(ctx, msg.ActionID, entity.ActionStatusFailed, errPtr, execTimePtr); err != nil {
-				return
-			}
-			if err = s.BrowserAgentRepo.UpdateMessageState(ctx, msg.MessageID, entity.MessageStateError); err != nil {
-				return
-			}
-			return
-		}); err != nil {
-			return nil, false, err
-		}
-
-		return nil, false, errors.New(msg.Error)
+		return s.retryFailedAction(c, message, action, msg, actionResult)
 	}
 
-	if err := s.BrowserAgentRepo.UpdateActionStatus(c, msg.ActionID, entity.ActionStatusSuccess, nil, execTimePtr); err != nil {
+	if err = s.BrowserAgentRepo.UpdateActionStatus(c, action.ID, entity.ActionStatusSuccess, actionResult); err != nil {
 		return nil, false, err
 	}
 
-	action, err := s.BrowserAgentRepo.GetActionByID(c, msg.ActionID)
-	if err != nil {
-		return nil, false, err
-	}
+	return s.planNextAction(c, message.ID, msg.Task, msg.PageState, "")
+}
 
-	pageState := msg.PageState
+// planNextAction 根据最新页面状态决策并保存下一步操作，任务完成时返回 finished
+//
+// feedback 为用户对上一步操作的反馈（如拒绝执行的原因），会写入提示词
+func (s *BrowserAgentService) planNextAction(
+	c context.Context,
+	messageID int64,
+	task string,
+	pageState *ws.PageState,
+	feedback string,
+) (*ws.Action, bool, error) {
 	if pageState != nil {
 		zap.L().Info("当前页面状态",
 			zap.String("url", pageState.URL),
@@ -289,33 +459,294 @@ func (s *BrowserAgentService) HandleResult(c context.Context, msg *ws.ClientMess
 		)
 	}
 
-	nextAction, finished, err := s.decideNextAction(c, pageState, msg.Task)
+	message, err := s.BrowserAgentRepo.GetMessageByID(c, messageID)
 	if err != nil {
 		return nil, false, err
 	}
+	if err = checkMessageRunnable(message); err != nil {
+		return nil, false, err
+	}
+
+	if task == "" {
+		task = message.Content
+	}
+
+	input, err := s.loadDecisionInput(c, message, task, pageState)
+	if err != nil {
+		return nil, false, err
+	}
+	input.Feedback = feedback
+
+	if len(input.Actions) >= s.BrowserAgentConfig.MaxStepsPerMessage {
+		return nil, false, s.abortLoopMessage(c, message.ID,
+			fmt.Sprintf("操作步数已达上限(%d)", s.BrowserAgentConfig.MaxStepsPerMessage))
+	}
+
+	if message.WorkflowID != 0 {
+		// 工作流步骤之间相互依赖，用户拒绝某一步后不再继续回放
+		if feedback != "" {
+			if err = s.endMessage(c, message.ID, entity.MessageStateError, feedback); err != nil {
+				return nil, false, err
+			}
+			return nil, false, fmt.Errorf("工作流已终止: %s", feedback)
+		}
+		return s.nextWorkflowStep(c, message, input)
+	}
+
+	stalled := s.countStalledActions(input.Actions, pageState)
+	if stalled >= 2*s.BrowserAgentConfig.StalledPageLimit {
+		return nil, false, s.abortLoopMessage(c, message.ID,
+			fmt.Sprintf("连续 %d 次操作后页面均无变化", stalled))
+	}
+	if stalled >= s.BrowserAgentConfig.StalledPageLimit {
+		input.StuckHint = fmt.Sprintf("最近连续 %d 次操作后页面没有任何变化，当前策略无效。", stalled)
+	}
+
+	nextAction, finished, err := s.decideWithPolicy(c, input, s.decideNextAction)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !finished && s.isRepeatedAction(input.Actions, pageState, nextAction) {
+		if input.StuckHint != "" {
+			return nil, false, s.abortLoopMessage(c, message.ID, "提示重新规划后仍重复执行相同操作")
+		}
+
+		zap.L().Warn("检测到重复操作，要求模型重新规划",
+			zap.Int64("messageID", message.ID),
+			zap.String("action", nextAction.Action),
+		)
+		input.StuckHint = fmt.Sprintf("你已连续多次执行相同的 %s 操作，但页面没有变化。", nextAction.Action)
+		ws.Emit(c, ws.ServerMessageRetrying, message.ID, "检测到重复操作，重新规划")
+
+		nextAction, finished, err = s.decideWithPolicy(c, input, s.decideNextAction)
+		if err != nil {
+			return nil, false, err
+		}
+		if !finished && s.isRepeatedAction(input.Actions, pageState, nextAction) {
+			return nil, false, s.abortLoopMessage(c, message.ID, "提示重新规划后仍重复执行相同操作")
+		}
+	}
+
+	if err = s.updatePlan(c, input, nextAction, finished); err != nil {
+		return nil, false, err
+	}
 
 	if finished {
-		zap.L().Info("任务完成", zap.Int64("messageID", msg.MessageID))
-		if err = s.BrowserAgentRepo.UpdateMessageState(c, msg.MessageID, entity.MessageStateFinished); err != nil {
+		zap.L().Info("任务完成", zap.Int64("messageID", message.ID))
+		if err = s.finishMessage(c, message.ID, nextAction); err != nil {
 			return nil, false, err
 		}
 		return nil, true, nil
 	}
 
-	dbAction := s.wsActionToEntity(action.MessageID, nextAction)
-	if err = s.BrowserAgentRepo.CreateAction(c, dbAction); err != nil {
+	if err = s.createAction(c, input, nextAction, "下一步决策"); err != nil {
 		return nil, false, err
 	}
 
-	nextAction.ActionID = dbAction.ID
+	revealed, err := revealSecrets(nextAction, input.Secrets)
+	return revealed, false, err
+}
+
+// createAction 保存即将下发的操作及其决策轨迹；命中敏感操作策略时标记为待确认，由用户确认后再执行
+func (s *BrowserAgentService) createAction(c context.Context, input *decisionInput, action *ws.Action, stage string) error {
+	// 模型决策耗时较长，期间用户可能已取消或暂停任务
+	message, err := s.BrowserAgentRepo.GetMessageByID(c, input.MessageID)
+	if err != nil {
+		return err
+	}
+	if err = checkMessageRunnable(message); err != nil {
+		return err
+	}
+
+	dbAction := s.wsActionToEntity(input.MessageID, action)
+	dbAction.PromptTokens, dbAction.CompletionTokens, dbAction.Cost = sumUsage(input.LLMCalls)
+	if reason := s.confirmReason(action, input.PageState); reason != "" {
+		dbAction.Status = entity.ActionStatusAwaitingConfirm
+		dbAction.ConfirmReason = &reason
+		action.ConfirmReason = reason
+	}
+
+	if err = s.BrowserAgentRepo.CreateAction(c, dbAction); err != nil {
+		return err
+	}
+
+	action.ActionID = dbAction.ID
+
+	s.saveActionTrace(c, input, dbAction.ID)
+	s.logAction(stage, action)
+
+	return nil
+}
+
+// finishMessage 将运行中的任务标记为完成，结束操作为 submit_result 时同时保存采集结果
+//
+// 模型决策期间任务可能已被取消或暂停，此时不覆盖当前状态，返回对应错误
+func (s *BrowserAgentService) finishMessage(c context.Context, messageID int64, action *ws.Action) error {
+	if action == nil || action.Action != "submit_result" {
+		return s.endMessage(c, messageID, entity.MessageStateFinished, "")
+	}
+
+	data, err := sonic.MarshalString(action.Data)
+	if err != nil {
+		return fmt.Errorf("序列化采集结果失败: %w", err)
+	}
+
+	zap.L().Info("保存采集结果", zap.Int64("messageID", messageID), zap.Int("size", len(data)))
+
+	ok, err := s.BrowserAgentRepo.FinishMessageWithResult(c, messageID, data)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.notRunningError(c, messageID)
+	}
+	return nil
+}
+
+// uploadScreenshot 将客户端回传的 base64 截图上传至 OSS，返回访问地址
+func (s *BrowserAgentService) uploadScreenshot(c context.Context, data string) (string, error) {
+	img, err := decodeScreenshot(data)
+	if err != nil {
+		return "", err
+	}
+	return s.OssClient.UploadBrowserAgentScreenshot(c, "screenshot.png", bytes.NewReader(img))
+}
+
+// decodeScreenshot 解码 base64 截图，兼容 data URL 前缀
+func decodeScreenshot(data string) ([]byte, error) {
+	if idx := strings.Index(data, ";base64,"); idx != -1 {
+		data = data[idx+len(";base64,"):]
+	}
+	img, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("截图解码失败: %w", err)
+	}
+	return img, nil
+}
+
+// countStalledActions 统计末尾连续多少次成功操作之后的页面与当前页面指纹一致
+func (s *BrowserAgentService) countStalledActions(actions []*entity.BrowserAgentAction, pageState *ws.PageState) int {
+	if pageState == nil {
+		return 0
+	}
+
+	current := pageState.Fingerprint()
+	count := 0
+	for i := len(actions) - 1; i >= 0; i-- {
+		a := actions[i]
+		if a.Status != entity.ActionStatusSuccess || a.PageFingerprint == nil || *a.PageFingerprint != current {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// isRepeatedAction 判断即将下发的操作是否为无效重复
+//
+// 末尾连续与 next 完全相同、且执行后页面与当前页面一致的操作数
+// 加上本次达到 RepeatActionLimit 时视为陷入循环；
+// 页面有变化的重复操作（如翻页、滚动加载）不计入
+func (s *BrowserAgentService) isRepeatedAction(actions []*entity.BrowserAgentAction, pageState *ws.PageState, next *ws.Action) bool {
+	current := pageState.Fingerprint()
+	repeats := 0
+	for i := len(actions) - 1; i >= 0; i-- {
+		a := actions[i]
+		if !sameAction(a, next) {
+			break
+		}
+		if a.PageFingerprint != nil && *a.PageFingerprint != current {
+			break
+		}
+		repeats++
+	}
+	return repeats+1 >= s.BrowserAgentConfig.RepeatActionLimit
+}
+
+func sameAction(a *entity.BrowserAgentAction, b *ws.Action) bool {
+	return a.ActionType == b.Action &&
+		equalPtr(a.URL, b.URL) &&
+		equalPtr(a.Selector, b.Selector) &&
+		equalPtr(a.Value, b.Value) &&
+		equalPtr(a.Distance, b.Distance) &&
+		equalPtr(a.Timeout, b.Timeout) &&
+		equalPtr(a.Key, b.Key) &&
+		equalPtr(a.TabIndex, b.TabIndex) &&
+		equalPtr(a.FileURL, b.FileURL)
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// abortLoopMessage 将任务标记为因循环终止，并返回提示给客户端的错误
+func (s *BrowserAgentService) abortLoopMessage(c context.Context, messageID int64, reason string) error {
+	zap.L().Warn("任务陷入循环，已终止", zap.Int64("messageID", messageID), zap.String("reason", reason))
+	if err := s.endMessage(c, messageID, entity.MessageStateAbortedLoop, reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("任务已终止: %s", reason)
+}
+
+// loadDecisionInput 加载决策所需的上下文：同会话的历史任务与当前任务已执行的操作轨迹
+func (s *BrowserAgentService) loadDecisionInput(
+	c context.Context,
+	msg *entity.BrowserAgentMessage,
+	task string,
+	pageState *ws.PageState,
+) (*decisionInput, error) {
+	conv, err := s.BrowserAgentRepo.GetConversationByID(c, msg.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	model, err := s.resolveConversationModel(c, conv)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := s.loadURLPolicies(c, conv)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := s.loadUserSecrets(c, conv.CreateBy)
+	if err != nil {
+		return nil, err
+	}
+	maskPageState(pageState, secrets)
+
+	history, err := s.BrowserAgentRepo.ListPreviousMessagesByConversationID(c, msg.ConversationID, msg.ID, historyMessageLimit)
+	if err != nil {
+		return nil, err
+	}
 
-	s.logAction("下一步决策", nextAction)
+	actions, err := s.BrowserAgentRepo.ListActionsByMessageID(c, msg.ID)
+	if err != nil {
+		return nil, err
+	}
 
-	return nextAction, false, nil
+	return &decisionInput{
+		MessageID:     msg.ID,
+		Task:          task,
+		Model:         model,
+		URLPolicies:   policies,
+		Secrets:       secrets,
+		History:       history,
+		Actions:       actions,
+		PageState:     pageState,
+		ImageURL:      s.prepareScreenshot(c, pageState),
+		ExtractSchema: msg.ExtractSchema,
+		Plan:          msg.Plan,
+	}, nil
 }
 
 func (s *BrowserAgentService) wsActionToEntity(messageID int64, action *ws.Action) *entity.BrowserAgentAction {
-	return &entity.BrowserAgentAction{
+	a := &entity.BrowserAgentAction{
 		MessageID:  messageID,
 		ActionType: action.Action,
 		Status:     entity.ActionStatusPending,
@@ -324,7 +755,15 @@ func (s *BrowserAgentService) wsActionToEntity(messageID int64, action *ws.Actio
 		Value:      action.Value,
 		Distance:   action.Distance,
 		Timeout:    action.Timeout,
+		Key:        action.Key,
+		TabIndex:   action.TabIndex,
+		FileURL:    action.FileURL,
+	}
+	if action.Rationale != "" {
+		rationale := truncateString(action.Rationale, 490)
+		a.Rationale = &rationale
 	}
+	return a
 }
 
 func (s *BrowserAgentService) logAction(stage string, action *ws.Action) {
@@ -339,7 +778,7 @@ func (s *BrowserAgentService) logAction(stage string, action *ws.Action) {
 		if action.URL != nil {
 			fields = append(fields, zap.String("url", *action.URL))
 		}
-	case "click":
+	case "click", "hover", "extract":
 		if action.Selector != nil {
 			fields = append(fields, zap.String("selector", *action.Selector))
 		}
@@ -358,9 +797,35 @@ func (s *BrowserAgentService) logAction(stage string, action *ws.Action) {
 		if action.Timeout != nil {
 			fields = append(fields, zap.Int("timeout(ms)", *action.Timeout))
 		}
+	case "press_key":
+		if action.Key != nil {
+			fields = append(fields, zap.String("key", *action.Key))
+		}
+		if action.Selector != nil {
+			fields = append(fields, zap.String("selector", *action.Selector))
+		}
+	case "open_tab":
+		if action.URL != nil {
+			fields = append(fields, zap.String("url", *action.URL))
+		}
+	case "switch_tab", "close_tab":
+		if action.TabIndex != nil {
+			fields = append(fields, zap.Int("tabIndex", *action.TabIndex))
+		}
+	case "upload_file":
+		if action.Selector != nil {
+			fields = append(fields, zap.String("selector", *action.Selector))
+		}
+		if action.FileURL != nil {
+			fields = append(fields, zap.String("fileURL", *action.FileURL))
+		}
+	case "go_back", "screenshot":
 	case "finish_task":
 		fields = append(fields, zap.String("message", "任务完成"))
 	}
+	if action.Rationale != "" {
+		fields = append(fields, zap.String("reason", action.Rationale))
+	}
 
 	zap.L().Info("========== 发送操作指令 ==========", fields...)
 }
@@ -369,50 +834,137 @@ func (s *BrowserAgentService) logAction(stage string, action *ws.Action) {
 // 5. 大模型相关
 // =========================
 
+// getProvider 获取模型供应商
+func (s *BrowserAgentService) getProvider(c context.Context, providerID int64) (*entity.AIProvider, error) {
+	if s.fixedProvider != nil {
+		return s.fixedProvider, nil
+	}
+	return s.AIProviderRepo.GetAIProviderByIDWithCache(c, providerID)
+}
+
+// callLLM 调用会话配置的文本模型决策下一步操作，同时返回本次调用的输入输出记录
 func (s *BrowserAgentService) callLLM(
 	c context.Context,
+	modelInfo *entity.AIModel,
 	systemPrompt,
 	promptText string,
-) (string, error) {
+	tools []ai.Tool,
+	onThinking func(delta string),
+) (*ws.Action, *entity.LLMCall, error) {
 
-	provider, err := s.AIProviderRepo.GetAIProviderByIDWithCache(c, llmid.BrowserProviderID)
+	provider, err := s.getProvider(c, modelInfo.ProviderID)
 	if err != nil {
-		zap.L().Error("获取浏览器智谱模型供应商失败", zap.Error(err))
-		return "", fmt.Errorf("获取浏览器智谱模型供应商失败: %w", err)
+		zap.L().Error("获取浏览器智能体模型供应商失败", zap.Int64("providerID", modelInfo.ProviderID), zap.Error(err))
+		return nil, nil, fmt.Errorf("获取浏览器智能体模型供应商失败: %w", err)
 	}
 
-	modelInfo, err := s.AIModelRepo.GetAIModelByIDWithCache(c, llmid.BrowserModelID)
-	if err != nil {
-		zap.L().Error("获取浏览器智谱模型失败", zap.Error(err))
-		return "", fmt.Errorf("获取浏览器智谱模型失败: %w", err)
-	}
-
-	respJSON, err := s.AIModelClient.ChatRequest(
-		c,
-		provider.BaseURL+modelInfo.APIPath,
-		provider.APIKey,
-		ai.DefaultChatRequest(
-			modelInfo.Model,
-			[]ai.ChatMessage{
-				{Role: "system", Content: systemPrompt},
-				{Role: "user", Content: promptText},
-			},
-		),
+	chatReq := ai.DefaultChatRequest(
+		modelInfo.Model,
+		[]ai.ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: promptText},
+		},
 	)
+
+	call := newLLMCall(modelInfo, promptText, false)
+	respJSON, err := s.requestWithToolFallback(modelInfo, tools, func(tools []ai.Tool) ([]byte, error) {
+		chatReq.Tools, chatReq.ToolChoice = nil, ""
+		if len(tools) > 0 {
+			chatReq.Tools, chatReq.ToolChoice = tools, "auto"
+		}
+		if onThinking != nil {
+			return s.AIModelClient.ChatStreamRequest(c, provider.BaseURL+modelInfo.APIPath, provider.APIKey, chatReq, onThinking)
+		}
+		return s.AIModelClient.ChatRequest(c, provider.BaseURL+modelInfo.APIPath, provider.APIKey, chatReq)
+	})
+	finishLLMCall(call, modelInfo, respJSON, err)
 	if err != nil {
 		zap.L().Error("调用LLM失败", zap.String("promptText", promptText), zap.Error(err))
-		return "", fmt.Errorf("调用LLM失败: %w", err)
+		return nil, call, fmt.Errorf("调用LLM失败: %w", err)
+	}
+
+	action, err := s.parseLLMResponse(respJSON, promptText)
+	failLLMCall(call, err)
+	return action, call, err
+}
+
+// toolErrorKeywords 供应商拒绝工具参数时错误信息中出现的关键词
+var toolErrorKeywords = []string{"tool", "function"}
+
+// requestWithToolFallback 优先携带工具定义请求模型，获取结构化的工具调用；
+// 请求返回 400/422 时本次改为纯文本请求，错误信息指明不支持工具调用时记录该模型，之后均以纯文本方式请求
+func (s *BrowserAgentService) requestWithToolFallback(
+	modelInfo *entity.AIModel,
+	tools []ai.Tool,
+	send func(tools []ai.Tool) ([]byte, error),
+) ([]byte, error) {
+	if _, unsupported := s.toolCallUnsupported.Load(modelInfo.ID); unsupported {
+		return send(nil)
 	}
 
+	respJSON, err := send(tools)
+
+	var statusErr *ai.StatusError
+	if err == nil || !errors.As(err, &statusErr) ||
+		(statusErr.StatusCode != http.StatusBadRequest && statusErr.StatusCode != http.StatusUnprocessableEntity) {
+		return respJSON, err
+	}
+
+	if isToolUnsupportedError(statusErr) {
+		zap.L().Warn("模型不支持工具调用，降级为 JSON 输出",
+			zap.String("model", modelInfo.Model),
+			zap.Error(err),
+		)
+		s.toolCallUnsupported.Store(modelInfo.ID, struct{}{})
+	} else {
+		// 上下文超长、参数错误等与工具无关的错误不记录，仅本次不携带工具重试
+		zap.L().Warn("携带工具请求模型失败，本次以 JSON 输出重试",
+			zap.String("model", modelInfo.Model),
+			zap.Error(err),
+		)
+	}
+	return send(nil)
+}
+
+// isToolUnsupportedError 判断供应商的错误信息是否指向工具调用参数
+func isToolUnsupportedError(statusErr *ai.StatusError) bool {
+	body := strings.ToLower(statusErr.Body)
+	for _, keyword := range toolErrorKeywords {
+		if strings.Contains(body, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLLMResponse 解析模型响应：优先读取工具调用，未返回工具调用时从文本中提取 JSON 兜底
+func (s *BrowserAgentService) parseLLMResponse(respJSON []byte, promptText string) (*ws.Action, error) {
 	var browserResp ai.ChatCompletionResponse
-	if err = sonic.Unmarshal(respJSON, &browserResp); err != nil {
+	if err := sonic.Unmarshal(respJSON, &browserResp); err != nil {
 		zap.L().Error("解析 LLM 原始响应失败", zap.Error(err))
-		return "", fmt.Errorf("解析 LLM 原始响应失败: %w", err)
+		return nil, fmt.Errorf("解析 LLM 原始响应失败: %w", err)
+	}
+
+	if toolCall := browserResp.FirstToolCall(); toolCall != nil {
+		zap.L().Debug(
+			"LLM 工具调用",
+			zap.String("name", toolCall.Function.Name),
+			zap.String("arguments", toolCall.Function.Arguments),
+		)
+		action, err := parseToolCall(toolCall)
+		if err != nil {
+			return nil, err
+		}
+		// 模型未在参数中给出理由时，以工具调用附带的文本作为理由
+		if action.Rationale == "" {
+			action.Rationale = strings.TrimSpace(browserResp.FirstText())
+		}
+		return action, nil
 	}
 
 	rawContent := strings.TrimSpace(browserResp.FirstText())
 	if rawContent == "" {
-		return "", errors.New("LLM 返回内容为空")
+		return nil, errors.New("LLM 返回内容为空")
 	}
 
 	cleanJSON, err := ai.ExtractJSONFromLLMOutput(rawContent)
@@ -422,7 +974,7 @@ func (s *BrowserAgentService) callLLM(
 			zap.String("raw", rawContent),
 			zap.Error(err),
 		)
-		return "", err
+		return nil, err
 	}
 
 	zap.L().Debug(
@@ -431,87 +983,223 @@ func (s *BrowserAgentService) callLLM(
 		zap.String("json", cleanJSON),
 	)
 
-	return cleanJSON, nil
+	return s.parseAction(cleanJSON)
+}
+
+// requestAction 请求模型给出下一步操作：本步带截图时优先使用多模态模型，失败后退回文本模型
+func (s *BrowserAgentService) requestAction(c context.Context, input *decisionInput, promptText string) (*ws.Action, error) {
+	tools := toolsFor(input)
+	onThinking := s.thinkingSink(c, input.MessageID)
+
+	var (
+		action *ws.Action
+		call   *entity.LLMCall
+		err    error
+	)
+	if input.ImageURL != "" {
+		action, call, err = s.callVisionLLM(c, prompt.BrowserSystemPrompt, promptText, input.ImageURL, tools, onThinking)
+		s.recordLLMCall(c, input, call)
+		if err != nil {
+			zap.L().Warn("多模态模型决策失败，降级为文本模型", zap.Error(err))
+			ws.Emit(c, ws.ServerMessageRetrying, input.MessageID, "多模态模型决策失败，改用文本模型")
+		}
+	}
+	if action == nil {
+		action, call, err = s.callLLM(c, input.Model, prompt.BrowserSystemPrompt, promptText, tools, onThinking)
+		s.recordLLMCall(c, input, call)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = resolveElementIndex(action, input.PageState)
+	failLLMCall(call, err)
+	return action, err
+}
+
+// thinkingSink 返回将模型增量输出作为 thinking 事件推送的回调；
+// 未关联客户端连接或已关闭流式调用时返回 nil，此时以非流式方式请求模型
+func (s *BrowserAgentService) thinkingSink(c context.Context, messageID int64) func(delta string) {
+	if s.BrowserAgentConfig.DisableStreaming || !ws.HasEventSink(c) {
+		return nil
+	}
+	return func(delta string) {
+		ws.Emit(c, ws.ServerMessageThinking, messageID, delta)
+	}
 }
 
 func (s *BrowserAgentService) decideAction(
 	c context.Context,
-	task string,
-	pageState *ws.PageState,
+	input *decisionInput,
 ) (*ws.Action, error) {
 
 	zap.L().Info(
 		"开始智能任务处理",
-		zap.String("task", task),
-		zap.Any("pageState", pageState),
+		zap.String("task", input.Task),
+		zap.String("url", input.PageState.URL),
+		zap.Int("elementsCount", len(input.PageState.Elements)),
+		zap.Bool("vision", input.ImageURL != ""),
 	)
 
-	decidePrompt := s.buildPrompt(task, pageState)
+	ws.Emit(c, ws.ServerMessagePlanning, input.MessageID, "正在规划下一步操作")
+	decidePrompt := s.buildPrompt(input)
 
-	resp, err := s.callLLM(c, prompt.BrowserSystemPrompt, decidePrompt)
+	action, err := s.requestAction(c, input, decidePrompt)
 	if err != nil {
 		return nil, err
 	}
 
-	action, err := s.parseAction(resp)
-	if err != nil {
+	ws.Emit(c, ws.ServerMessageValidating, input.MessageID, "正在校验操作 "+action.Action)
+
+	if err = s.validateAction(action, input.URLPolicies); err != nil {
 		return nil, err
 	}
+	if err = checkSecretRefs(action, input.Secrets); err != nil {
+		return nil, err
+	}
+	if action.Action == "submit_result" {
+		return action, validateExtractResult(input.ExtractSchema, action.Data)
+	}
 
-	return action, s.validateAction(action)
+	return action, nil
 }
 
 func (s *BrowserAgentService) decideNextAction(
 	c context.Context,
-	pageState *ws.PageState,
-	task string,
+	input *decisionInput,
 ) (*ws.Action, bool, error) {
 
-	nextActionPrompt := s.buildNextPrompt(pageState, task)
+	ws.Emit(c, ws.ServerMessagePlanning, input.MessageID, "正在规划下一步操作")
+	nextActionPrompt := s.buildNextPrompt(input)
 
-	resp, err := s.callLLM(c, prompt.BrowserSystemPrompt, nextActionPrompt)
+	action, err := s.requestAction(c, input, nextActionPrompt)
 	if err != nil {
 		return nil, false, err
 	}
 
-	if strings.Contains(resp, `"action":"finish_task"`) ||
-		strings.Contains(resp, `"action": "finish_task"`) {
-		return nil, true, nil
+	ws.Emit(c, ws.ServerMessageValidating, input.MessageID, "正在校验操作 "+action.Action)
+
+	switch action.Action {
+	case "finish_task":
+		return action, true, nil
+	case "submit_result":
+		if err = s.validateAction(action, input.URLPolicies); err != nil {
+			return nil, false, err
+		}
+		return action, true, validateExtractResult(input.ExtractSchema, action.Data)
 	}
 
-	action, err := s.parseAction(resp)
-	if err != nil {
+	if err = s.validateAction(action, input.URLPolicies); err != nil {
 		return nil, false, err
 	}
-
-	return action, false, s.validateAction(action)
+	return action, false, checkSecretRefs(action, input.Secrets)
 }
 
 // =========================
 // 6. Prompt 构建
 // =========================
 
-func (s *BrowserAgentService) buildPrompt(
-	task string,
-	pageState *ws.PageState,
-) string {
+// decisionInput 一次决策所需的上下文
+type decisionInput struct {
+	MessageID     int64                         // 当前任务ID
+	Task          string                        // 当前任务描述
+	Model         *entity.AIModel               // 会话使用的决策模型
+	URLPolicies   []*urlPolicy                  // 会话及会话所有者角色的 URL 访问策略
+	Secrets       map[string]string             // 会话所有者的凭据（名称 -> 明文），仅用于替换与屏蔽，不写入提示词
+	History       []*entity.BrowserAgentMessage // 同会话中更早的任务
+	Actions       []*entity.BrowserAgentAction  // 当前任务已生成的操作（按时间正序）
+	PageState     *ws.PageState                 // 当前页面状态
+	ImageURL      string                        // 标注了元素序号的截图地址，为空表示本步无截图
+	StuckHint     string                        // 检测到循环/停滞时给模型的重新规划提示
+	WorkflowHint  string                        // 工作流回放时元素失效的步骤说明
+	Feedback      string                        // 用户对上一步操作的反馈（如拒绝执行的原因）
+	ExtractSchema map[string]any                // 数据采集任务的结果 Schema，为空表示普通任务
+	Plan          []entity.PlanStep             // 任务计划，为空表示未使用计划
+	LLMCalls      []*entity.LLMCall             // 本次决策的模型调用记录，保存操作时写入决策轨迹
+}
+
+func (s *BrowserAgentService) buildPrompt(input *decisionInput) string {
 
 	return "【用户目标】\n" +
-		task + "\n\n" +
-		s.buildPageStateSection(pageState)
+		input.Task + "\n\n" +
+		s.buildExtractSection(input.ExtractSchema) +
+		s.buildPlanSection(input.Plan) +
+		s.buildSecretSection(input.Secrets) +
+		s.buildHistorySection(input.History) +
+		s.buildActionHistorySection(input.Actions) +
+		s.buildFeedbackSection(input.Feedback) +
+		s.buildWorkflowSection(input.WorkflowHint) +
+		s.buildVisionSection(input.ImageURL) +
+		s.buildPageStateSection(input)
 }
 
-func (s *BrowserAgentService) buildNextPrompt(
-	pageState *ws.PageState,
-	task string,
-) string {
+func (s *BrowserAgentService) buildNextPrompt(input *decisionInput) string {
 
 	return "【继续执行当前任务】\n\n" +
-		"原始任务:" + task + "\n\n" +
-		s.buildPageStateSection(pageState)
+		"原始任务:" + input.Task + "\n\n" +
+		s.buildExtractSection(input.ExtractSchema) +
+		s.buildPlanSection(input.Plan) +
+		s.buildSecretSection(input.Secrets) +
+		s.buildHistorySection(input.History) +
+		s.buildActionHistorySection(input.Actions) +
+		s.buildFeedbackSection(input.Feedback) +
+		s.buildStuckSection(input.StuckHint) +
+		s.buildVisionSection(input.ImageURL) +
+		s.buildPageStateSection(input)
+}
+
+func (s *BrowserAgentService) buildExtractSection(schema map[string]any) string {
+	if len(schema) == 0 {
+		return ""
+	}
+
+	schemaJSON, err := sonic.MarshalString(schema)
+	if err != nil {
+		return ""
+	}
+
+	return "【数据采集要求】\n" +
+		"本任务需要采集结构化数据，结果必须符合以下 JSON Schema：\n" +
+		schemaJSON + "\n" +
+		"数据采集完整后调用 submit_result 提交（JSON 输出时放在 data 字段），不要使用 finish_task；" +
+		"数据分布在多页时请先翻页收集，再一次性提交。\n\n"
+}
+
+func (s *BrowserAgentService) buildVisionSection(imageURL string) string {
+	if imageURL == "" {
+		return ""
+	}
+
+	return "【页面截图】\n" +
+		"已附上当前视口截图，元素边框左上角的数字即【可交互元素】中的序号。" +
+		"请结合截图理解图标、画布等无文字内容，需要操作元素时可通过 element_index 指定序号。\n\n"
 }
 
-func (s *BrowserAgentService) buildPageStateSection(pageState *ws.PageState) string {
+func (s *BrowserAgentService) buildFeedbackSection(feedback string) string {
+	if feedback == "" {
+		return ""
+	}
+
+	return "【用户反馈】\n" +
+		feedback + "\n" +
+		"请尊重用户的决定，不要再次提出相同的操作；如果没有其他可行方式，请返回 finish_task。\n\n"
+}
+
+func (s *BrowserAgentService) buildStuckSection(hint string) string {
+	if hint == "" {
+		return ""
+	}
+
+	return "【警告：你陷入了停滞】\n" +
+		hint + "\n" +
+		"请不要重复之前的操作，重新分析页面并换一种方式推进任务" +
+		"（例如选择其他元素、先滚动查找、或跳转到其他页面）；" +
+		"如果任务确实已经完成，请返回 finish_task。\n\n"
+}
+
+// buildPageStateSection 构建页面状态，可交互元素按与任务的相关度在 token 预算内挑选
+func (s *BrowserAgentService) buildPageStateSection(input *decisionInput) string {
+	pageState := input.PageState
 	if pageState == nil {
 		return ""
 	}
@@ -535,44 +1223,18 @@ func (s *BrowserAgentService) buildPageStateSection(pageState *ws.PageState) str
 		))
 	}
 
-	sb.WriteString("\n【可交互元素】\n")
-	for i, elem := range pageState.Elements {
-		sb.WriteString(fmt.Sprintf("%d. ", i+1))
-
-		switch elem.Tag {
-		case "input", "textarea":
-			typeInfo := "text"
-			if elem.Type != nil {
-				typeInfo = *elem.Type
-			}
-			labelInfo := elem.Text
-			if elem.Label != nil && *elem.Label != "" {
-				labelInfo = *elem.Label
-			}
-			sb.WriteString(fmt.Sprintf("[%s] label=\"%s\"", typeInfo, labelInfo))
-			if elem.Value != nil && *elem.Value != "" {
-				sb.WriteString(fmt.Sprintf(" value=\"%s\"", *elem.Value))
-			}
-			sb.WriteString(fmt.Sprintf(" selector=%s", elem.Selector))
-
-		case "select":
-			labelInfo := elem.Text
-			if elem.Label != nil && *elem.Label != "" {
-				labelInfo = *elem.Label
-			}
-			sb.WriteString(fmt.Sprintf("[select] label=\"%s\" selector=%s", labelInfo, elem.Selector))
-
-		case "button":
-			sb.WriteString(fmt.Sprintf("[button] text=\"%s\" selector=%s", elem.Text, elem.Selector))
-
-		case "a":
-			sb.WriteString(fmt.Sprintf("[link] text=\"%s\" selector=%s", elem.Text, elem.Selector))
-
-		default:
-			sb.WriteString(fmt.Sprintf("[%s] text=\"%s\" selector=%s", elem.Tag, elem.Text, elem.Selector))
-		}
+	texts := []string{input.Task}
+	if current := countDoneSteps(input.Plan); current < len(input.Plan) {
+		texts = append(texts, input.Plan[current].Goal)
+	}
+	lines, omitted := selectPageElements(pageState, taskKeywords(texts...), pageElementsTokenBudget(input.Model))
 
-		sb.WriteString("\n")
+	sb.WriteString("\n【可交互元素】\n")
+	for _, line := range lines {
+		sb.WriteString(line)
+	}
+	if omitted > 0 {
+		sb.WriteString(fmt.Sprintf("（另有 %d 个与任务相关度较低或重复的元素已省略，需要时可滚动页面或结合截图序号操作）\n", omitted))
 	}
 
 	return sb.String()
@@ -588,11 +1250,112 @@ func (s *BrowserAgentService) buildHistorySection(history []*entity.BrowserAgent
 
 	for i, h := range history {
 		sb.WriteString(fmt.Sprintf(
-			"%d. %s\n",
+			"%d. %s (状态: %s)\n",
 			i+1,
-			h.Content,
+			truncateString(h.Content, historyContentMaxLen),
+			h.State,
 		))
 	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// buildActionHistorySection 构建当前任务的操作轨迹
+//
+// 从最近的操作开始倒序累加，超过 actionHistoryTokenBudget 后截断更早的操作，
+// 保证提示词长度可控的同时让模型知道自己已经做过什么
+func (s *BrowserAgentService) buildActionHistorySection(actions []*entity.BrowserAgentAction) string {
+	if len(actions) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(actions))
+	usedTokens := 0
+	omitted := len(actions)
+
+	for i := len(actions) - 1; i >= 0; i-- {
+		line := fmt.Sprintf("%d. %s\n", i+1, s.formatActionTrace(actions[i]))
+		tokens := ai.EstimateTokens(line)
+		if usedTokens+tokens > actionHistoryTokenBudget {
+			break
+		}
+		usedTokens += tokens
+		omitted = i
+		lines = append(lines, line)
+	}
+	slices.Reverse(lines)
+
+	var sb strings.Builder
+	sb.WriteString("【已执行操作】\n")
+	if omitted > 0 {
+		sb.WriteString(fmt.Sprintf("（更早的 %d 条操作已省略）\n", omitted))
+	}
+	for _, line := range lines {
+		sb.WriteString(line)
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// formatActionTrace 将单条操作压缩为一行描述：类型、参数、执行结果与结果页面
+func (s *BrowserAgentService) formatActionTrace(action *entity.BrowserAgentAction) string {
+	var sb strings.Builder
+	sb.WriteString(action.ActionType)
+
+	if action.URL != nil {
+		sb.WriteString(" url=" + *action.URL)
+	}
+	if action.Selector != nil {
+		sb.WriteString(" selector=" + *action.Selector)
+	}
+	if action.Value != nil {
+		sb.WriteString(fmt.Sprintf(" value=\"%s\"", truncateString(*action.Value, actionValueMaxLen)))
+	}
+	if action.Distance != nil {
+		sb.WriteString(fmt.Sprintf(" distance=%d", *action.Distance))
+	}
+	if action.Timeout != nil {
+		sb.WriteString(fmt.Sprintf(" timeout=%d", *action.Timeout))
+	}
+	if action.Key != nil {
+		sb.WriteString(" key=" + *action.Key)
+	}
+	if action.TabIndex != nil {
+		sb.WriteString(fmt.Sprintf(" tab_index=%d", *action.TabIndex))
+	}
+	if action.FileURL != nil {
+		sb.WriteString(" file_url=" + *action.FileURL)
+	}
+
+	switch action.Status {
+	case entity.ActionStatusSuccess:
+		sb.WriteString(" → 成功")
+	case entity.ActionStatusFailed:
+		sb.WriteString(" → 失败")
+		if action.ErrorMessage != nil && *action.ErrorMessage != "" {
+			sb.WriteString(": " + truncateString(*action.ErrorMessage, actionValueMaxLen))
+		}
+	case entity.ActionStatusSkipped:
+		sb.WriteString(" → 已跳过")
+		if action.ErrorMessage != nil && *action.ErrorMessage != "" {
+			sb.WriteString(": " + truncateString(*action.ErrorMessage, actionValueMaxLen))
+		}
+	case entity.ActionStatusRejected:
+		sb.WriteString(" → 用户拒绝执行")
+	case entity.ActionStatusAwaitingConfirm:
+		sb.WriteString(" → 等待用户确认")
+	default:
+		sb.WriteString(" → 执行中")
+	}
+
+	if action.ResultURL != nil && *action.ResultURL != "" {
+		sb.WriteString(" | 结果页面: " + *action.ResultURL)
+	}
+	if action.Extracted != nil && *action.Extracted != "" {
+		sb.WriteString(fmt.Sprintf(" | 提取内容: \"%s\"", truncateString(*action.Extracted, extractedContentMaxLen)))
+	}
 
 	return sb.String()
 }
@@ -610,15 +1373,26 @@ func (s *BrowserAgentService) parseAction(resp string) (*ws.Action, error) {
 	return &action, nil
 }
 
-func (s *BrowserAgentService) validateAction(action *ws.Action) error {
+// validateAction 校验操作参数，跳转类操作与上传文件同时校验 URL 访问策略，违反策略时返回 *urlPolicyViolation
+func (s *BrowserAgentService) validateAction(action *ws.Action, policies []*urlPolicy) error {
 	validActions := map[string]bool{
-		"goto":        true,
-		"click":       true,
-		"input":       true,
-		"select":      true,
-		"scroll":      true,
-		"wait":        true,
-		"finish_task": true,
+		"goto":          true,
+		"click":         true,
+		"input":         true,
+		"select":        true,
+		"scroll":        true,
+		"wait":          true,
+		"hover":         true,
+		"press_key":     true,
+		"switch_tab":    true,
+		"open_tab":      true,
+		"close_tab":     true,
+		"go_back":       true,
+		"upload_file":   true,
+		"extract":       true,
+		"screenshot":    true,
+		"finish_task":   true,
+		"submit_result": true,
 	}
 
 	if !validActions[action.Action] {
@@ -630,9 +1404,9 @@ func (s *BrowserAgentService) validateAction(action *ws.Action) error {
 		if action.URL == nil || *action.URL == "" {
 			return errors.New("goto 缺少 url")
 		}
-	case "click":
+	case "click", "hover", "extract":
 		if action.Selector == nil || *action.Selector == "" {
-			return errors.New("click 缺少 selector")
+			return fmt.Errorf("%s 缺少 selector", action.Action)
 		}
 	case "input", "select":
 		if action.Selector == nil || action.Value == nil {
@@ -646,6 +1420,43 @@ func (s *BrowserAgentService) validateAction(action *ws.Action) error {
 		if action.Timeout == nil {
 			return errors.New("wait 缺少 timeout")
 		}
+	case "press_key":
+		if action.Key == nil || *action.Key == "" {
+			return errors.New("press_key 缺少 key")
+		}
+	case "open_tab":
+		if action.URL == nil || *action.URL == "" {
+			return errors.New("open_tab 缺少 url")
+		}
+	case "switch_tab":
+		if action.TabIndex == nil || *action.TabIndex < 0 {
+			return errors.New("switch_tab 缺少合法的 tab_index")
+		}
+	case "close_tab":
+		if action.TabIndex != nil && *action.TabIndex < 0 {
+			return errors.New("close_tab 的 tab_index 不合法")
+		}
+	case "upload_file":
+		if action.Selector == nil || *action.Selector == "" || action.FileURL == nil || *action.FileURL == "" {
+			return errors.New("upload_file 缺少 selector 或 file_url")
+		}
+	case "submit_result":
+		if action.Data == nil {
+			return errors.New("submit_result 缺少 data")
+		}
+	}
+
+	var targetURL string
+	switch action.Action {
+	case "goto", "open_tab":
+		targetURL = *action.URL
+	case "upload_file":
+		targetURL = *action.FileURL
+	}
+	if targetURL != "" {
+		if reason := checkURLPolicies(targetURL, policies); reason != "" {
+			return &urlPolicyViolation{Action: action, Reason: reason}
+		}
 	}
 
 	return nil
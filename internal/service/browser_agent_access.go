@@ -0,0 +1,124 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/repository"
+	"Art-Design-Backend/pkg/errors"
+	"context"
+	"slices"
+
+	"go.uber.org/zap"
+)
+
+// isAdmin 用户拥有配置的管理员角色时可访问其他用户的会话与任务，并可修改全局配置
+func (s *BrowserAgentService) isAdmin(c context.Context, userID int64) bool {
+	return hasAdminRole(c, s.RoleRepo, s.BrowserAgentConfig.AdminRoleCodes, userID)
+}
+
+// hasAdminRole 用户是否拥有 adminRoleCodes 中的任一角色，查询角色失败时按普通用户处理
+func hasAdminRole(c context.Context, roleRepo *repository.RoleRepo, adminRoleCodes []string, userID int64) bool {
+	roles, err := roleRepo.GetRoleListByUserID(c, userID)
+	if err != nil {
+		zap.L().Warn("查询用户角色失败，按普通用户处理", zap.Int64("userID", userID), zap.Error(err))
+		return false
+	}
+	for _, role := range roles {
+		if slices.Contains(adminRoleCodes, role.Code) {
+			return true
+		}
+	}
+	return false
+}
+
+// getOwnConversation 获取用户的会话，会话不存在或属于其他用户且当前用户不是管理员时均返回 404，不暴露会话是否存在
+func (s *BrowserAgentService) getOwnConversation(c context.Context, userID, conversationID int64) (*entity.BrowserAgentConversation, error) {
+	conv, err := s.BrowserAgentRepo.GetConversationByID(c, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conv.CreateBy != userID && !s.isAdmin(c, userID) {
+		zap.L().Warn("拒绝访问其他用户的会话",
+			zap.Int64("userID", userID),
+			zap.Int64("conversationID", conversationID),
+			zap.Int64("ownerID", conv.CreateBy),
+		)
+		return nil, errors.NewNotFoundError("会话不存在")
+	}
+	return conv, nil
+}
+
+// getOwnMessage 获取用户会话中的任务，返回任务及所属会话，权限规则同 getOwnConversation
+func (s *BrowserAgentService) getOwnMessage(c context.Context, userID, messageID int64) (*entity.BrowserAgentMessage, *entity.BrowserAgentConversation, error) {
+	msg, err := s.BrowserAgentRepo.GetMessageByID(c, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	conv, err := s.getOwnConversation(c, userID, msg.ConversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, conv, nil
+}
+
+// getOwnerConversation 获取用户作为所有者的会话，用于执行任务及修改会话
+//
+// 执行任务会使用会话所有者的凭据，管理员对其他用户的会话只读，返回 403；
+// 其余情况同 getOwnConversation
+func (s *BrowserAgentService) getOwnerConversation(c context.Context, userID, conversationID int64) (*entity.BrowserAgentConversation, error) {
+	conv, err := s.getOwnConversation(c, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conv.CreateBy != userID {
+		zap.L().Warn("拒绝管理员操作其他用户的会话",
+			zap.Int64("userID", userID),
+			zap.Int64("conversationID", conversationID),
+			zap.Int64("ownerID", conv.CreateBy),
+		)
+		return nil, errors.NewForbiddenError("仅会话所有者可执行该操作")
+	}
+	return conv, nil
+}
+
+// getOwnerMessage 获取用户作为所有者的任务，权限规则同 getOwnerConversation
+func (s *BrowserAgentService) getOwnerMessage(c context.Context, userID, messageID int64) (*entity.BrowserAgentMessage, *entity.BrowserAgentConversation, error) {
+	msg, err := s.BrowserAgentRepo.GetMessageByID(c, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	conv, err := s.getOwnerConversation(c, userID, msg.ConversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, conv, nil
+}
+
+// getOwnerAction 获取用户任务中的操作，返回操作及所属任务，权限规则同 getOwnerMessage
+//
+// messageID 与 actionID 均由客户端上报，操作不属于该任务时与操作不存在一样返回 404，
+// 避免借自己的任务修改其他用户的操作
+func (s *BrowserAgentService) getOwnerAction(c context.Context, userID, messageID, actionID int64) (*entity.BrowserAgentAction, *entity.BrowserAgentMessage, error) {
+	action, err := s.BrowserAgentRepo.GetActionByID(c, actionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if action.MessageID != messageID {
+		zap.L().Warn("拒绝访问不属于该任务的操作",
+			zap.Int64("userID", userID),
+			zap.Int64("messageID", messageID),
+			zap.Int64("actionID", actionID),
+		)
+		return nil, nil, errors.NewNotFoundError("操作不存在")
+	}
+	msg, _, err := s.getOwnerMessage(c, userID, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return action, msg, nil
+}
+
+// CheckConversationAccess 校验用户是会话所有者，供 WebSocket 升级前调用
+func (s *BrowserAgentService) CheckConversationAccess(c context.Context, userID, conversationID int64) error {
+	_, err := s.getOwnerConversation(c, userID, conversationID)
+	return err
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/repository"
+	"Art-Design-Backend/internal/repository/cache"
+	"Art-Design-Backend/pkg/constant/rediskey"
+	"Art-Design-Backend/pkg/errors"
+	"Art-Design-Backend/pkg/redisx"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ownerID = 10
+	otherID = 20
+	adminID = 30
+)
+
+// withAdmin 为服务设置角色仓库，adminID 拥有管理员角色，其余用户没有角色
+func withAdmin(t *testing.T, s *BrowserAgentService) {
+	t.Helper()
+	s.RoleRepo = newRoleRepo(t)
+	s.BrowserAgentConfig.AdminRoleCodes = []string{"admin"}
+}
+
+// newRoleRepo 创建从 miniredis 缓存读取用户角色的角色仓库，adminID 拥有 admin 角色
+func newRoleRepo(t *testing.T) *repository.RoleRepo {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	roles, _ := sonic.MarshalString([]*entity.Role{{Code: "admin"}})
+	mr.Set(fmt.Sprintf("%s%d", rediskey.UserRoleList, adminID), roles)
+	for _, userID := range []int64{ownerID, otherID} {
+		mr.Set(fmt.Sprintf("%s%d", rediskey.UserRoleList, userID), "[]")
+	}
+
+	rdb := redisx.NewRedisWrapper(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Second, time.Hour, time.Hour)
+	return &repository.RoleRepo{UserCache: cache.NewUserCache(rdb)}
+}
+
+// expectOwnMessage 期望依次查询任务 messageID 及其所属会话，任务处于 state 状态，会话属于 ownerID
+func expectOwnMessage(mock sqlmock.Sqlmock, messageID int64, state string) {
+	mock.ExpectQuery(`SELECT \* FROM "browser_agent_message" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "conversation_id", "state"}).AddRow(messageID, 5, state))
+	mock.ExpectQuery(`SELECT \* FROM "browser_agent_conversation" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_by"}).AddRow(5, ownerID))
+}
+
+func wantAccessError(t *testing.T, err error, code int) {
+	t.Helper()
+	var accessErr *errors.AccessError
+	if !stderrors.As(err, &accessErr) || accessErr.Code != code {
+		t.Fatalf("err = %v, want AccessError %d", err, code)
+	}
+}
+
+func TestGetOwnMessage(t *testing.T) {
+	tests := []struct {
+		name          string
+		userID        int64
+		wantCode      int // 只读访问
+		wantOwnerCode int // 执行任务
+	}{
+		{name: "会话所有者", userID: ownerID},
+		{name: "管理员只读", userID: adminID, wantOwnerCode: 403},
+		{name: "其他用户", userID: otherID, wantCode: 404, wantOwnerCode: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			withAdmin(t, s)
+
+			expectOwnMessage(mock, 1, entity.MessageStateRunning)
+			_, _, err := s.getOwnMessage(context.Background(), tt.userID, 1)
+			if tt.wantCode != 0 {
+				wantAccessError(t, err, tt.wantCode)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			expectOwnMessage(mock, 1, entity.MessageStateRunning)
+			_, _, err = s.getOwnerMessage(context.Background(), tt.userID, 1)
+			if tt.wantOwnerCode != 0 {
+				wantAccessError(t, err, tt.wantOwnerCode)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestGetOwnerAction(t *testing.T) {
+	tests := []struct {
+		name            string
+		userID          int64
+		actionMessageID int64
+		wantCode        int
+	}{
+		{name: "任务所有者", userID: ownerID, actionMessageID: 1},
+		{name: "管理员不能执行其他用户的任务", userID: adminID, actionMessageID: 1, wantCode: 403},
+		{name: "其他用户的任务", userID: otherID, actionMessageID: 1, wantCode: 404},
+		{name: "操作不属于该任务", userID: ownerID, actionMessageID: 2, wantCode: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			withAdmin(t, s)
+
+			mock.ExpectQuery(`SELECT \* FROM "browser_agent_action" WHERE id = \$1`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "message_id"}).AddRow(99, tt.actionMessageID))
+			if tt.actionMessageID == 1 {
+				expectOwnMessage(mock, 1, entity.MessageStateRunning)
+			}
+
+			action, message, err := s.getOwnerAction(context.Background(), tt.userID, 1, 99)
+			if tt.wantCode != 0 {
+				wantAccessError(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action.ID != 99 || message.ID != 1 {
+				t.Errorf("getOwnerAction() = %d, %d, want 99, 1", action.ID, message.ID)
+			}
+		})
+	}
+}
+
+// TestHandleResultForeignAction 上报其他用户的操作时不修改该操作
+func TestHandleResultForeignAction(t *testing.T) {
+	s, mock := newMockService(t)
+	withAdmin(t, s)
+
+	// 操作 99 属于其他用户的任务 2，客户端上报时填写自己的任务 1
+	mock.ExpectQuery(`SELECT \* FROM "browser_agent_action" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id"}).AddRow(99, 2))
+
+	_, _, err := s.HandleResult(context.Background(), ownerID, &ws.ClientMessage{
+		MessageID: 1,
+		ActionID:  99,
+		Success:   true,
+	})
+	wantAccessError(t, err, 404)
+}
+
+// TestHandleTaskByAdmin 管理员不能执行其他用户的任务，不会读取所有者的凭据
+func TestHandleTaskByAdmin(t *testing.T) {
+	s, mock := newMockService(t)
+	withAdmin(t, s)
+
+	expectOwnMessage(mock, 1, entity.MessageStateRunning)
+	_, err := s.HandleTask(context.Background(), adminID, 1, &ws.PageState{URL: "https://example.com/"})
+	wantAccessError(t, err, 403)
+}
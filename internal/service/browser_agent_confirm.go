@@ -0,0 +1,214 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/repository/db"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// confirmReason 判断操作是否需要用户确认，返回确认原因，无需确认时返回空字符串
+//
+// 需要确认的场景：
+//  1. 点击文本包含提交、支付、删除等关键词的元素
+//  2. 向密码框输入内容
+//  3. 跳转到允许列表以外的域名（未配置允许列表时不校验）
+func (s *BrowserAgentService) confirmReason(action *ws.Action, pageState *ws.PageState) string {
+	switch action.Action {
+	case "click":
+		elem := findElementBySelector(pageState, action.Selector)
+		if elem == nil {
+			return ""
+		}
+		text := strings.ToLower(elementDisplayText(elem))
+		for _, keyword := range s.BrowserAgentConfig.ConfirmKeywords {
+			if keyword != "" && strings.Contains(text, strings.ToLower(keyword)) {
+				return fmt.Sprintf("点击「%s」可能提交表单、产生支付或删除数据", truncateString(elementDisplayText(elem), actionValueMaxLen))
+			}
+		}
+	case "input":
+		elem := findElementBySelector(pageState, action.Selector)
+		if elem != nil && elem.Type != nil && strings.EqualFold(*elem.Type, "password") {
+			return "向密码框输入内容"
+		}
+	case "goto", "open_tab":
+		if action.URL == nil || len(s.BrowserAgentConfig.ConfirmAllowedDomains) == 0 {
+			return ""
+		}
+		u, err := url.Parse(*action.URL)
+		if err != nil || u.Hostname() == "" {
+			return fmt.Sprintf("无法识别的跳转地址: %s", *action.URL)
+		}
+		if !matchDomain(u.Hostname(), s.BrowserAgentConfig.ConfirmAllowedDomains) {
+			return fmt.Sprintf("跳转到允许列表以外的域名: %s", u.Hostname())
+		}
+	}
+	return ""
+}
+
+func findElementBySelector(pageState *ws.PageState, selector *string) *ws.PageElement {
+	if pageState == nil || selector == nil {
+		return nil
+	}
+	for i := range pageState.Elements {
+		if pageState.Elements[i].Selector == *selector {
+			return &pageState.Elements[i]
+		}
+	}
+	return nil
+}
+
+// elementDisplayText 元素的可见文本，按钮类 input 的文本在 value 中
+func elementDisplayText(elem *ws.PageElement) string {
+	parts := []string{elem.Text}
+	if elem.Label != nil {
+		parts = append(parts, *elem.Label)
+	}
+	if elem.Tag == "input" && elem.Value != nil {
+		parts = append(parts, *elem.Value)
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// matchDomain 域名与列表中的某项相同或为其子域名时返回 true
+func matchDomain(host string, domains []string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleConfirm 处理用户对敏感操作的确认
+//
+//   - approve：按原操作执行
+//   - edit：按用户修改后的操作执行
+//   - reject：操作不执行，携带用户反馈重新决策下一步
+func (s *BrowserAgentService) HandleConfirm(c context.Context, userID int64, msg *ws.ClientMessage) (*ws.Action, bool, error) {
+	zap.L().Info("========== 收到操作确认 ==========",
+		zap.Int64("actionID", msg.ActionID),
+		zap.Int64("userID", userID),
+		zap.String("decision", msg.Decision),
+	)
+
+	action, err := s.BrowserAgentRepo.GetActionByID(c, msg.ActionID)
+	if err != nil {
+		return nil, false, err
+	}
+	message, _, err := s.getOwnerMessage(c, userID, action.MessageID)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = checkMessageRunnable(message); err != nil {
+		return nil, false, err
+	}
+	if action.Status != entity.ActionStatusAwaitingConfirm {
+		return nil, false, errors.New("该操作不在待确认状态")
+	}
+
+	confirmation := &db.ActionConfirmation{ConfirmedBy: userID, ConfirmedAt: time.Now()}
+
+	switch msg.Decision {
+	case ws.DecisionApprove:
+		confirmation.Decision = entity.ConfirmDecisionApproved
+		if err = s.BrowserAgentRepo.ConfirmAction(c, action.ID, entity.ActionStatusPending, confirmation, nil); err != nil {
+			return nil, false, err
+		}
+		next := entityToWSAction(action)
+		s.logAction("用户批准", next)
+		secrets, err := s.loadMessageSecrets(c, action.MessageID)
+		if err != nil {
+			return nil, false, err
+		}
+		revealed, err := revealSecrets(next, secrets)
+		return revealed, false, err
+
+	case ws.DecisionEdit:
+		if msg.EditedAction == nil {
+			return nil, false, errors.New("修改操作时 edited_action 不能为空")
+		}
+		edited := *msg.EditedAction
+		if edited.Action == "" {
+			edited.Action = action.ActionType
+		}
+		if edited.Action == "finish_task" || edited.Action == "submit_result" {
+			return nil, false, fmt.Errorf("不能修改为 %s 操作", edited.Action)
+		}
+		if err = resolveElementIndex(&edited, msg.PageState); err != nil {
+			return nil, false, err
+		}
+		policies, err := s.loadMessageURLPolicies(c, action.MessageID)
+		if err != nil {
+			return nil, false, err
+		}
+		if err = s.validateAction(&edited, policies); err != nil {
+			return nil, false, err
+		}
+		// 用户直接填写的凭据真实值同样以占位符保存
+		secrets, err := s.loadMessageSecrets(c, action.MessageID)
+		if err != nil {
+			return nil, false, err
+		}
+		maskSecretPtr(edited.Value, secrets)
+		if err = checkSecretRefs(&edited, secrets); err != nil {
+			return nil, false, err
+		}
+		confirmation.Decision = entity.ConfirmDecisionEdited
+		if err = s.BrowserAgentRepo.ConfirmAction(c, action.ID, entity.ActionStatusPending, confirmation,
+			s.wsActionToEntity(action.MessageID, &edited)); err != nil {
+			return nil, false, err
+		}
+		edited.ActionID = action.ID
+		edited.ConfirmReason = ""
+		s.logAction("用户修改", &edited)
+		revealed, err := revealSecrets(&edited, secrets)
+		return revealed, false, err
+
+	case ws.DecisionReject:
+		if msg.PageState == nil {
+			return nil, false, errors.New("拒绝操作时需携带当前页面状态")
+		}
+		confirmation.Decision = entity.ConfirmDecisionRejected
+		if err = s.BrowserAgentRepo.ConfirmAction(c, action.ID, entity.ActionStatusRejected, confirmation, nil); err != nil {
+			return nil, false, err
+		}
+		feedback := fmt.Sprintf("用户拒绝执行上一步 %s 操作", action.ActionType)
+		if msg.Comment != "" {
+			feedback += "，原因: " + msg.Comment
+		}
+		return s.planNextAction(c, action.MessageID, msg.Task, msg.PageState, feedback)
+
+	default:
+		return nil, false, fmt.Errorf("未知的确认结果: %s", msg.Decision)
+	}
+}
+
+// entityToWSAction 将已保存的操作还原为下发给客户端的指令
+func entityToWSAction(a *entity.BrowserAgentAction) *ws.Action {
+	action := &ws.Action{
+		ActionID: a.ID,
+		Action:   a.ActionType,
+		Selector: a.Selector,
+		Value:    a.Value,
+		URL:      a.URL,
+		Distance: a.Distance,
+		Timeout:  a.Timeout,
+		Key:      a.Key,
+		TabIndex: a.TabIndex,
+		FileURL:  a.FileURL,
+	}
+	if a.Rationale != nil {
+		action.Rationale = *a.Rationale
+	}
+	return action
+}
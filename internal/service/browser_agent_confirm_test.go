@@ -0,0 +1,116 @@
+package service
+
+import (
+	"Art-Design-Backend/config"
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestConfirmReason(t *testing.T) {
+	password := "password"
+	page := &ws.PageState{Elements: []ws.PageElement{
+		{Selector: "#pay", Tag: "button", Text: "立即支付"},
+		{Selector: "#next", Tag: "button", Text: "下一页"},
+		{Selector: "#pwd", Tag: "input", Type: &password},
+	}}
+
+	s := &BrowserAgentService{BrowserAgentConfig: &config.BrowserAgent{
+		ConfirmKeywords:       []string{"支付", "删除"},
+		ConfirmAllowedDomains: []string{"example.com"},
+	}}
+
+	tests := []struct {
+		name    string
+		action  ws.Action
+		confirm bool
+	}{
+		{name: "点击支付按钮", action: ws.Action{Action: "click", Selector: ptr("#pay")}, confirm: true},
+		{name: "点击普通按钮", action: ws.Action{Action: "click", Selector: ptr("#next")}},
+		{name: "点击页面上不存在的元素", action: ws.Action{Action: "click", Selector: ptr("#missing")}},
+		{name: "向密码框输入", action: ws.Action{Action: "input", Selector: ptr("#pwd"), Value: ptr("{{secret:password}}")}, confirm: true},
+		{name: "跳转到允许的子域名", action: ws.Action{Action: "goto", URL: ptr("https://shop.example.com/cart")}},
+		{name: "跳转到其他域名", action: ws.Action{Action: "open_tab", URL: ptr("https://evil.test/")}, confirm: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason := s.confirmReason(&tt.action, page); (reason != "") != tt.confirm {
+				t.Errorf("confirmReason() = %q, want confirm %v", reason, tt.confirm)
+			}
+		})
+	}
+}
+
+func TestCheckMessageRunnable(t *testing.T) {
+	for _, state := range []string{
+		entity.MessageStateFinished,
+		entity.MessageStateError,
+		entity.MessageStateAbortedLoop,
+		entity.MessageStateQueued,
+		entity.MessageStateExpired,
+		entity.MessageStateCancelled,
+		entity.MessageStatePaused,
+	} {
+		if err := checkMessageRunnable(&entity.BrowserAgentMessage{State: state}); err == nil {
+			t.Errorf("%s 状态的任务不应继续执行", state)
+		}
+	}
+	if err := checkMessageRunnable(&entity.BrowserAgentMessage{State: entity.MessageStateRunning}); err != nil {
+		t.Errorf("运行中的任务应可继续执行: %v", err)
+	}
+}
+
+func TestHandleConfirm(t *testing.T) {
+	tests := []struct {
+		name         string
+		messageState string
+		actionStatus string
+		wantErr      error // 为 nil 时期望批准成功
+	}{
+		{name: "批准待确认的操作", messageState: entity.MessageStateRunning, actionStatus: entity.ActionStatusAwaitingConfirm},
+		{name: "任务已暂停", messageState: entity.MessageStatePaused, actionStatus: entity.ActionStatusAwaitingConfirm, wantErr: ws.ErrTaskPaused},
+		{name: "任务已取消", messageState: entity.MessageStateCancelled, actionStatus: entity.ActionStatusAwaitingConfirm, wantErr: ws.ErrTaskCancelled},
+		{name: "任务已结束", messageState: entity.MessageStateError, actionStatus: entity.ActionStatusAwaitingConfirm, wantErr: errors.New("任务已结束")},
+		{name: "操作已确认过", messageState: entity.MessageStateRunning, actionStatus: entity.ActionStatusPending, wantErr: errors.New("该操作不在待确认状态")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+
+			mock.ExpectQuery(`SELECT \* FROM "browser_agent_action" WHERE id = \$1`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "action_type", "selector", "status"}).
+					AddRow(99, 1, "click", "#pay", tt.actionStatus))
+			expectOwnMessage(mock, 1, tt.messageState)
+			if tt.wantErr == nil {
+				mock.ExpectExec(`UPDATE "browser_agent_action" SET .* WHERE id = \$\d+ AND status = \$\d+`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectOwnMessage(mock, 1, tt.messageState)
+				mock.ExpectQuery(`SELECT \* FROM "browser_agent_secret" WHERE user_id = \$1`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			}
+
+			action, finished, err := s.HandleConfirm(context.Background(), ownerID, &ws.ClientMessage{
+				ActionID: 99,
+				Decision: ws.DecisionApprove,
+			})
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if finished || action.ActionID != 99 || action.Action != "click" || action.ConfirmReason != "" {
+				t.Errorf("HandleConfirm() = %+v, %v, want 原操作", action, finished)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/internal/repository/db"
+	"Art-Design-Backend/pkg/authutils"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// checkMessageRunnable 任务不在运行中时返回错误，不再下发后续操作；已取消或暂停时返回对应错误
+func checkMessageRunnable(msg *entity.BrowserAgentMessage) error {
+	switch msg.State {
+	case entity.MessageStateRunning:
+		return nil
+	case entity.MessageStateCancelled:
+		return ws.ErrTaskCancelled
+	case entity.MessageStatePaused:
+		return ws.ErrTaskPaused
+	case entity.MessageStateExpired:
+		return errors.New("定时任务已过期")
+	case entity.MessageStateQueued:
+		return errors.New("任务尚未下发")
+	}
+	return errors.New("任务已结束")
+}
+
+// endMessage 将运行中的任务更新为结束状态 state 并记录原因；
+// 任务已被取消、暂停或已结束时不覆盖当前状态，返回对应错误
+func (s *BrowserAgentService) endMessage(c context.Context, messageID int64, state, errMsg string) error {
+	ok, err := s.BrowserAgentRepo.TransitionMessageState(c, messageID, []string{entity.MessageStateRunning}, state, errMsg)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.notRunningError(c, messageID)
+	}
+	return nil
+}
+
+// notRunningError 任务状态因已不在运行中而更新失败时，返回任务当前状态对应的错误
+func (s *BrowserAgentService) notRunningError(c context.Context, messageID int64) error {
+	msg, err := s.BrowserAgentRepo.GetMessageByID(c, messageID)
+	if err != nil {
+		return err
+	}
+	if err = checkMessageRunnable(msg); err != nil {
+		return err
+	}
+	return errors.New("任务状态已变化，请刷新后重试")
+}
+
+// CancelMessage 取消任务：运行中、暂停或排队的任务均可取消，未结束的操作标记为已跳过
+func (s *BrowserAgentService) CancelMessage(c context.Context, userID, messageID int64) error {
+	if _, _, err := s.getOwnMessage(c, userID, messageID); err != nil {
+		return err
+	}
+
+	return s.GormTX.Transaction(c, func(ctx context.Context) error {
+		ok, err := s.BrowserAgentRepo.TransitionMessageState(ctx, messageID, []string{
+			entity.MessageStateRunning,
+			entity.MessageStatePaused,
+			entity.MessageStateQueued,
+		}, entity.MessageStateCancelled, "用户取消")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("任务已结束，无法取消")
+		}
+		zap.L().Info("任务已取消", zap.Int64("messageID", messageID), zap.Int64("userID", userID))
+		return s.BrowserAgentRepo.SkipOpenActions(ctx, messageID)
+	})
+}
+
+// PauseMessage 暂停运行中的任务，客户端正在执行的操作完成后不再下发后续操作
+func (s *BrowserAgentService) PauseMessage(c context.Context, userID, messageID int64) error {
+	if _, _, err := s.getOwnMessage(c, userID, messageID); err != nil {
+		return err
+	}
+
+	ok, err := s.BrowserAgentRepo.CompareAndUpdateMessageState(c, messageID, entity.MessageStateRunning, entity.MessageStatePaused)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("只能暂停运行中的任务")
+	}
+	zap.L().Info("任务已暂停", zap.Int64("messageID", messageID), zap.Int64("userID", userID))
+	return nil
+}
+
+// ResumeMessage 恢复任务：已暂停的任务恢复运行，运行中的任务用于客户端断线重连后续接执行
+//
+// msg.ActionID 为客户端最后回传结果的操作ID：
+//   - 存在此后下发但客户端未执行的操作时原样重新下发，待确认的操作重新请求确认
+//   - 否则根据客户端回传的当前页面状态重新规划下一步
+func (s *BrowserAgentService) ResumeMessage(c context.Context, userID int64, msg *ws.ClientMessage) (*ws.Action, bool, error) {
+	message, _, err := s.getOwnerMessage(c, userID, msg.MessageID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if message.State == entity.MessageStatePaused {
+		ok, err := s.BrowserAgentRepo.CompareAndUpdateMessageState(c, message.ID, entity.MessageStatePaused, entity.MessageStateRunning)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, errors.New("任务状态已变化，请刷新后重试")
+		}
+		zap.L().Info("任务已恢复", zap.Int64("messageID", message.ID), zap.Int64("userID", userID))
+	} else if err = checkMessageRunnable(message); err != nil {
+		return nil, false, err
+	}
+
+	secrets, err := s.loadMessageSecrets(c, message.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	action, err := s.replayPendingAction(c, message.ID, msg.ActionID)
+	if err != nil {
+		return nil, false, err
+	}
+	if action != nil {
+		revealed, err := revealSecrets(action, secrets)
+		return revealed, false, err
+	}
+
+	if msg.PageState == nil {
+		return nil, false, errors.New("页面状态为空")
+	}
+	maskPageState(msg.PageState, secrets)
+
+	zap.L().Info("没有可重发的操作，根据当前页面重新规划", zap.Int64("messageID", message.ID))
+	return s.planNextAction(c, message.ID, msg.Task, msg.PageState, "")
+}
+
+// replayPendingAction 返回需要重新下发的操作，没有时返回 nil
+//
+// 不晚于 lastAckID 的未完成操作客户端已执行但结果在断线时丢失，无法判断执行结果，标记为已跳过
+func (s *BrowserAgentService) replayPendingAction(c context.Context, messageID, lastAckID int64) (*ws.Action, error) {
+	pending, err := s.BrowserAgentRepo.GetPendingActionsByMessageID(c, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range pending {
+		if a.ID <= lastAckID {
+			errMsg := "客户端断线，执行结果丢失"
+			if err = s.BrowserAgentRepo.UpdateActionStatus(c, a.ID, entity.ActionStatusSkipped, &db.ActionResult{ErrorMessage: &errMsg}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		action := entityToWSAction(a)
+		if a.Status == entity.ActionStatusAwaitingConfirm && a.ConfirmReason != nil {
+			action.ConfirmReason = *a.ConfirmReason
+		}
+		s.logAction("断线重发", action)
+		return action, nil
+	}
+	return nil, nil
+}
+
+// =========================
+// 任务控制接口
+// =========================
+
+// CancelTask 取消任务，并通知会话所有者在线的客户端停止执行
+func (s *BrowserAgentService) CancelTask(c *gin.Context, req *request.MessageControlRequest) error {
+	userID := authutils.GetUserID(c)
+	_, conv, err := s.getOwnMessage(c, userID, int64(req.MessageID))
+	if err != nil {
+		return err
+	}
+	if err = s.CancelMessage(c, userID, int64(req.MessageID)); err != nil {
+		return err
+	}
+	// 管理员可操作其他用户的任务，执行任务的是会话所有者的客户端
+	s.Hub.SendToUser(conv.CreateBy, &ws.ServerMessage{
+		Type:      ws.ServerMessageCancel,
+		MessageID: int64(req.MessageID),
+		Message:   ws.ErrTaskCancelled.Error(),
+	})
+	return nil
+}
+
+// PauseTask 暂停任务，并通知会话所有者在线的客户端
+func (s *BrowserAgentService) PauseTask(c *gin.Context, req *request.MessageControlRequest) error {
+	userID := authutils.GetUserID(c)
+	_, conv, err := s.getOwnMessage(c, userID, int64(req.MessageID))
+	if err != nil {
+		return err
+	}
+	if err = s.PauseMessage(c, userID, int64(req.MessageID)); err != nil {
+		return err
+	}
+	s.Hub.SendToUser(conv.CreateBy, &ws.ServerMessage{
+		Type:      ws.ServerMessagePause,
+		MessageID: int64(req.MessageID),
+		Message:   ws.ErrTaskPaused.Error(),
+	})
+	return nil
+}
+
+// ResumeTask 请求一个在线客户端携带当前页面状态发送 resume 消息，由其继续执行已暂停的任务
+//
+// 规划下一步需要最新的页面状态，因此状态恢复在客户端发送 resume 消息时进行
+func (s *BrowserAgentService) ResumeTask(c *gin.Context, req *request.MessageControlRequest) error {
+	msg, conv, err := s.getOwnMessage(c, authutils.GetUserID(c), int64(req.MessageID))
+	if err != nil {
+		return err
+	}
+	if msg.State != entity.MessageStatePaused {
+		return errors.New("只能恢复已暂停的任务")
+	}
+
+	client := s.Hub.PickClient(conv.CreateBy, conv.ID, conv.BrowserType)
+	if !s.Hub.Send(client, &ws.ServerMessage{
+		Type:           ws.ServerMessageResume,
+		MessageID:      msg.ID,
+		ConversationID: msg.ConversationID,
+		Task:           msg.Content,
+	}) {
+		return errors.New("没有在线的浏览器客户端，无法恢复任务")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// TestFinishMessage 模型决策期间任务被取消或暂停时，结束任务不覆盖用户设置的状态
+func TestFinishMessage(t *testing.T) {
+	submit := &ws.Action{Action: "submit_result", Data: map[string]any{"price": "5999"}}
+
+	tests := []struct {
+		name    string
+		action  *ws.Action
+		state   string // 更新时任务所处的状态
+		wantErr error
+	}{
+		{name: "完成运行中的任务", state: entity.MessageStateRunning},
+		{name: "保存采集结果", action: submit, state: entity.MessageStateRunning},
+		{name: "决策期间被取消", state: entity.MessageStateCancelled, wantErr: ws.ErrTaskCancelled},
+		{name: "决策期间被暂停", state: entity.MessageStatePaused, wantErr: ws.ErrTaskPaused},
+		{name: "提交结果时已被取消", action: submit, state: entity.MessageStateCancelled, wantErr: ws.ErrTaskCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+
+			rows := int64(0)
+			if tt.state == entity.MessageStateRunning {
+				rows = 1
+			}
+			mock.ExpectExec(`UPDATE "browser_agent_message" SET .*"state"=\$\d+ WHERE id = \$\d+ AND state (= \$\d+|IN \(\$\d+\))`).
+				WillReturnResult(sqlmock.NewResult(0, rows))
+			if rows == 0 {
+				mock.ExpectQuery(`SELECT \* FROM "browser_agent_message" WHERE id = \$1`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(1, tt.state))
+			}
+
+			if err := s.finishMessage(context.Background(), 1, tt.action); !errors.Is(err, tt.wantErr) {
+				t.Errorf("finishMessage() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCreateActionAfterCancel 规划期间任务被取消或暂停时不保存、不下发规划出的操作
+func TestCreateActionAfterCancel(t *testing.T) {
+	for state, wantErr := range map[string]error{
+		entity.MessageStateCancelled: ws.ErrTaskCancelled,
+		entity.MessageStatePaused:    ws.ErrTaskPaused,
+	} {
+		t.Run(state, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectQuery(`SELECT \* FROM "browser_agent_message" WHERE id = \$1`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(1, state))
+
+			action := &ws.Action{Action: "click", Selector: ptr("#next")}
+			if err := s.createAction(context.Background(), &decisionInput{MessageID: 1}, action, "下一步决策"); !errors.Is(err, wantErr) {
+				t.Errorf("createAction() error = %v, want %v", err, wantErr)
+			}
+			if action.ActionID != 0 {
+				t.Errorf("操作不应保存，ActionID = %d", action.ActionID)
+			}
+		})
+	}
+}
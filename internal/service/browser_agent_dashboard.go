@@ -1,107 +1,198 @@
 package service
 
 import (
+	"Art-Design-Backend/config"
 	"Art-Design-Backend/internal/model/common"
 	"Art-Design-Backend/internal/model/entity"
 	"Art-Design-Backend/internal/model/query"
+	"Art-Design-Backend/internal/model/request"
 	"Art-Design-Backend/internal/model/response"
 	"Art-Design-Backend/internal/repository"
+	"Art-Design-Backend/internal/repository/db"
+	"Art-Design-Backend/pkg/authutils"
+	"Art-Design-Backend/pkg/errors"
 	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/jinzhu/copier"
+	"github.com/shopspring/decimal"
 )
 
 type BrowserAgentDashboardService struct {
-	BrowserAgentRepo *repository.BrowserAgentRepo
+	BrowserAgentRepo   *repository.BrowserAgentRepo
+	RoleRepo           *repository.RoleRepo
+	BrowserAgentConfig *config.BrowserAgent
+}
+
+// CheckAdmin 管理员仪表盘统计全部用户的数据，仅管理员可访问
+func (s *BrowserAgentDashboardService) CheckAdmin(ctx context.Context, userID int64) error {
+	if !hasAdminRole(ctx, s.RoleRepo, s.BrowserAgentConfig.AdminRoleCodes, userID) {
+		return errors.NewForbiddenError("仅管理员可访问")
+	}
+	return nil
+}
+
+// bucketFetcher 按时间桶统计的查询，userID 为 0 时统计全部用户
+type bucketFetcher func(ctx context.Context, userID int64, sr *db.StatsRange) ([]db.BucketCount, error)
+
+// rangeSeries 时间范围内的分桶统计，Prev* 为上一个等长范围的合计
+type rangeSeries struct {
+	Buckets     []time.Time
+	XAxis       []string
+	Total       []int64
+	Success     []int64
+	TotalSum    int64
+	SuccessSum  int64
+	PrevTotal   int64
+	PrevSuccess int64
+}
+
+// bucketSeries 查询时间范围内的分桶统计
+func bucketSeries(ctx context.Context, r *dashboardRange, userID int64, fetch bucketFetcher) (*rangeSeries, error) {
+	buckets, err := r.buckets()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := fetch(ctx, userID, r.stats())
+	if err != nil {
+		return nil, err
+	}
+	series := &rangeSeries{Buckets: buckets, XAxis: r.labels(buckets)}
+	series.Total, series.Success = fillBuckets(buckets, rows)
+	series.TotalSum, series.SuccessSum = sumBuckets(rows)
+	return series, nil
+}
+
+// compareSeries 查询时间范围内的分桶统计及上一个等长范围的合计
+func compareSeries(ctx context.Context, r *dashboardRange, userID int64, fetch bucketFetcher) (*rangeSeries, error) {
+	series, err := bucketSeries(ctx, r, userID, fetch)
+	if err != nil {
+		return nil, err
+	}
+	prevRows, err := fetch(ctx, userID, r.previous().stats())
+	if err != nil {
+		return nil, err
+	}
+	series.PrevTotal, series.PrevSuccess = sumBuckets(prevRows)
+	return series, nil
+}
+
+func (rs *rangeSeries) volumeResponse() *response.VolumeDataResponse {
+	return &response.VolumeDataResponse{
+		Volume:    rs.TotalSum,
+		Growth:    calcChange(rs.TotalSum, rs.PrevTotal),
+		XAxis:     rs.XAxis,
+		ChartData: rs.Total,
+	}
+}
+
+// rateResponse 每个时间桶的成功率及整个范围的成功率与环比
+func (rs *rangeSeries) rateResponse() *response.RateDataResponse {
+	chart := make([]int64, len(rs.Total))
+	for i := range rs.Total {
+		chart[i] = percent(rs.Success[i], rs.Total[i])
+	}
+	rate := percent(rs.SuccessSum, rs.TotalSum)
+	return &response.RateDataResponse{
+		Rate:      int(rate),
+		Growth:    calcChange(rate, percent(rs.PrevSuccess, rs.PrevTotal)),
+		XAxis:     rs.XAxis,
+		ChartData: chart,
+	}
 }
 
 // =========================
 // 8. Admin Dashboard APIs
 // =========================
 
-func (s *BrowserAgentDashboardService) GetAdminSummary(ctx context.Context) (*response.AdminSummaryResponse, error) {
-	now := time.Now()
-	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	yesterdayStart := todayStart.AddDate(0, 0, -1)
+func (s *BrowserAgentDashboardService) GetAdminSummary(ctx context.Context, req *request.DashboardRangeRequest) (*response.AdminSummaryResponse, error) {
+	r, err := s.resolveRange(ctx, req, today)
+	if err != nil {
+		return nil, err
+	}
+	prev := r.previous()
 
-	todayTasks, _ := s.BrowserAgentRepo.CountAllMessagesByTimeRange(ctx, todayStart, time.Time{})
-	yesterdayTasks, _ := s.BrowserAgentRepo.CountAllMessagesByTimeRange(ctx, yesterdayStart, todayStart)
+	tasks, err := s.BrowserAgentRepo.CountAllMessagesByTimeRange(ctx, r.Start, r.End)
+	if err != nil {
+		return nil, err
+	}
+	prevTasks, err := s.BrowserAgentRepo.CountAllMessagesByTimeRange(ctx, prev.Start, prev.End)
+	if err != nil {
+		return nil, err
+	}
 
-	totalActions, _ := s.BrowserAgentRepo.CountAllActionsByTimeRange(ctx, "", time.Time{}, time.Time{})
-	successActions, _ := s.BrowserAgentRepo.CountAllActionsByTimeRange(ctx, "success", time.Time{}, time.Time{})
-	successRate := 0
-	if totalActions > 0 {
-		successRate = int(successActions * 100 / totalActions)
+	successRate, err := s.adminActionSuccessRate(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	prevSuccessRate, err := s.adminActionSuccessRate(ctx, prev)
+	if err != nil {
+		return nil, err
 	}
 
-	lastWeekTotal, _ := s.BrowserAgentRepo.CountAllActionsByTimeRange(ctx, "", todayStart.AddDate(0, 0, -7), todayStart)
-	lastWeekSuccess, _ := s.BrowserAgentRepo.CountAllActionsByTimeRange(ctx, "success", todayStart.AddDate(0, 0, -7), todayStart)
-	lastWeekRate := 0
-	if lastWeekTotal > 0 {
-		lastWeekRate = int(lastWeekSuccess * 100 / lastWeekTotal)
+	policyViolations, err := s.BrowserAgentRepo.CountPolicyViolationsByTimeRange(ctx, r.Start, r.End)
+	if err != nil {
+		return nil, err
 	}
-	successGrowth := calcChange(int64(successRate), int64(lastWeekRate))
 
 	return &response.AdminSummaryResponse{
-		TodayTasks:    todayTasks,
-		TodayGrowth:   calcChange(todayTasks, yesterdayTasks),
-		SuccessRate:   successRate,
-		SuccessGrowth: successGrowth,
+		TodayTasks:       tasks,
+		TodayGrowth:      calcChange(tasks, prevTasks),
+		SuccessRate:      int(successRate),
+		SuccessGrowth:    calcChange(successRate, prevSuccessRate),
+		PolicyViolations: policyViolations,
 	}, nil
 }
 
-func (s *BrowserAgentDashboardService) GetAdminWeeklyTaskVolume(ctx context.Context) (*response.VolumeDataResponse, error) {
-	now := time.Now()
-	days := make([]time.Time, 7)
-	for i := range 7 {
-		days[i] = now.AddDate(0, 0, -6+i)
+// adminActionSuccessRate 时间范围内全平台的操作成功率
+func (s *BrowserAgentDashboardService) adminActionSuccessRate(ctx context.Context, r *dashboardRange) (int64, error) {
+	total, err := s.BrowserAgentRepo.CountAllActionsByTimeRange(ctx, "", r.Start, r.End)
+	if err != nil {
+		return 0, err
 	}
-
-	chartData, _ := s.BrowserAgentRepo.CountAllMessagesByDays(ctx, days)
-	thisWeekVolume := int64(0)
-	for _, v := range chartData {
-		thisWeekVolume += v
+	success, err := s.BrowserAgentRepo.CountAllActionsByTimeRange(ctx, entity.ActionStatusSuccess, r.Start, r.End)
+	if err != nil {
+		return 0, err
 	}
+	return percent(success, total), nil
+}
 
-	lastWeekDays := make([]time.Time, 7)
-	for i := range 7 {
-		lastWeekDays[i] = now.AddDate(0, 0, -13+i)
+func (s *BrowserAgentDashboardService) GetAdminWeeklyTaskVolume(ctx context.Context, req *request.DashboardRangeRequest) (*response.VolumeDataResponse, error) {
+	r, err := s.resolveRange(ctx, req, lastDays(7))
+	if err != nil {
+		return nil, err
 	}
-	lastWeekData, _ := s.BrowserAgentRepo.CountAllMessagesByDays(ctx, lastWeekDays)
-	lastWeekVolume := int64(0)
-	for _, v := range lastWeekData {
-		lastWeekVolume += v
+	series, err := compareSeries(ctx, r, 0, s.BrowserAgentRepo.CountMessagesByBucket)
+	if err != nil {
+		return nil, err
 	}
-
-	return &response.VolumeDataResponse{
-		Volume:    thisWeekVolume,
-		Growth:    calcChange(thisWeekVolume, lastWeekVolume),
-		ChartData: chartData,
-	}, nil
+	return series.volumeResponse(), nil
 }
 
-func (s *BrowserAgentDashboardService) GetAdminWeeklyTaskSuccessRate(ctx context.Context) (*response.RateDataResponse, error) {
-	return s.getWeeklySuccessRate(ctx, func(ctx context.Context, days []time.Time) ([]dayCountRow, error) {
-		rows, err := s.BrowserAgentRepo.CountAllMessageSuccessRateByDays(ctx, days)
-		if err != nil {
-			return nil, err
-		}
-		result := make([]dayCountRow, len(rows))
-		for i, r := range rows {
-			result[i] = dayCountRow{Day: r.Day, Total: r.Total, Success: r.Success}
-		}
-		return result, nil
-	})
+// GetAdminWeeklyTaskSuccessRate 任务完成率（finished 任务占比）
+func (s *BrowserAgentDashboardService) GetAdminWeeklyTaskSuccessRate(ctx context.Context, req *request.DashboardRangeRequest) (*response.RateDataResponse, error) {
+	r, err := s.resolveRange(ctx, req, lastDays(7))
+	if err != nil {
+		return nil, err
+	}
+	series, err := compareSeries(ctx, r, 0, s.BrowserAgentRepo.CountMessagesByBucket)
+	if err != nil {
+		return nil, err
+	}
+	return series.rateResponse(), nil
 }
 
 func (s *BrowserAgentDashboardService) GetAdminTotalTaskVolume(
-	ctx context.Context,
+	ctx context.Context, req *request.DashboardRangeRequest,
 ) (*response.TotalTaskVolumeResponse, error) {
+	r, err := s.resolveRange(ctx, req, allTime)
+	if err != nil {
+		return nil, err
+	}
 
-	stateStats, err := s.BrowserAgentRepo.GetMessageStateStats(ctx)
+	stateStats, err := s.BrowserAgentRepo.GetMessageStateStats(ctx, r.Start, r.End)
 	if err != nil {
 		return nil, err
 	}
@@ -111,6 +202,9 @@ func (s *BrowserAgentDashboardService) GetAdminTotalTaskVolume(
 	stateNameMap[entity.MessageStateRunning] = "进行中"
 	stateNameMap[entity.MessageStateFinished] = "已完成"
 	stateNameMap[entity.MessageStateError] = "已失败"
+	stateNameMap[entity.MessageStateAbortedLoop] = "循环终止"
+	stateNameMap[entity.MessageStateCancelled] = "已取消"
+	stateNameMap[entity.MessageStatePaused] = "已暂停"
 
 	// 哪些状态计入 Total
 	countInTotal := map[string]struct{}{
@@ -146,8 +240,15 @@ func (s *BrowserAgentDashboardService) GetAdminTotalTaskVolume(
 	}, nil
 }
 
-func (s *BrowserAgentDashboardService) GetAdminTaskClassification(ctx context.Context) (*response.TaskClassificationResponse, error) {
-	tasks, _ := s.BrowserAgentRepo.GetTaskClassification(ctx)
+func (s *BrowserAgentDashboardService) GetAdminTaskClassification(ctx context.Context, req *request.DashboardRangeRequest) (*response.TaskClassificationResponse, error) {
+	r, err := s.resolveRange(ctx, req, allTime)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.BrowserAgentRepo.GetTaskClassification(ctx, r.Start, r.End)
+	if err != nil {
+		return nil, err
+	}
 
 	classificationMap := make(map[string]int64)
 	var total int64
@@ -174,120 +275,85 @@ func (s *BrowserAgentDashboardService) GetAdminTaskClassification(ctx context.Co
 	}, nil
 }
 
-func (s *BrowserAgentDashboardService) GetAdminWeeklyOperationVolume(ctx context.Context) (*response.VolumeDataResponse, error) {
-	now := time.Now()
-	days := make([]time.Time, 7)
-	for i := range 7 {
-		days[i] = now.AddDate(0, 0, -6+i)
+func (s *BrowserAgentDashboardService) GetAdminWeeklyOperationVolume(ctx context.Context, req *request.DashboardRangeRequest) (*response.VolumeDataResponse, error) {
+	r, err := s.resolveRange(ctx, req, lastDays(7))
+	if err != nil {
+		return nil, err
 	}
-
-	chartData, _ := s.BrowserAgentRepo.CountAllActionsByDays(ctx, days)
-	thisWeekVolume := int64(0)
-	for _, v := range chartData {
-		thisWeekVolume += v
+	series, err := compareSeries(ctx, r, 0, s.BrowserAgentRepo.CountActionsByBucket)
+	if err != nil {
+		return nil, err
 	}
+	return series.volumeResponse(), nil
+}
 
-	lastWeekDays := make([]time.Time, 7)
-	for i := range 7 {
-		lastWeekDays[i] = now.AddDate(0, 0, -13+i)
+func (s *BrowserAgentDashboardService) GetAdminWeeklyOperationSuccessRate(ctx context.Context, req *request.DashboardRangeRequest) (*response.RateDataResponse, error) {
+	r, err := s.resolveRange(ctx, req, lastDays(7))
+	if err != nil {
+		return nil, err
 	}
-	lastWeekData, _ := s.BrowserAgentRepo.CountAllActionsByDays(ctx, lastWeekDays)
-	lastWeekVolume := int64(0)
-	for _, v := range lastWeekData {
-		lastWeekVolume += v
+	series, err := compareSeries(ctx, r, 0, s.BrowserAgentRepo.CountActionsByBucket)
+	if err != nil {
+		return nil, err
 	}
-
-	return &response.VolumeDataResponse{
-		Volume:    thisWeekVolume,
-		Growth:    calcChange(thisWeekVolume, lastWeekVolume),
-		ChartData: chartData,
-	}, nil
-}
-
-func (s *BrowserAgentDashboardService) GetAdminWeeklyOperationSuccessRate(ctx context.Context) (*response.RateDataResponse, error) {
-	return s.getWeeklySuccessRate(ctx, func(ctx context.Context, days []time.Time) ([]dayCountRow, error) {
-		rows, err := s.BrowserAgentRepo.CountAllByDays(ctx, days)
-		if err != nil {
-			return nil, err
-		}
-		result := make([]dayCountRow, len(rows))
-		for i, r := range rows {
-			result[i] = dayCountRow{Day: r.Day, Total: r.Total, Success: r.Success}
-		}
-		return result, nil
-	})
+	return series.rateResponse(), nil
 }
 
+// GetAdminActiveSessions 时间范围内新建的会话数
 func (s *BrowserAgentDashboardService) GetAdminActiveSessions(
-	ctx context.Context,
+	ctx context.Context, req *request.DashboardRangeRequest,
 ) (*response.ActiveSessionsResponse, error) {
-
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-
-	// ===== 本周 7 天 =====
-	days := make([]time.Time, 7)
-	for i := range 7 {
-		days[i] = today.AddDate(0, 0, -6+i)
-	}
-
-	thisWeekData, err := s.BrowserAgentRepo.CountAllConversationsByDays(ctx, days)
+	r, err := s.resolveRange(ctx, req, lastDays(7))
 	if err != nil {
 		return nil, err
 	}
-
-	// 本周总会话数
-	var currentCount int64
-	for _, c := range thisWeekData {
-		currentCount += c
-	}
-
-	// ===== 上周 7 天 =====
-	lastWeekDays := make([]time.Time, 7)
-	for i := range 7 {
-		lastWeekDays[i] = today.AddDate(0, 0, -13+i)
-	}
-
-	lastWeekData, err := s.BrowserAgentRepo.CountAllConversationsByDays(ctx, lastWeekDays)
+	series, err := compareSeries(ctx, r, 0, s.BrowserAgentRepo.CountConversationsByBucket)
 	if err != nil {
 		return nil, err
 	}
 
-	var lastWeekCount int64
-	for _, c := range lastWeekData {
-		lastWeekCount += c
-	}
-
 	return &response.ActiveSessionsResponse{
-		Count:     currentCount,                            // 本周总会话数
-		Growth:    calcChange(currentCount, lastWeekCount), // 周环比
-		ChartData: thisWeekData,                            // 每天会话数
+		Count:     series.TotalSum,
+		Growth:    calcChange(series.TotalSum, series.PrevTotal),
+		XAxis:     series.XAxis,
+		ChartData: series.Total,
 	}, nil
 }
 
-func (s *BrowserAgentDashboardService) GetAdminAnnualTaskStats(ctx context.Context, year int) (*response.AnnualTaskStatsResponse, error) {
-	if year == 0 {
-		year = time.Now().Year()
+// GetAdminAnnualTaskStats 任务量趋势，默认为 year 自然年按月统计，季度数据按时间桶起点所在季度合并
+func (s *BrowserAgentDashboardService) GetAdminAnnualTaskStats(ctx context.Context, year int, req *request.DashboardRangeRequest) (*response.AnnualTaskStatsResponse, error) {
+	r, err := s.resolveRange(ctx, req, calendarYear(year))
+	if err != nil {
+		return nil, err
 	}
-
-	monthlyData, quarterData, err := s.BrowserAgentRepo.CountAllMessagesByYearWithQuarters(ctx, year)
+	series, err := bucketSeries(ctx, r, 0, s.BrowserAgentRepo.CountMessagesByBucket)
 	if err != nil {
 		return nil, err
 	}
+	quarterAxis, quarterData := quarterSeries(series.Buckets, series.Total)
 
 	return &response.AnnualTaskStatsResponse{
-		Year:        year,
-		MonthlyData: monthlyData,
+		Year:        r.Start.In(r.Location).Year(),
+		XAxis:       series.XAxis,
+		MonthlyData: series.Total,
+		QuarterAxis: quarterAxis,
 		QuarterData: quarterData,
 	}, nil
 }
 
-func (s *BrowserAgentDashboardService) GetAdminHotTaskList(ctx context.Context, limit int) ([]response.HotTaskItemResponse, error) {
+func (s *BrowserAgentDashboardService) GetAdminHotTaskList(ctx context.Context, limit int, req *request.DashboardRangeRequest) ([]response.HotTaskItemResponse, error) {
 	if limit == 0 || limit > 10 {
 		limit = 6
 	}
+	r, err := s.resolveRange(ctx, req, allTime)
+	if err != nil {
+		return nil, err
+	}
 
-	tasks, _ := s.BrowserAgentRepo.GetHotTasksWithDetails(ctx, limit)
+	tasks, err := s.BrowserAgentRepo.GetHotTasksWithDetails(ctx, r.Start, r.End, limit)
+	if err != nil {
+		return nil, err
+	}
 
 	colors := []string{"primary", "success", "warning", "error", "info", "secondary"}
 	result := make([]response.HotTaskItemResponse, len(tasks))
@@ -309,7 +375,11 @@ func (s *BrowserAgentDashboardService) GetAdminHotTaskList(ctx context.Context,
 	return result, nil
 }
 
+// GetMessagePage 分页查询全部用户的任务，仅管理员可访问
 func (s *BrowserAgentDashboardService) GetMessagePage(ctx context.Context, queryParam *query.BrowserAgentMessage) (*common.PaginationResp[response.MessageResponse], error) {
+	if err := s.CheckAdmin(ctx, authutils.GetUserID(ctx)); err != nil {
+		return nil, err
+	}
 	messages, total, err := s.BrowserAgentRepo.ListMessagesPage(ctx, queryParam)
 	if err != nil {
 		return nil, err
@@ -323,290 +393,252 @@ func (s *BrowserAgentDashboardService) GetMessagePage(ctx context.Context, query
 	return common.BuildPageResp[response.MessageResponse](responses, total, queryParam.PaginationReq), nil
 }
 
-// =========================
-// 9. User Dashboard APIs
-// =========================
-
-func (s *BrowserAgentDashboardService) GetUserSummary(ctx context.Context, userID int64) (*response.UserSummaryResponse, error) {
-	thisWeekStart, lastWeekStart, lastWeekEnd := getWeekTimeRanges()
-
-	thisWeekSessions, _ := s.BrowserAgentRepo.CountConversationsByTimeRange(ctx, userID, thisWeekStart, time.Time{})
-	lastWeekSessions, _ := s.BrowserAgentRepo.CountConversationsByTimeRange(ctx, userID, lastWeekStart, lastWeekEnd)
-
-	totalActions, _ := s.BrowserAgentRepo.CountActionsByTimeRange(ctx, userID, "", time.Time{}, time.Time{})
-	successActions, _ := s.BrowserAgentRepo.CountActionsByTimeRange(ctx, userID, "success", time.Time{}, time.Time{})
-	successRate := 0
-	if totalActions > 0 {
-		successRate = int(successActions * 100 / totalActions)
+func (s *BrowserAgentDashboardService) GetPolicyViolationPage(ctx context.Context, queryParam *query.BrowserAgentPolicyViolation) (*common.PaginationResp[response.ActionResponse], error) {
+	if err := s.CheckAdmin(ctx, authutils.GetUserID(ctx)); err != nil {
+		return nil, err
+	}
+	actions, total, err := s.BrowserAgentRepo.ListPolicyViolationsPage(ctx, queryParam)
+	if err != nil {
+		return nil, err
 	}
 
-	lastWeekActions, _ := s.BrowserAgentRepo.CountActionsByTimeRange(ctx, userID, "", lastWeekStart, lastWeekEnd)
-	lastWeekSuccess, _ := s.BrowserAgentRepo.CountActionsByTimeRange(ctx, userID, "success", lastWeekStart, lastWeekEnd)
-	lastWeekRate := 0
-	if lastWeekActions > 0 {
-		lastWeekRate = int(lastWeekSuccess * 100 / lastWeekActions)
+	responses := make([]response.ActionResponse, len(actions))
+	for i := range actions {
+		_ = copier.Copy(&responses[i], &actions[i])
 	}
 
-	return &response.UserSummaryResponse{
-		SessionCount:  thisWeekSessions,
-		SessionGrowth: calcChange(thisWeekSessions, lastWeekSessions),
-		SuccessRate:   successRate,
-		SuccessGrowth: calcChange(int64(successRate), int64(lastWeekRate)),
-	}, nil
+	return common.BuildPageResp[response.ActionResponse](responses, total, queryParam.PaginationReq), nil
 }
 
-func (s *BrowserAgentDashboardService) GetUserWeeklyTaskVolume(ctx context.Context, userID int64) (*response.VolumeDataResponse, error) {
-	now := time.Now()
-	days := make([]time.Time, 7)
-	for i := range 7 {
-		days[i] = now.AddDate(0, 0, -6+i)
+// GetAdminTokenUsage 时间范围内全平台的模型用量与费用最高的用户
+func (s *BrowserAgentDashboardService) GetAdminTokenUsage(ctx context.Context, limit int, req *request.DashboardRangeRequest) (*response.AdminTokenUsageResponse, error) {
+	if err := s.CheckAdmin(ctx, authutils.GetUserID(ctx)); err != nil {
+		return nil, err
 	}
-
-	chartData, _ := s.BrowserAgentRepo.CountMessagesByDays(ctx, userID, days)
-	thisWeekVolume := int64(0)
-	for _, v := range chartData {
-		thisWeekVolume += v
+	r, err := s.resolveRange(ctx, req, allTime)
+	if err != nil {
+		return nil, err
 	}
-
-	lastWeekDays := make([]time.Time, 7)
-	for i := range 7 {
-		lastWeekDays[i] = now.AddDate(0, 0, -13+i)
+	total, err := s.BrowserAgentRepo.SumTokenUsage(ctx, 0, r.Start, r.End)
+	if err != nil {
+		return nil, err
 	}
-	lastWeekData, _ := s.BrowserAgentRepo.CountMessagesByDays(ctx, userID, lastWeekDays)
-	lastWeekVolume := int64(0)
-	for _, v := range lastWeekData {
-		lastWeekVolume += v
+	rows, err := s.BrowserAgentRepo.GetUserTokenUsageRanking(ctx, r.Start, r.End, limit)
+	if err != nil {
+		return nil, err
 	}
 
-	return &response.VolumeDataResponse{
-		Volume:    thisWeekVolume,
-		Growth:    calcChange(thisWeekVolume, lastWeekVolume),
-		ChartData: chartData,
+	users := make([]response.UserTokenUsageItem, len(rows))
+	for i := range rows {
+		users[i] = response.UserTokenUsageItem{
+			UserID:             rows[i].UserID,
+			Username:           rows[i].Username,
+			TokenUsageResponse: toTokenUsageResponse(&rows[i].TokenUsageRow),
+		}
+	}
+	return &response.AdminTokenUsageResponse{
+		TokenUsageResponse: toTokenUsageResponse(total),
+		Users:              users,
 	}, nil
 }
 
-func (s *BrowserAgentDashboardService) GetUserWeeklyTaskSuccessRate(
-	ctx context.Context, userID int64,
-) (*response.RateDataResponse, error) {
-
-	now := time.Now()
-	location := now.Location()
-
-	// 构建本周日期数组（7天），日期归零
-	days := make([]time.Time, 7)
-	for i := range 7 {
-		t := now.AddDate(0, 0, -6+i) // 从6天前到今天
-		days[i] = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
-	}
+// =========================
+// 9. User Dashboard APIs
+// =========================
 
-	// 查询本周每天成功率数据 + 本周总操作数和成功数
-	chartData, totalSum, totalSuccess, err := s.BrowserAgentRepo.CountActionsSuccessRateByDays(ctx, userID, days)
+func (s *BrowserAgentDashboardService) GetUserSummary(ctx context.Context, userID int64, req *request.DashboardRangeRequest) (*response.UserSummaryResponse, error) {
+	r, err := s.resolveRange(ctx, req, lastDays(7))
 	if err != nil {
 		return nil, err
 	}
+	prev := r.previous()
 
-	// 计算本周整周成功率（总成功数 / 总操作数）
-	var currentRate int64
-	if totalSum > 0 {
-		currentRate = totalSuccess * 100 / totalSum
-	} else {
-		currentRate = 0
+	sessions, err := s.BrowserAgentRepo.CountConversationsByTimeRange(ctx, userID, r.Start, r.End)
+	if err != nil {
+		return nil, err
 	}
-
-	// 构建上周日期数组（7天），日期归零
-	lastWeekDays := make([]time.Time, 7)
-	for i := range 7 {
-		t := now.AddDate(0, 0, -13+i) // 上周同样7天
-		lastWeekDays[i] = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+	prevSessions, err := s.BrowserAgentRepo.CountConversationsByTimeRange(ctx, userID, prev.Start, prev.End)
+	if err != nil {
+		return nil, err
 	}
 
-	// 查询上周每天成功率数据 + 上周总操作数和成功数
-	_, lastWeekTotal, lastWeekSuccess, err := s.BrowserAgentRepo.CountActionsSuccessRateByDays(ctx, userID, lastWeekDays)
+	successRate, err := s.userActionSuccessRate(ctx, userID, r)
 	if err != nil {
 		return nil, err
 	}
-
-	// 计算上周整周成功率（总成功数 / 总操作数）
-	var lastWeekRate int64
-	if lastWeekTotal > 0 {
-		lastWeekRate = lastWeekSuccess * 100 / lastWeekTotal
-	} else {
-		lastWeekRate = 0
+	prevSuccessRate, err := s.userActionSuccessRate(ctx, userID, prev)
+	if err != nil {
+		return nil, err
 	}
 
-	// 返回结果
-	return &response.RateDataResponse{
-		Rate:      int(currentRate), // 本周总成功率
-		Growth:    calcChange(currentRate, lastWeekRate),
-		ChartData: chartData, // 每日成功率图表
+	return &response.UserSummaryResponse{
+		SessionCount:  sessions,
+		SessionGrowth: calcChange(sessions, prevSessions),
+		SuccessRate:   int(successRate),
+		SuccessGrowth: calcChange(successRate, prevSuccessRate),
 	}, nil
 }
 
-func (s *BrowserAgentDashboardService) GetUserTaskOverview(ctx context.Context, userID int64) (*response.UserTaskOverviewResponse, error) {
-	sessionCount, _ := s.BrowserAgentRepo.CountConversationsByTimeRange(ctx, userID, time.Time{}, time.Time{})
-	taskCount, _ := s.BrowserAgentRepo.CountMessagesByTimeRange(ctx, userID, time.Time{}, time.Time{})
+// userActionSuccessRate 时间范围内用户的操作成功率
+func (s *BrowserAgentDashboardService) userActionSuccessRate(ctx context.Context, userID int64, r *dashboardRange) (int64, error) {
+	total, err := s.BrowserAgentRepo.CountActionsByTimeRange(ctx, userID, "", r.Start, r.End)
+	if err != nil {
+		return 0, err
+	}
+	success, err := s.BrowserAgentRepo.CountActionsByTimeRange(ctx, userID, entity.ActionStatusSuccess, r.Start, r.End)
+	if err != nil {
+		return 0, err
+	}
+	return percent(success, total), nil
+}
+
+func (s *BrowserAgentDashboardService) GetUserWeeklyTaskVolume(ctx context.Context, userID int64, req *request.DashboardRangeRequest) (*response.VolumeDataResponse, error) {
+	r, err := s.resolveRange(ctx, req, lastDays(7))
+	if err != nil {
+		return nil, err
+	}
+	series, err := compareSeries(ctx, r, userID, s.BrowserAgentRepo.CountMessagesByBucket)
+	if err != nil {
+		return nil, err
+	}
+	return series.volumeResponse(), nil
+}
 
-	totalActions, _ := s.BrowserAgentRepo.CountActionsByTimeRange(ctx, userID, "", time.Time{}, time.Time{})
-	successActions, _ := s.BrowserAgentRepo.CountActionsByTimeRange(ctx, userID, "success", time.Time{}, time.Time{})
-	successRate := 0
-	if totalActions > 0 {
-		successRate = int(successActions * 100 / totalActions)
+// GetUserWeeklyTaskSuccessRate 用户的操作成功率
+func (s *BrowserAgentDashboardService) GetUserWeeklyTaskSuccessRate(
+	ctx context.Context, userID int64, req *request.DashboardRangeRequest,
+) (*response.RateDataResponse, error) {
+	r, err := s.resolveRange(ctx, req, lastDays(7))
+	if err != nil {
+		return nil, err
 	}
+	series, err := compareSeries(ctx, r, userID, s.BrowserAgentRepo.CountActionsByBucket)
+	if err != nil {
+		return nil, err
+	}
+	return series.rateResponse(), nil
+}
 
-	_, lastWeekStart, lastWeekEnd := getWeekTimeRanges()
-	lastWeekTasks, _ := s.BrowserAgentRepo.CountMessagesByTimeRange(ctx, userID, lastWeekStart, lastWeekEnd)
+func (s *BrowserAgentDashboardService) GetUserTaskOverview(ctx context.Context, userID int64, req *request.DashboardRangeRequest) (*response.UserTaskOverviewResponse, error) {
+	r, err := s.resolveRange(ctx, req, lastDays(7))
+	if err != nil {
+		return nil, err
+	}
 
-	now := time.Now()
-	days := make([]time.Time, 7)
-	for i := range 7 {
-		days[i] = now.AddDate(0, 0, -6+i)
+	sessionCount, err := s.BrowserAgentRepo.CountConversationsByTimeRange(ctx, userID, r.Start, r.End)
+	if err != nil {
+		return nil, err
+	}
+	successRate, err := s.userActionSuccessRate(ctx, userID, r)
+	if err != nil {
+		return nil, err
+	}
+	series, err := compareSeries(ctx, r, userID, s.BrowserAgentRepo.CountMessagesByBucket)
+	if err != nil {
+		return nil, err
 	}
-	chartData, _ := s.BrowserAgentRepo.CountMessagesByDays(ctx, userID, days)
 
 	return &response.UserTaskOverviewResponse{
 		SessionCount: sessionCount,
-		TaskCount:    taskCount,
-		SuccessRate:  successRate,
-		WeekGrowth:   calcChange(taskCount, lastWeekTasks),
+		TaskCount:    series.TotalSum,
+		SuccessRate:  int(successRate),
+		WeekGrowth:   calcChange(series.TotalSum, series.PrevTotal),
 		ChartData: response.UserTaskChart{
-			XAxis: []string{"周一", "周二", "周三", "周四", "周五", "周六", "周日"},
-			Data:  chartData,
+			XAxis: series.XAxis,
+			Data:  series.Total,
 		},
 	}, nil
 }
 
-func (s *BrowserAgentDashboardService) GetUserTaskTrend(ctx context.Context, userID int64, year int) (*response.UserTaskTrendResponse, error) {
-	if year == 0 {
-		year = time.Now().Year()
-	}
-
-	monthlyData, err := s.BrowserAgentRepo.CountMessagesByMonths(ctx, userID, year)
+// GetUserTaskTrend 用户任务量趋势，默认为 year 自然年按月统计
+func (s *BrowserAgentDashboardService) GetUserTaskTrend(ctx context.Context, userID int64, year int, req *request.DashboardRangeRequest) (*response.UserTaskTrendResponse, error) {
+	r, err := s.resolveRange(ctx, req, calendarYear(year))
 	if err != nil {
 		return nil, err
 	}
-
-	lastYearData, _ := s.BrowserAgentRepo.CountMessagesByMonths(ctx, userID, year-1)
-
-	var thisYearTotal, lastYearTotal int64
-	for _, v := range monthlyData {
-		thisYearTotal += v
-	}
-	for _, v := range lastYearData {
-		lastYearTotal += v
+	series, err := compareSeries(ctx, r, userID, s.BrowserAgentRepo.CountMessagesByBucket)
+	if err != nil {
+		return nil, err
 	}
 
 	return &response.UserTaskTrendResponse{
-		Year:        year,
-		Growth:      calcChange(thisYearTotal, lastYearTotal),
-		MonthlyData: monthlyData,
+		Year:        r.Start.In(r.Location).Year(),
+		Growth:      calcChange(series.TotalSum, series.PrevTotal),
+		XAxis:       series.XAxis,
+		MonthlyData: series.Total,
 	}, nil
 }
 
-// =========================
-// 辅助函数
-// =========================
-
-type dayCountRow struct {
-	Day     time.Time
-	Total   int64
-	Success int64
-}
-
-type dayCountFetcher func(ctx context.Context, days []time.Time) ([]dayCountRow, error)
-
-func makeWeekDays(today time.Time, startOffset int) []time.Time {
-	days := make([]time.Time, 7)
-	for i := range 7 {
-		days[i] = today.AddDate(0, 0, startOffset+i)
-	}
-	return days
-}
-
-func calcWeekRate(rows []dayCountRow, days []time.Time) (rate int64, chart []int64) {
-	rowMap := make(map[string]dayCountRow)
-	for _, r := range rows {
-		rowMap[r.Day.Format("2006-01-02")] = r
-	}
-
-	chart = make([]int64, 7)
-	var total, success int64
-
-	for i, d := range days {
-		if r, ok := rowMap[d.Format("2006-01-02")]; ok {
-			total += r.Total
-			success += r.Success
-			if r.Total > 0 {
-				chart[i] = r.Success * 100 / r.Total
-			}
-		}
-	}
-
-	if total > 0 {
-		rate = success * 100 / total
+// GetUserTokenUsage 时间范围内用户的模型用量与费用最高的会话
+func (s *BrowserAgentDashboardService) GetUserTokenUsage(ctx context.Context, userID int64, limit int, req *request.DashboardRangeRequest) (*response.UserTokenUsageResponse, error) {
+	r, err := s.resolveRange(ctx, req, allTime)
+	if err != nil {
+		return nil, err
 	}
-	return
-}
-
-func (s *BrowserAgentDashboardService) getWeeklySuccessRate(
-	ctx context.Context,
-	fetcher dayCountFetcher,
-) (*response.RateDataResponse, error) {
-	today := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Now().Location())
-
-	thisWeekDays := makeWeekDays(today, -6)
-	lastWeekDays := makeWeekDays(today, -13)
-
-	thisWeekRows, err := fetcher(ctx, thisWeekDays)
+	total, err := s.BrowserAgentRepo.SumTokenUsage(ctx, userID, r.Start, r.End)
 	if err != nil {
 		return nil, err
 	}
-
-	lastWeekRows, err := fetcher(ctx, lastWeekDays)
+	rows, err := s.BrowserAgentRepo.ListConversationTokenUsage(ctx, userID, r.Start, r.End, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	thisWeekRate, thisWeekChart := calcWeekRate(thisWeekRows, thisWeekDays)
-	lastWeekRate, _ := calcWeekRate(lastWeekRows, lastWeekDays)
-
-	return &response.RateDataResponse{
-		Rate:      int(thisWeekRate),
-		Growth:    calcChange(thisWeekRate, lastWeekRate),
-		ChartData: thisWeekChart,
+	conversations := make([]response.ConversationTokenUsageItem, len(rows))
+	for i := range rows {
+		conversations[i] = response.ConversationTokenUsageItem{
+			ConversationID:     rows[i].ConversationID,
+			Title:              rows[i].Title,
+			TokenUsageResponse: toTokenUsageResponse(&rows[i].TokenUsageRow),
+		}
+	}
+	return &response.UserTokenUsageResponse{
+		TokenUsageResponse: toTokenUsageResponse(total),
+		Conversations:      conversations,
 	}, nil
 }
 
-func getWeekTimeRanges() (thisWeekStart, lastWeekStart, lastWeekEnd time.Time) {
-	now := time.Now()
-	thisWeekStart = now.AddDate(0, 0, -int(now.Weekday())-7)
-	lastWeekStart = thisWeekStart.AddDate(0, 0, -7)
-	lastWeekEnd = thisWeekStart
-	return
+// =========================
+// 辅助函数
+// =========================
+
+func toTokenUsageResponse(row *db.TokenUsageRow) response.TokenUsageResponse {
+	usage := response.TokenUsageResponse{
+		PromptTokens:     row.PromptTokens,
+		CompletionTokens: row.CompletionTokens,
+		TotalTokens:      row.PromptTokens + row.CompletionTokens,
+		Cost:             row.Cost,
+		TaskCount:        row.TaskCount,
+	}
+	if row.TaskCount > 0 {
+		usage.AvgTaskCost = row.Cost.Div(decimal.NewFromInt(row.TaskCount)).Round(8)
+	}
+	return usage
 }
 
-// calcChange 计算本周相比上周的百分比变化
+// calcChange 计算本期相比上一个等长时间范围的百分比变化
 // 返回格式示例：+20%、-15%、+100%
 //
 // 计算公式：
 //
-//	(thisWeek - lastWeek) / lastWeek * 100
+//	(current - previous) / previous * 100
 //
 // 特殊情况处理：
-//   - 如果 lastWeek == 0 且 thisWeek > 0，认为增长 100%
-//   - 如果 lastWeek == 0 且 thisWeek == 0，返回 +0%
-func calcChange(thisWeek, lastWeek int64) string {
-	// 如果上周为 0，需要特殊处理（避免除 0）
-	if lastWeek == 0 {
-		// 上周为 0，本周有数据，视为 100% 增长
-		if thisWeek > 0 {
+//   - 如果 previous == 0 且 current > 0，认为增长 100%
+//   - 如果 previous == 0 且 current == 0，返回 +0%
+func calcChange(current, previous int64) string {
+	// 如果上期为 0，需要特殊处理（避免除 0）
+	if previous == 0 {
+		// 上期为 0，本期有数据，视为 100% 增长
+		if current > 0 {
 			return "+100%"
 		}
-		// 两周都为 0
+		// 两期都为 0
 		return "+0%"
 	}
 
 	// 计算变化百分比
-	diff := float64(thisWeek-lastWeek) / float64(lastWeek) * 100
+	diff := float64(current-previous) / float64(previous) * 100
 
 	// 正数加 "+" 号
 	if diff >= 0 {
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/internal/repository/db"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	_ "time/tzdata" // 统计时区由请求指定，不依赖运行环境的时区数据库
+)
+
+const (
+	// dashboardDBTimezone 数据库连接时区（见 init_gorm 的 DSN），created_at 按该时区的本地时间保存，也是统计的默认时区
+	dashboardDBTimezone = "Asia/Shanghai"
+	// maxDashboardBuckets 单次统计的时间桶上限（31 天按小时）
+	maxDashboardBuckets = 744
+)
+
+// dashboardRange 解析后的仪表盘统计范围 [Start, End)，零值表示不限，仅用于不分桶的统计
+type dashboardRange struct {
+	Start       time.Time
+	End         time.Time
+	Granularity string
+	Location    *time.Location
+}
+
+// rangeDefault 请求未指定 start / end / granularity 时各接口使用的默认值，now 为统计时区的当前时间
+type rangeDefault func(now time.Time) (start, end time.Time, granularity string)
+
+// lastDays 最近 n 天（含今天），按天统计
+func lastDays(n int) rangeDefault {
+	return func(now time.Time) (time.Time, time.Time, string) {
+		tomorrow := startOfDay(now).AddDate(0, 0, 1)
+		return tomorrow.AddDate(0, 0, -n), tomorrow, "day"
+	}
+}
+
+// today 今天，按小时统计
+func today(now time.Time) (time.Time, time.Time, string) {
+	start := startOfDay(now)
+	return start, start.AddDate(0, 0, 1), "hour"
+}
+
+// calendarYear 自然年，year 为 0 时为今年，按月统计
+func calendarYear(year int) rangeDefault {
+	return func(now time.Time) (time.Time, time.Time, string) {
+		if year == 0 {
+			year = now.Year()
+		}
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(1, 0, 0), "month"
+	}
+}
+
+// allTime 不限时间
+func allTime(time.Time) (time.Time, time.Time, string) {
+	return time.Time{}, time.Time{}, "day"
+}
+
+// supportedTimezones 已确认数据库支持的时区名称，时区数据库随数据库版本固定，进程内缓存即可
+var supportedTimezones sync.Map
+
+// resolveRange 校验数据库支持请求的时区后解析统计范围，分桶查询的 timezone() 遇到不支持的时区会直接报错
+func (s *BrowserAgentDashboardService) resolveRange(ctx context.Context, req *request.DashboardRangeRequest, def rangeDefault) (*dashboardRange, error) {
+	if req.Timezone != "" && req.Timezone != dashboardDBTimezone {
+		if _, ok := supportedTimezones.Load(req.Timezone); !ok {
+			exists, err := s.BrowserAgentRepo.TimezoneExists(ctx, req.Timezone)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, fmt.Errorf("无效的时区: %s", req.Timezone)
+			}
+			supportedTimezones.Store(req.Timezone, struct{}{})
+		}
+	}
+	return resolveDashboardRange(req, def)
+}
+
+// resolveDashboardRange 解析请求中的时间范围、粒度与时区，未指定的部分使用 def
+func resolveDashboardRange(req *request.DashboardRangeRequest, def rangeDefault) (*dashboardRange, error) {
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = dashboardDBTimezone
+	}
+	// Local 表示服务进程所在环境的时区，数据库无法识别且各副本可能不一致
+	if timezone == "Local" {
+		return nil, fmt.Errorf("无效的时区: %s", req.Timezone)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区: %s", req.Timezone)
+	}
+	dbLoc, err := time.LoadLocation(dashboardDBTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("加载数据库时区失败: %w", err)
+	}
+
+	start, end, granularity := def(time.Now().In(loc))
+	if req.Granularity != "" {
+		granularity = req.Granularity
+	}
+	if req.Start != "" {
+		if start, err = parseDashboardTime(req.Start, loc, false); err != nil {
+			return nil, err
+		}
+	}
+	if req.End != "" {
+		if end, err = parseDashboardTime(req.End, loc, true); err != nil {
+			return nil, err
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return nil, errors.New("开始时间必须早于结束时间")
+	}
+
+	// 时间参数按本地时间与不带时区的 created_at 比较，需换算到数据库时区
+	return &dashboardRange{
+		Start:       start.In(dbLoc),
+		End:         end.In(dbLoc),
+		Granularity: granularity,
+		Location:    loc,
+	}, nil
+}
+
+// parseDashboardTime 解析 2006-01-02 或 RFC3339 格式的时间，日期格式的结束时间包含当天
+func parseDashboardTime(value string, loc *time.Location, isEnd bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, loc); err == nil {
+		if isEnd {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无效的时间: %s，支持 2006-01-02 或 RFC3339 格式", value)
+	}
+	return t, nil
+}
+
+// previous 紧邻的上一个等长范围，用于计算环比；按整月划分的范围向前平移相同月数
+func (r *dashboardRange) previous() *dashboardRange {
+	prev := *r
+	prev.End = r.Start
+	if months := r.wholeMonths(); months > 0 {
+		prev.Start = r.Start.In(r.Location).AddDate(0, -months, 0)
+	} else {
+		prev.Start = r.Start.Add(-r.End.Sub(r.Start))
+	}
+	return &prev
+}
+
+// wholeMonths 范围起止均为统计时区的月初时返回月数，否则返回 0
+func (r *dashboardRange) wholeMonths() int {
+	start, end := r.Start.In(r.Location), r.End.In(r.Location)
+	isMonthStart := func(t time.Time) bool {
+		return t.Day() == 1 && t.Equal(startOfDay(t))
+	}
+	if !isMonthStart(start) || !isMonthStart(end) {
+		return 0
+	}
+	return (end.Year()-start.Year())*12 + int(end.Month()-start.Month())
+}
+
+// stats 转换为数据库分桶查询的参数
+func (r *dashboardRange) stats() *db.StatsRange {
+	return &db.StatsRange{
+		Start:       r.Start,
+		End:         r.End,
+		Granularity: r.Granularity,
+		Timezone:    r.Location.String(),
+	}
+}
+
+// buckets 范围内每个时间桶的起点（统计时区），与 date_trunc 的对齐方式一致，周从周一开始
+func (r *dashboardRange) buckets() ([]time.Time, error) {
+	if r.Start.IsZero() || r.End.IsZero() {
+		return nil, errors.New("分桶统计需要指定开始与结束时间")
+	}
+	var buckets []time.Time
+	for t := r.truncate(r.Start); t.Before(r.End); t = r.next(t) {
+		if len(buckets) == maxDashboardBuckets {
+			return nil, fmt.Errorf("时间范围内的统计点超过 %d 个，请缩小时间范围或增大统计粒度", maxDashboardBuckets)
+		}
+		buckets = append(buckets, t)
+	}
+	return buckets, nil
+}
+
+func (r *dashboardRange) truncate(t time.Time) time.Time {
+	t = t.In(r.Location)
+	switch r.Granularity {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, r.Location)
+	case "week":
+		day := startOfDay(t)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, r.Location)
+	default:
+		return startOfDay(t)
+	}
+}
+
+func (r *dashboardRange) next(t time.Time) time.Time {
+	switch r.Granularity {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, r.Location)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// labels 时间桶的横轴文案
+func (r *dashboardRange) labels(buckets []time.Time) []string {
+	layout := "01-02"
+	switch r.Granularity {
+	case "hour":
+		layout = "01-02 15:00"
+	case "month":
+		layout = "2006-01"
+	}
+	labels := make([]string, len(buckets))
+	for i, b := range buckets {
+		labels[i] = b.Format(layout)
+	}
+	return labels
+}
+
+// fillBuckets 按时间桶顺序填充统计结果，没有记录的桶为 0
+//
+// 数据库返回的桶起点是不带时区的本地时间，按年月日时与 buckets 对应
+func fillBuckets(buckets []time.Time, rows []db.BucketCount) (total, success []int64) {
+	const keyLayout = "2006-01-02 15"
+	index := make(map[string]int, len(buckets))
+	for i, b := range buckets {
+		index[b.Format(keyLayout)] = i
+	}
+	total = make([]int64, len(buckets))
+	success = make([]int64, len(buckets))
+	for _, row := range rows {
+		if i, ok := index[row.Bucket.Format(keyLayout)]; ok {
+			total[i] = row.Total
+			success[i] = row.Success
+		}
+	}
+	return
+}
+
+func sumBuckets(rows []db.BucketCount) (total, success int64) {
+	for _, row := range rows {
+		total += row.Total
+		success += row.Success
+	}
+	return
+}
+
+// quarterSeries 将时间桶按起点所在季度合并
+func quarterSeries(buckets []time.Time, values []int64) (axis []string, data []int64) {
+	for i, b := range buckets {
+		label := fmt.Sprintf("%d-Q%d", b.Year(), (int(b.Month())-1)/3+1)
+		if len(axis) == 0 || axis[len(axis)-1] != label {
+			axis = append(axis, label)
+			data = append(data, 0)
+		}
+		data[len(data)-1] += values[i]
+	}
+	return
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// percent 百分比（取整），total 为 0 时返回 0
+func percent(part, total int64) int64 {
+	if total == 0 {
+		return 0
+	}
+	return part * 100 / total
+}
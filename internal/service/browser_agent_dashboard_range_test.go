@@ -0,0 +1,190 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/internal/repository/db"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 统计时区由 time/tzdata 内置，加载不会失败
+var (
+	shanghai, _ = time.LoadLocation("Asia/Shanghai")
+	newYork, _  = time.LoadLocation("America/New_York")
+)
+
+func TestResolveDashboardRange(t *testing.T) {
+	tests := []struct {
+		name            string
+		req             request.DashboardRangeRequest
+		wantStart       time.Time
+		wantEnd         time.Time
+		wantGranularity string
+		wantLocation    string
+		wantErr         bool
+	}{
+		{
+			name:            "日期格式的结束时间包含当天",
+			req:             request.DashboardRangeRequest{Start: "2026-03-01", End: "2026-03-07"},
+			wantStart:       time.Date(2026, 3, 1, 0, 0, 0, 0, shanghai),
+			wantEnd:         time.Date(2026, 3, 8, 0, 0, 0, 0, shanghai),
+			wantGranularity: "day",
+			wantLocation:    "Asia/Shanghai",
+		},
+		{
+			name:            "按请求时区解释日期并换算到数据库时区",
+			req:             request.DashboardRangeRequest{Start: "2026-03-01", End: "2026-03-01", Granularity: "hour", Timezone: "America/New_York"},
+			wantStart:       time.Date(2026, 3, 1, 0, 0, 0, 0, newYork),
+			wantEnd:         time.Date(2026, 3, 2, 0, 0, 0, 0, newYork),
+			wantGranularity: "hour",
+			wantLocation:    "America/New_York",
+		},
+		{
+			name:            "RFC3339 格式的结束时间不含",
+			req:             request.DashboardRangeRequest{Start: "2026-03-01T00:00:00Z", End: "2026-03-02T00:00:00Z"},
+			wantStart:       time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:         time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+			wantGranularity: "day",
+			wantLocation:    "Asia/Shanghai",
+		},
+		{name: "无效时区", req: request.DashboardRangeRequest{Timezone: "Mars/Base"}, wantErr: true},
+		{name: "不支持 Local", req: request.DashboardRangeRequest{Timezone: "Local"}, wantErr: true},
+		{name: "无效时间", req: request.DashboardRangeRequest{Start: "2026/03/01"}, wantErr: true},
+		{name: "开始时间不早于结束时间", req: request.DashboardRangeRequest{Start: "2026-03-02", End: "2026-03-01"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := resolveDashboardRange(&tt.req, lastDays(7))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveDashboardRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !r.Start.Equal(tt.wantStart) || !r.End.Equal(tt.wantEnd) {
+				t.Errorf("range = [%v, %v), want [%v, %v)", r.Start, r.End, tt.wantStart, tt.wantEnd)
+			}
+			if r.Start.Location().String() != dashboardDBTimezone {
+				t.Errorf("Start location = %s, want %s", r.Start.Location(), dashboardDBTimezone)
+			}
+			if r.Granularity != tt.wantGranularity || r.Location.String() != tt.wantLocation {
+				t.Errorf("granularity = %s, location = %s, want %s, %s", r.Granularity, r.Location, tt.wantGranularity, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestDashboardRangePrevious(t *testing.T) {
+	loc := shanghai
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name      string
+		start     time.Time
+		end       time.Time
+		wantStart time.Time
+	}{
+		{name: "最近 7 天", start: date(2026, 3, 8), end: date(2026, 3, 15), wantStart: date(2026, 3, 1)},
+		{name: "单日", start: date(2026, 3, 1), end: date(2026, 3, 2), wantStart: date(2026, 2, 28)},
+		{name: "整月按月平移", start: date(2026, 3, 1), end: date(2026, 4, 1), wantStart: date(2026, 2, 1)},
+		{name: "自然年", start: date(2026, 1, 1), end: date(2027, 1, 1), wantStart: date(2025, 1, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &dashboardRange{Start: tt.start, End: tt.end, Granularity: "day", Location: loc}
+			prev := r.previous()
+			if !prev.Start.Equal(tt.wantStart) || !prev.End.Equal(tt.start) {
+				t.Errorf("previous() = [%v, %v), want [%v, %v)", prev.Start, prev.End, tt.wantStart, tt.start)
+			}
+		})
+	}
+}
+
+func TestDashboardRangeBuckets(t *testing.T) {
+	loc := shanghai
+	at := func(m time.Month, d, h int) time.Time {
+		return time.Date(2026, m, d, h, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name        string
+		start       time.Time
+		end         time.Time
+		granularity string
+		wantFirst   time.Time
+		wantLen     int
+		wantErr     bool
+	}{
+		{name: "按天", start: at(3, 1, 0), end: at(3, 8, 0), granularity: "day", wantFirst: at(3, 1, 0), wantLen: 7},
+		{name: "按小时", start: at(3, 1, 0), end: at(3, 2, 0), granularity: "hour", wantFirst: at(3, 1, 0), wantLen: 24},
+		{name: "按周从周一开始", start: at(3, 4, 0), end: at(3, 18, 0), granularity: "week", wantFirst: at(3, 2, 0), wantLen: 3},
+		{name: "按月", start: at(1, 15, 0), end: at(4, 1, 0), granularity: "month", wantFirst: at(1, 1, 0), wantLen: 3},
+		{name: "起点不在整点时对齐", start: at(3, 1, 10), end: at(3, 3, 0), granularity: "day", wantFirst: at(3, 1, 0), wantLen: 2},
+		{name: "不限时间", granularity: "day", wantErr: true},
+		{name: "超过桶数上限", start: at(1, 1, 0), end: at(3, 1, 0), granularity: "hour", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &dashboardRange{Start: tt.start, End: tt.end, Granularity: tt.granularity, Location: loc}
+			buckets, err := r.buckets()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buckets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(buckets) != tt.wantLen || !buckets[0].Equal(tt.wantFirst) {
+				t.Errorf("buckets() = %d 个，首个 %v，want %d 个，首个 %v", len(buckets), buckets[0], tt.wantLen, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestFillBuckets(t *testing.T) {
+	loc := newYork
+	buckets := []time.Time{
+		time.Date(2026, 3, 1, 0, 0, 0, 0, loc),
+		time.Date(2026, 3, 2, 0, 0, 0, 0, loc),
+		time.Date(2026, 3, 3, 0, 0, 0, 0, loc),
+	}
+	// 数据库返回的桶起点为不带时区的本地时间，扫描后位于 UTC
+	bucket := func(d int) time.Time {
+		return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name        string
+		rows        []db.BucketCount
+		wantTotal   []int64
+		wantSuccess []int64
+	}{
+		{name: "没有记录", wantTotal: []int64{0, 0, 0}, wantSuccess: []int64{0, 0, 0}},
+		{
+			name:        "按年月日时对应",
+			rows:        []db.BucketCount{{Bucket: bucket(1), Total: 5, Success: 3}, {Bucket: bucket(3), Total: 2, Success: 2}},
+			wantTotal:   []int64{5, 0, 2},
+			wantSuccess: []int64{3, 0, 2},
+		},
+		{
+			name:        "忽略范围外的桶",
+			rows:        []db.BucketCount{{Bucket: bucket(4), Total: 9, Success: 9}},
+			wantTotal:   []int64{0, 0, 0},
+			wantSuccess: []int64{0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, success := fillBuckets(buckets, tt.rows)
+			if !reflect.DeepEqual(total, tt.wantTotal) || !reflect.DeepEqual(success, tt.wantSuccess) {
+				t.Errorf("fillBuckets() = %v, %v, want %v, %v", total, success, tt.wantTotal, tt.wantSuccess)
+			}
+		})
+	}
+}
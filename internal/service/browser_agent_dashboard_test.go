@@ -0,0 +1,32 @@
+package service
+
+import (
+	"Art-Design-Backend/config"
+	"Art-Design-Backend/internal/model/query"
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/pkg/jwt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestAdminDashboardAccess 非管理员调用管理员仪表盘返回 403，不查询任何数据
+func TestAdminDashboardAccess(t *testing.T) {
+	s := &BrowserAgentDashboardService{
+		RoleRepo:           newRoleRepo(t),
+		BrowserAgentConfig: &config.BrowserAgent{AdminRoleCodes: []string{"admin"}},
+	}
+
+	if err := s.CheckAdmin(t.Context(), adminID); err != nil {
+		t.Errorf("CheckAdmin(admin) = %v", err)
+	}
+	wantAccessError(t, s.CheckAdmin(t.Context(), ownerID), 403)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("claims", &jwt.CustomClaims{BaseClaims: jwt.NewBaseClaims(ownerID)})
+	_, err := s.GetAdminTokenUsage(c, 10, &request.DashboardRangeRequest{})
+	wantAccessError(t, err, 403)
+	_, err = s.GetMessagePage(c, &query.BrowserAgentMessage{})
+	wantAccessError(t, err, 403)
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"Art-Design-Backend/config"
+	"Art-Design-Backend/internal/repository"
+	"Art-Design-Backend/internal/repository/db"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newMockService 创建使用 sqlmock 数据库的 BrowserAgentService，配置为零值，由测试按需设置
+//
+// SQL 按正则匹配且须按顺序执行，测试结束时校验期望的 SQL 均已执行
+func newMockService(t *testing.T) (*BrowserAgentService, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = sqlDB.Close()
+	})
+
+	return &BrowserAgentService{
+		BrowserAgentRepo:   &repository.BrowserAgentRepo{BrowserAgentDB: db.NewBrowserAgentDB(gormDB)},
+		GormTX:             db.NewGormTransactionManager(gormDB),
+		BrowserAgentConfig: &config.BrowserAgent{},
+	}, mock
+}
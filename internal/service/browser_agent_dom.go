@@ -0,0 +1,218 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ai"
+	"Art-Design-Backend/pkg/ws"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	// pageElementsContextShare 可交互元素列表最多占用模型上下文的 1/pageElementsContextShare
+	pageElementsContextShare = 4
+	// pageElementsMinTokens / pageElementsMaxTokens 可交互元素列表的 token 预算范围
+	pageElementsMinTokens = 800
+	pageElementsMaxTokens = 8000
+)
+
+// pageElementsTokenBudget 根据模型上下文长度计算可交互元素列表的 token 预算，未配置上下文长度时使用上限
+func pageElementsTokenBudget(model *entity.AIModel) int {
+	if model == nil || model.MaxContextTokens <= 0 {
+		return pageElementsMaxTokens
+	}
+	return min(max(model.MaxContextTokens/pageElementsContextShare, pageElementsMinTokens), pageElementsMaxTokens)
+}
+
+// rankedElement 参与排序的元素，index 为元素在 PageState.Elements 中的序号（从 1 开始）
+type rankedElement struct {
+	index int
+	score int
+	line  string
+}
+
+// selectPageElements 在 token 预算内挑选提示词中展示的元素，返回按页面顺序排列的元素行与省略的元素数
+//
+// 排序依据：
+//   - 与任务（及当前子目标）的关键词匹配度
+//   - 可见性：位于当前视口内的元素优先，尺寸为 0 的隐藏元素靠后
+//   - 元素类型：表单字段与按钮优先于普通链接
+//
+// 文本相同的链接只保留第一个；元素始终使用原始序号，与截图标注及 element_index 一致
+func selectPageElements(pageState *ws.PageState, keywords []string, budget int) ([]string, int) {
+	candidates := make([]rankedElement, 0, len(pageState.Elements))
+	seenLinks := make(map[string]bool)
+	for i := range pageState.Elements {
+		elem := &pageState.Elements[i]
+		if elem.Tag == "a" {
+			key := strings.TrimSpace(elem.Text)
+			if key != "" && seenLinks[key] {
+				continue
+			}
+			seenLinks[key] = true
+		}
+		candidates = append(candidates, rankedElement{
+			index: i + 1,
+			score: scoreElement(elem, keywords, pageState.ScrollInfo),
+			line:  formatPageElement(i+1, elem),
+		})
+	}
+
+	ranked := slices.Clone(candidates)
+	slices.SortStableFunc(ranked, func(a, b rankedElement) int {
+		return cmp.Compare(b.score, a.score)
+	})
+
+	used := 0
+	kept := make([]rankedElement, 0, len(ranked))
+	for _, elem := range ranked {
+		tokens := ai.EstimateTokens(elem.line)
+		if used+tokens > budget {
+			continue
+		}
+		used += tokens
+		kept = append(kept, elem)
+	}
+	slices.SortFunc(kept, func(a, b rankedElement) int {
+		return cmp.Compare(a.index, b.index)
+	})
+
+	lines := make([]string, len(kept))
+	for i, elem := range kept {
+		lines[i] = elem.line
+	}
+	return lines, len(pageState.Elements) - len(kept)
+}
+
+// scoreElement 计算元素与任务的相关度得分
+func scoreElement(elem *ws.PageElement, keywords []string, scroll *ws.ScrollInfo) int {
+	score := 0
+
+	text := strings.ToLower(elem.Text)
+	if elem.Label != nil {
+		text += " " + strings.ToLower(*elem.Label)
+	}
+	if elem.Value != nil {
+		text += " " + strings.ToLower(*elem.Value)
+	}
+	if strings.TrimSpace(text) == "" {
+		score -= 2
+	}
+	for _, kw := range keywords {
+		if strings.Contains(text, kw) {
+			score += 3
+		}
+	}
+
+	switch elem.Tag {
+	case "input", "textarea", "select":
+		score += 4
+	case "button":
+		score += 2
+	}
+
+	if p := elem.Position; p != nil {
+		if p.Width <= 0 || p.Height <= 0 {
+			score -= 5
+		} else if scroll != nil && scroll.ClientHeight > 0 {
+			if p.Y+p.Height > 0 && p.Y < scroll.ClientHeight {
+				score += 5
+			} else {
+				// 距离视口越远得分越低，最多扣 5 分
+				offset := max(-(p.Y + p.Height), p.Y-scroll.ClientHeight)
+				score -= min(int(offset/scroll.ClientHeight)+1, 5)
+			}
+		}
+	}
+	return score
+}
+
+// taskKeywords 从任务描述中提取用于匹配元素的关键词：英文按单词，中文按相邻两字切分
+func taskKeywords(texts ...string) []string {
+	seen := make(map[string]bool)
+	var keywords []string
+	add := func(kw string) {
+		if !seen[kw] {
+			seen[kw] = true
+			keywords = append(keywords, kw)
+		}
+	}
+
+	for _, text := range texts {
+		var word, han []rune
+		flush := func() {
+			if len(word) >= 2 {
+				add(strings.ToLower(string(word)))
+			}
+			if len(han) == 1 {
+				add(string(han))
+			}
+			for i := 0; i+1 < len(han); i++ {
+				add(string(han[i : i+2]))
+			}
+			word, han = word[:0], han[:0]
+		}
+		for _, r := range text {
+			switch {
+			case unicode.Is(unicode.Han, r):
+				if len(word) > 0 {
+					flush()
+				}
+				han = append(han, r)
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				if len(han) > 0 {
+					flush()
+				}
+				word = append(word, r)
+			default:
+				flush()
+			}
+		}
+		flush()
+	}
+	return keywords
+}
+
+// formatPageElement 将元素格式化为提示词中的一行，模型通过序号引用元素
+func formatPageElement(index int, elem *ws.PageElement) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d. ", index))
+
+	switch elem.Tag {
+	case "input", "textarea":
+		typeInfo := "text"
+		if elem.Type != nil {
+			typeInfo = *elem.Type
+		}
+		labelInfo := elem.Text
+		if elem.Label != nil && *elem.Label != "" {
+			labelInfo = *elem.Label
+		}
+		sb.WriteString(fmt.Sprintf("[%s] label=\"%s\"", typeInfo, labelInfo))
+		if elem.Value != nil && *elem.Value != "" {
+			sb.WriteString(fmt.Sprintf(" value=\"%s\"", *elem.Value))
+		}
+
+	case "select":
+		labelInfo := elem.Text
+		if elem.Label != nil && *elem.Label != "" {
+			labelInfo = *elem.Label
+		}
+		sb.WriteString(fmt.Sprintf("[select] label=\"%s\"", labelInfo))
+
+	case "button":
+		sb.WriteString(fmt.Sprintf("[button] text=\"%s\"", elem.Text))
+
+	case "a":
+		sb.WriteString(fmt.Sprintf("[link] text=\"%s\"", elem.Text))
+
+	default:
+		sb.WriteString(fmt.Sprintf("[%s] text=\"%s\"", elem.Tag, elem.Text))
+	}
+
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"Art-Design-Backend/config"
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ai"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// EvalScenario 离线评估场景：录制的页面状态与脚本化的页面跳转
+//
+// 智能体从 StartPage 开始决策，每个操作按 Transitions 切换页面，没有匹配的跳转时页面保持不变；
+// 在 GoalPages 中的页面上结束任务视为成功
+type EvalScenario struct {
+	Name          string                   `json:"name"`
+	Task          string                   `json:"task"`
+	ExtractSchema map[string]any           `json:"extract_schema,omitempty"`
+	Secrets       map[string]string        `json:"secrets,omitempty"` // 任务中可引用的凭据（名称 -> 测试值）
+	Pages         map[string]*ws.PageState `json:"pages"`             // 页面名称 -> 录制的页面状态
+	StartPage     string                   `json:"start_page"`
+	Transitions   []EvalTransition         `json:"transitions"`
+	GoalPages     []string                 `json:"goal_pages"`
+	MaxSteps      int                      `json:"max_steps,omitempty"` // 缺省使用 max-steps-per-message
+}
+
+// EvalTransition 页面跳转规则：在 From 页面执行匹配的操作后切换到 To 页面
+//
+// Selector / URL 为空时不参与匹配；URL 按前缀匹配 goto/open_tab 的目标地址
+type EvalTransition struct {
+	From     string `json:"from"` // "*" 表示任意页面
+	Action   string `json:"action"`
+	Selector string `json:"selector,omitempty"`
+	URL      string `json:"url,omitempty"`
+	To       string `json:"to"`
+}
+
+func (t *EvalTransition) match(page string, action *ws.Action) bool {
+	if (t.From != "*" && t.From != page) || t.Action != action.Action {
+		return false
+	}
+	if t.Selector != "" && (action.Selector == nil || *action.Selector != t.Selector) {
+		return false
+	}
+	if t.URL != "" && (action.URL == nil || !strings.HasPrefix(*action.URL, t.URL)) {
+		return false
+	}
+	return true
+}
+
+// EvalResult 单个场景的评估结果
+type EvalResult struct {
+	Name           string `json:"name"`
+	Success        bool   `json:"success"`
+	Steps          int    `json:"steps"`           // 模型决策次数（含无效操作）
+	InvalidActions int    `json:"invalid_actions"` // 解析或校验失败、引用了页面上不存在元素的操作数
+	FinalPage      string `json:"final_page"`
+	Error          string `json:"error,omitempty"`
+}
+
+// InvalidRate 无效操作占全部决策的比例
+func (r *EvalResult) InvalidRate() float64 {
+	if r.Steps == 0 {
+		return 0
+	}
+	return float64(r.InvalidActions) / float64(r.Steps)
+}
+
+// BrowserAgentEvaluator 离线评估器，使用与线上相同的提示词构建、模型调用、解析与校验流程，
+// 不依赖数据库、Redis 与 WebSocket，用于对比提示词或元素筛选调整前后的效果
+type BrowserAgentEvaluator struct {
+	svc   *BrowserAgentService
+	model *entity.AIModel
+}
+
+func NewBrowserAgentEvaluator(
+	client *ai.AIModelClient,
+	cfg *config.BrowserAgent,
+	model *entity.AIModel,
+	provider *entity.AIProvider,
+) *BrowserAgentEvaluator {
+	return &BrowserAgentEvaluator{
+		svc: &BrowserAgentService{
+			AIModelClient:      client,
+			BrowserAgentConfig: cfg,
+			fixedProvider:      provider,
+		},
+		model: model,
+	}
+}
+
+// Run 执行一个场景，直到模型结束任务或达到步数上限
+func (e *BrowserAgentEvaluator) Run(ctx context.Context, sc *EvalScenario) *EvalResult {
+	result := &EvalResult{Name: sc.Name, FinalPage: sc.StartPage}
+
+	page, state := sc.StartPage, sc.Pages[sc.StartPage]
+	if state == nil {
+		result.Error = fmt.Sprintf("起始页面 %s 不存在", sc.StartPage)
+		return result
+	}
+
+	maxSteps := sc.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = e.svc.BrowserAgentConfig.MaxStepsPerMessage
+	}
+
+	var (
+		actions  []*entity.BrowserAgentAction
+		feedback string
+	)
+	for result.Steps < maxSteps {
+		input := &decisionInput{
+			Task:          sc.Task,
+			Model:         e.model,
+			Actions:       actions,
+			PageState:     state,
+			Secrets:       sc.Secrets,
+			Feedback:      feedback,
+			ExtractSchema: sc.ExtractSchema,
+		}
+		feedback = ""
+
+		var (
+			action   *ws.Action
+			finished bool
+			err      error
+		)
+		if result.Steps == 0 {
+			action, err = e.svc.decideAction(ctx, input)
+			finished = action != nil && (action.Action == "finish_task" || action.Action == "submit_result")
+		} else {
+			action, finished, err = e.svc.decideNextAction(ctx, input)
+		}
+		result.Steps++
+
+		if err == nil && !finished && action.Selector != nil && findElementBySelector(state, action.Selector) == nil {
+			err = fmt.Errorf("页面上不存在元素 %s", *action.Selector)
+		}
+		if err != nil {
+			result.InvalidActions++
+			result.Error = err.Error()
+			feedback = "上一步操作无效：" + err.Error()
+			if action != nil {
+				actions = append(actions, evalActionRecord(action, entity.ActionStatusFailed, state, err.Error()))
+			}
+			continue
+		}
+		result.Error = ""
+
+		if finished {
+			result.Success = slices.Contains(sc.GoalPages, page)
+			if !result.Success {
+				result.Error = fmt.Sprintf("在页面 %s 上结束了任务", page)
+			}
+			return result
+		}
+
+		for i := range sc.Transitions {
+			if sc.Transitions[i].match(page, action) {
+				if next := sc.Pages[sc.Transitions[i].To]; next != nil {
+					page, state = sc.Transitions[i].To, next
+				}
+				break
+			}
+		}
+		result.FinalPage = page
+		actions = append(actions, evalActionRecord(action, entity.ActionStatusSuccess, state, ""))
+	}
+
+	if result.Error == "" {
+		result.Error = fmt.Sprintf("达到步数上限(%d)仍未结束任务", maxSteps)
+	}
+	return result
+}
+
+// evalActionRecord 构造操作轨迹记录，供后续决策的【已执行操作】使用
+func evalActionRecord(action *ws.Action, status string, state *ws.PageState, errMsg string) *entity.BrowserAgentAction {
+	record := &entity.BrowserAgentAction{
+		ActionType: action.Action,
+		Status:     status,
+		URL:        action.URL,
+		Selector:   action.Selector,
+		Value:      action.Value,
+		Distance:   action.Distance,
+		Timeout:    action.Timeout,
+		Key:        action.Key,
+		TabIndex:   action.TabIndex,
+		FileURL:    action.FileURL,
+	}
+	if errMsg != "" {
+		record.ErrorMessage = &errMsg
+	}
+	fingerprint := state.Fingerprint()
+	record.PageFingerprint = &fingerprint
+	record.ResultURL = &state.URL
+	return record
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/pkg/authutils"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+)
+
+// csvBOM 让 Excel 以 UTF-8 打开导出的 CSV，避免中文乱码
+const csvBOM = "\xEF\xBB\xBF"
+
+// ExportFile 导出的文件
+type ExportFile struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// ExportExtractResult 导出任务的采集结果，format 支持 json（默认）与 csv
+func (s *BrowserAgentService) ExportExtractResult(c *gin.Context, req *request.DownloadExtractResultRequest) (*ExportFile, error) {
+	msg, _, err := s.getOwnMessage(c, authutils.GetUserID(c), req.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.ExtractResult == nil {
+		return nil, errors.New("该任务没有采集结果")
+	}
+
+	if req.Format == "csv" {
+		content, err := extractResultToCSV(msg.ExtractResult)
+		if err != nil {
+			return nil, err
+		}
+		return &ExportFile{
+			FileName:    fmt.Sprintf("extract_result_%d.csv", msg.ID),
+			ContentType: "text/csv; charset=utf-8",
+			Content:     content,
+		}, nil
+	}
+
+	content, err := sonic.ConfigStd.MarshalIndent(msg.ExtractResult, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化采集结果失败: %w", err)
+	}
+	return &ExportFile{
+		FileName:    fmt.Sprintf("extract_result_%d.json", msg.ID),
+		ContentType: "application/json; charset=utf-8",
+		Content:     content,
+	}, nil
+}
+
+// validateExtractSchema 校验用户提交的结果 Schema，只支持顶层为 object 或 array
+func validateExtractSchema(schema map[string]any) error {
+	switch schema["type"] {
+	case "object", "array":
+		return nil
+	default:
+		return errors.New("extract_schema 顶层 type 必须为 object 或 array")
+	}
+}
+
+// validateExtractResult 按 Schema 对模型提交的结果做基础校验：顶层类型与必填字段
+//
+// 不做完整的 JSON Schema 校验，只拦截明显不符合要求的结果，让模型在下一轮修正
+func validateExtractResult(schema map[string]any, data any) error {
+	if data == nil {
+		return errors.New("submit_result 缺少 data")
+	}
+	if len(schema) == 0 {
+		return nil
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := data.(map[string]any)
+		if !ok {
+			return errors.New("采集结果应为对象")
+		}
+		return checkRequiredFields(schema, obj)
+	case "array":
+		items, ok := data.([]any)
+		if !ok {
+			return errors.New("采集结果应为数组")
+		}
+		itemSchema, _ := schema["items"].(map[string]any)
+		for i, item := range items {
+			obj, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			if err := checkRequiredFields(itemSchema, obj); err != nil {
+				return fmt.Errorf("第 %d 条记录: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func checkRequiredFields(schema map[string]any, obj map[string]any) error {
+	required, _ := schema["required"].([]any)
+	var missing []string
+	for _, field := range required {
+		name, ok := field.(string)
+		if !ok {
+			continue
+		}
+		if _, exists := obj[name]; !exists {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("缺少必填字段: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// extractResultToCSV 将采集结果展开为表格：
+// 对象数组每个元素一行；仅包含一个数组字段的对象取该数组；单个对象作为一行
+func extractResultToCSV(result any) ([]byte, error) {
+	rows := extractResultRows(result)
+
+	var headers []string
+	for _, row := range rows {
+		for key := range row {
+			if !slices.Contains(headers, key) {
+				headers = append(headers, key)
+			}
+		}
+	}
+	slices.Sort(headers)
+
+	var buf bytes.Buffer
+	buf.WriteString(csvBOM)
+	w := csv.NewWriter(&buf)
+	if err := w.Write(headers); err != nil {
+		return nil, fmt.Errorf("生成 CSV 失败: %w", err)
+	}
+	for _, row := range rows {
+		record := make([]string, len(headers))
+		for i, key := range headers {
+			record[i] = csvCell(row[key])
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("生成 CSV 失败: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("生成 CSV 失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func extractResultRows(result any) []map[string]any {
+	switch v := result.(type) {
+	case []any:
+		rows := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			if obj, ok := item.(map[string]any); ok {
+				rows = append(rows, obj)
+			} else {
+				rows = append(rows, map[string]any{"value": item})
+			}
+		}
+		return rows
+	case map[string]any:
+		var arrays [][]any
+		for _, field := range v {
+			if arr, ok := field.([]any); ok {
+				arrays = append(arrays, arr)
+			}
+		}
+		if len(arrays) == 1 {
+			return extractResultRows(arrays[0])
+		}
+		return []map[string]any{v}
+	default:
+		return []map[string]any{{"value": v}}
+	}
+}
+
+func csvCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		s, err := sonic.MarshalString(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return s
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/internal/model/response"
+	"Art-Design-Backend/pkg/authutils"
+	"Art-Design-Backend/pkg/constant/llmid"
+	"Art-Design-Backend/pkg/constant/settingkey"
+	"Art-Design-Backend/pkg/errors"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// browserAgentModelTypes 可用于浏览器智能体决策的模型类型
+var browserAgentModelTypes = []string{"chat", "multimodal"}
+
+// getBrowserModel 获取模型并校验其可用于浏览器智能体：类型为对话/多模态且已启用
+func (s *BrowserAgentService) getBrowserModel(c context.Context, modelID int64) (*entity.AIModel, error) {
+	model, err := s.AIModelRepo.GetAIModelByIDWithCache(c, modelID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(browserAgentModelTypes, model.ModelType) {
+		return nil, fmt.Errorf("模型 %s 的类型 %s 不支持浏览器智能体", model.Model, model.ModelType)
+	}
+	if !model.Enabled {
+		return nil, fmt.Errorf("模型 %s 已停用", model.Model)
+	}
+	return model, nil
+}
+
+// getDefaultModelID 读取系统默认模型ID，未配置时使用内置模型
+func (s *BrowserAgentService) getDefaultModelID(c context.Context) (int64, error) {
+	setting, err := s.SystemSettingRepo.GetSetting(c, settingkey.BrowserAgentDefaultModelID)
+	if err != nil {
+		return 0, err
+	}
+	if setting == nil {
+		return llmid.BrowserModelID, nil
+	}
+	modelID, err := strconv.ParseInt(setting.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("系统默认模型配置无效: %s", setting.Value)
+	}
+	return modelID, nil
+}
+
+// resolveConversationModel 解析会话实际使用的决策模型
+//
+// 会话指定的模型被停用或删除后退回系统默认模型，避免进行中的会话直接不可用
+func (s *BrowserAgentService) resolveConversationModel(c context.Context, conv *entity.BrowserAgentConversation) (*entity.AIModel, error) {
+	if conv.ModelID != 0 {
+		model, err := s.getBrowserModel(c, conv.ModelID)
+		if err == nil {
+			return model, nil
+		}
+		zap.L().Warn("会话模型不可用，使用系统默认模型",
+			zap.Int64("conversationID", conv.ID),
+			zap.Int64("modelID", conv.ModelID),
+			zap.Error(err),
+		)
+	}
+
+	defaultModelID, err := s.getDefaultModelID(c)
+	if err != nil {
+		return nil, err
+	}
+	return s.getBrowserModel(c, defaultModelID)
+}
+
+func (s *BrowserAgentService) UpdateConversationModel(c *gin.Context, req *request.UpdateConversationModelRequest) error {
+	conv, err := s.getOwnerConversation(c, authutils.GetUserID(c), int64(req.ID))
+	if err != nil {
+		return err
+	}
+	if req.ModelID != 0 {
+		if _, err = s.getBrowserModel(c, int64(req.ModelID)); err != nil {
+			return err
+		}
+	}
+	conv.ModelID = int64(req.ModelID)
+	return s.BrowserAgentRepo.UpdateConversation(c, conv)
+}
+
+func (s *BrowserAgentService) GetDefaultModel(c *gin.Context) (*response.DefaultModelResponse, error) {
+	modelID, err := s.getDefaultModelID(c)
+	if err != nil {
+		return nil, err
+	}
+	model, err := s.AIModelRepo.GetAIModelByIDWithCache(c, modelID)
+	if err != nil {
+		return nil, err
+	}
+	return &response.DefaultModelResponse{ModelID: model.ID, Model: model.Model}, nil
+}
+
+// SetDefaultModel 设置全部用户共用的默认决策模型，仅管理员可操作
+func (s *BrowserAgentService) SetDefaultModel(c *gin.Context, req *request.SetDefaultModelRequest) error {
+	userID := authutils.GetUserID(c)
+	if !s.isAdmin(c, userID) {
+		return errors.NewForbiddenError("仅管理员可设置默认模型")
+	}
+	if _, err := s.getBrowserModel(c, int64(req.ModelID)); err != nil {
+		return err
+	}
+	return s.SystemSettingRepo.SaveSetting(c, &entity.SystemSetting{
+		Key:      settingkey.BrowserAgentDefaultModelID,
+		Value:    strconv.FormatInt(int64(req.ModelID), 10),
+		Remark:   "浏览器智能体默认决策模型",
+		UpdateBy: userID,
+	})
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ai"
+	"Art-Design-Backend/pkg/constant/prompt"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+)
+
+// maxPlanSteps 计划中子目标数量上限，超出部分丢弃
+const maxPlanSteps = 10
+
+// ensurePlan 开启计划的任务在首次决策前将任务拆解为子目标
+//
+// 计划只是辅助决策，拆解失败时记录日志后不使用计划继续执行
+func (s *BrowserAgentService) ensurePlan(c context.Context, message *entity.BrowserAgentMessage, input *decisionInput) {
+	if !message.PlanEnabled || message.WorkflowID != 0 || len(input.Plan) > 0 {
+		return
+	}
+
+	ws.Emit(c, ws.ServerMessagePlanning, message.ID, "正在拆解任务")
+	goals, err := s.generatePlan(c, input)
+	if err != nil {
+		zap.L().Warn("任务拆解失败，不使用计划继续执行", zap.Int64("messageID", message.ID), zap.Error(err))
+		return
+	}
+
+	plan := make([]entity.PlanStep, len(goals))
+	for i, goal := range goals {
+		plan[i] = entity.PlanStep{Goal: goal}
+	}
+	if err = s.savePlan(c, input, plan, false); err != nil {
+		zap.L().Error("保存任务计划失败", zap.Int64("messageID", message.ID), zap.Error(err))
+		return
+	}
+	zap.L().Info("任务计划已生成", zap.Int64("messageID", message.ID), zap.Strings("goals", goals))
+}
+
+// generatePlan 请求模型将任务拆解为按顺序完成的子目标
+func (s *BrowserAgentService) generatePlan(c context.Context, input *decisionInput) ([]string, error) {
+	provider, err := s.getProvider(c, input.Model.ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("获取浏览器智能体模型供应商失败: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("【用户目标】\n" + input.Task + "\n\n")
+	sb.WriteString(s.buildExtractSection(input.ExtractSchema))
+	if input.PageState != nil {
+		sb.WriteString("【当前页面】\n")
+		sb.WriteString("URL: " + input.PageState.URL + "\n")
+		sb.WriteString("标题: " + input.PageState.Title + "\n")
+	}
+
+	chatReq := ai.DefaultChatRequest(
+		input.Model.Model,
+		[]ai.ChatMessage{
+			{Role: "system", Content: prompt.BrowserPlanPrompt},
+			{Role: "user", Content: sb.String()},
+		},
+	)
+
+	url := provider.BaseURL + input.Model.APIPath
+	call := newLLMCall(input.Model, sb.String(), false)
+	var respJSON []byte
+	if onThinking := s.thinkingSink(c, input.MessageID); onThinking != nil {
+		respJSON, err = s.AIModelClient.ChatStreamRequest(c, url, provider.APIKey, chatReq, onThinking)
+	} else {
+		respJSON, err = s.AIModelClient.ChatRequest(c, url, provider.APIKey, chatReq)
+	}
+	finishLLMCall(call, input.Model, respJSON, err)
+	s.recordLLMCall(c, input, call)
+	if err != nil {
+		return nil, fmt.Errorf("调用LLM失败: %w", err)
+	}
+
+	var resp ai.ChatCompletionResponse
+	if err = sonic.Unmarshal(respJSON, &resp); err != nil {
+		return nil, fmt.Errorf("解析 LLM 原始响应失败: %w", err)
+	}
+	cleanJSON, err := ai.ExtractJSONFromLLMOutput(strings.TrimSpace(resp.FirstText()))
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Steps []string `json:"steps"`
+	}
+	if err = sonic.UnmarshalString(cleanJSON, &result); err != nil {
+		return nil, fmt.Errorf("解析任务计划失败: %w", err)
+	}
+	goals := cleanGoals(result.Steps)
+	if len(goals) == 0 {
+		return nil, errors.New("任务计划为空")
+	}
+	return goals, nil
+}
+
+// cleanGoals 去除空白子目标并限制数量
+func cleanGoals(goals []string) []string {
+	cleaned := make([]string, 0, len(goals))
+	for _, goal := range goals {
+		if goal = strings.TrimSpace(goal); goal != "" {
+			cleaned = append(cleaned, goal)
+		}
+	}
+	if len(cleaned) > maxPlanSteps {
+		cleaned = cleaned[:maxPlanSteps]
+	}
+	return cleaned
+}
+
+// updatePlan 根据模型随操作报告的进度推进计划，模型给出 revised_plan 时以其替换未完成的子目标；
+// 任务完成时所有子目标标记为完成。处理后清空操作中的计划字段，不下发给客户端
+//
+// 已完成的子目标始终是计划的前缀，进度只增不减
+func (s *BrowserAgentService) updatePlan(c context.Context, input *decisionInput, action *ws.Action, finished bool) error {
+	progress, revisedGoals := action.PlanProgress, cleanGoals(action.RevisedPlan)
+	action.PlanProgress, action.RevisedPlan = nil, nil
+	if len(input.Plan) == 0 {
+		return nil
+	}
+
+	plan := slices.Clone(input.Plan)
+	done := countDoneSteps(plan)
+
+	completed := done
+	switch {
+	case finished:
+		completed = len(plan)
+	case progress != nil && *progress > done:
+		completed = min(*progress, len(plan))
+	}
+	for i := done; i < completed; i++ {
+		plan[i].Done = true
+	}
+
+	revised := !finished && len(revisedGoals) > 0
+	if revised {
+		plan = plan[:completed]
+		for _, goal := range revisedGoals {
+			plan = append(plan, entity.PlanStep{Goal: goal})
+		}
+		zap.L().Info("任务计划已修订", zap.Int64("messageID", input.MessageID), zap.Strings("goals", revisedGoals))
+	}
+
+	if !revised && completed == done {
+		return nil
+	}
+	return s.savePlan(c, input, plan, revised)
+}
+
+// savePlan 保存计划并向客户端推送最新计划
+func (s *BrowserAgentService) savePlan(c context.Context, input *decisionInput, plan []entity.PlanStep, revised bool) error {
+	data, err := sonic.MarshalString(plan)
+	if err != nil {
+		return fmt.Errorf("序列化任务计划失败: %w", err)
+	}
+	if err = s.BrowserAgentRepo.UpdateMessagePlan(c, input.MessageID, data, revised); err != nil {
+		return err
+	}
+	input.Plan = plan
+
+	text := "计划已完成"
+	if done := countDoneSteps(plan); done < len(plan) {
+		text = fmt.Sprintf("子目标 %d/%d：%s", done+1, len(plan), plan[done].Goal)
+	}
+	if revised {
+		text = "计划已修订，" + text
+	}
+	ws.EmitData(c, ws.ServerMessagePlan, input.MessageID, text, plan)
+	return nil
+}
+
+func countDoneSteps(plan []entity.PlanStep) int {
+	done := 0
+	for done < len(plan) && plan[done].Done {
+		done++
+	}
+	return done
+}
+
+func (s *BrowserAgentService) buildPlanSection(plan []entity.PlanStep) string {
+	if len(plan) == 0 {
+		return ""
+	}
+
+	current := countDoneSteps(plan)
+	var sb strings.Builder
+	sb.WriteString("【任务计划】\n")
+	for i, step := range plan {
+		status := "待完成"
+		switch {
+		case step.Done:
+			status = "已完成"
+		case i == current:
+			status = "当前"
+		}
+		sb.WriteString(fmt.Sprintf("%d. [%s] %s\n", i+1, status, step.Goal))
+	}
+	sb.WriteString("请围绕当前子目标决策，并通过 plan_progress 报告已完成的子目标总数；" +
+		"页面与计划明显不符时通过 revised_plan 给出新的剩余子目标。\n\n")
+	return sb.String()
+}
+
+// withPlanParams 为工具补充报告计划进度与修订计划的参数，返回新的工具列表
+func withPlanParams(tools []ai.Tool) []ai.Tool {
+	planned := make([]ai.Tool, len(tools))
+	for i, tool := range tools {
+		parameters := maps.Clone(tool.Function.Parameters.(map[string]any))
+		properties := maps.Clone(parameters["properties"].(map[string]any))
+		properties["plan_progress"] = integerParam("结合当前页面，已完成的子目标总数（从计划开头起算）")
+		properties["revised_plan"] = map[string]any{
+			"type":        "array",
+			"items":       map[string]any{"type": "string"},
+			"description": "可选，页面与计划明显不符时给出新的剩余子目标列表",
+		}
+		parameters["properties"] = properties
+		tool.Function.Parameters = parameters
+		planned[i] = tool
+	}
+	return planned
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/internal/model/response"
+	"Art-Design-Backend/pkg/authutils"
+	myerrors "Art-Design-Backend/pkg/errors"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/copier"
+	"go.uber.org/zap"
+)
+
+// urlPolicyViolation 操作违反 URL 访问策略
+type urlPolicyViolation struct {
+	Action *ws.Action
+	Reason string
+}
+
+func (e *urlPolicyViolation) Error() string {
+	return "URL 安全策略拒绝: " + e.Reason
+}
+
+// hostLookupTimeout 校验内网地址时解析域名的超时时间
+const hostLookupTimeout = 3 * time.Second
+
+// urlPolicy 加载后的 URL 策略，正则在加载时编译一次
+type urlPolicy struct {
+	*entity.BrowserAgentURLPolicy
+	allowed []*regexp.Regexp
+	denied  []*regexp.Regexp
+}
+
+// compileURLPolicy 编译策略中的正则，规则已在保存时校验，此处编译失败的规则记录日志后忽略
+func compileURLPolicy(policy *entity.BrowserAgentURLPolicy) *urlPolicy {
+	compile := func(patterns []string) []*regexp.Regexp {
+		compiled := make([]*regexp.Regexp, 0, len(patterns))
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				zap.L().Warn("URL 策略正则无效", zap.Int64("policyID", policy.ID), zap.String("pattern", pattern), zap.Error(err))
+				continue
+			}
+			compiled = append(compiled, re)
+		}
+		return compiled
+	}
+	return &urlPolicy{
+		BrowserAgentURLPolicy: policy,
+		allowed:               compile(policy.AllowedPatterns),
+		denied:                compile(policy.DeniedPatterns),
+	}
+}
+
+// loadURLPolicies 加载任务适用的 URL 策略：会话策略 + 会话所有者各角色的策略
+func (s *BrowserAgentService) loadURLPolicies(c context.Context, conv *entity.BrowserAgentConversation) ([]*urlPolicy, error) {
+	roleIDs, err := s.RoleRepo.GetRoleIDListByUserID(c, conv.CreateBy)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := s.BrowserAgentRepo.ListApplicableURLPolicies(c, conv.ID, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	compiled := make([]*urlPolicy, len(policies))
+	for i, policy := range policies {
+		compiled[i] = compileURLPolicy(policy)
+	}
+	return compiled, nil
+}
+
+func (s *BrowserAgentService) loadMessageURLPolicies(c context.Context, messageID int64) ([]*urlPolicy, error) {
+	msg, err := s.BrowserAgentRepo.GetMessageByID(c, messageID)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := s.BrowserAgentRepo.GetConversationByID(c, msg.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadURLPolicies(c, conv)
+}
+
+// decideWithPolicy 执行一次决策；操作违反 URL 策略时记录为失败操作，
+// 并携带拒绝原因让模型重新决策一次，仍违反则任务失败
+func (s *BrowserAgentService) decideWithPolicy(
+	c context.Context,
+	input *decisionInput,
+	decide func(context.Context, *decisionInput) (*ws.Action, bool, error),
+) (*ws.Action, bool, error) {
+	for retried := false; ; retried = true {
+		action, finished, err := decide(c, input)
+
+		var violation *urlPolicyViolation
+		if !errors.As(err, &violation) {
+			return action, finished, err
+		}
+
+		dbAction, err := s.recordPolicyViolation(c, input.MessageID, violation)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if retried {
+			if err = s.endMessage(c, input.MessageID, entity.MessageStateError, violation.Error()); err != nil {
+				return nil, false, err
+			}
+			return nil, false, violation
+		}
+
+		input.Actions = append(input.Actions, dbAction)
+		if input.Feedback != "" {
+			input.Feedback += "\n"
+		}
+		input.Feedback += fmt.Sprintf("上一步 %s 操作被 URL 安全策略拒绝（%s），请改用允许访问的页面完成任务。",
+			violation.Action.Action, violation.Reason)
+		ws.Emit(c, ws.ServerMessageRetrying, input.MessageID, "操作被 URL 安全策略拒绝："+violation.Reason)
+	}
+}
+
+// recordPolicyViolation 将被拦截的操作记录为失败操作，供审计与管理端统计
+func (s *BrowserAgentService) recordPolicyViolation(c context.Context, messageID int64, violation *urlPolicyViolation) (*entity.BrowserAgentAction, error) {
+	zap.L().Warn("操作违反 URL 策略，已拦截",
+		zap.Int64("messageID", messageID),
+		zap.String("action", violation.Action.Action),
+		zap.String("reason", violation.Reason),
+	)
+
+	errMsg := violation.Error()
+	dbAction := s.wsActionToEntity(messageID, violation.Action)
+	dbAction.Status = entity.ActionStatusFailed
+	dbAction.ErrorMessage = &errMsg
+	dbAction.PolicyViolation = true
+	if err := s.BrowserAgentRepo.CreateAction(c, dbAction); err != nil {
+		return nil, err
+	}
+	return dbAction, nil
+}
+
+// checkURLPolicies 依次校验所有策略，返回第一条拒绝原因，全部通过时返回空字符串
+func checkURLPolicies(rawURL string, policies []*urlPolicy) string {
+	if len(policies) == 0 {
+		return ""
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Sprintf("无法解析的 URL: %s", rawURL)
+	}
+
+	for _, policy := range policies {
+		if reason := checkURLPolicy(u, rawURL, policy); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+func checkURLPolicy(u *url.URL, rawURL string, policy *urlPolicy) string {
+	host := u.Hostname()
+
+	if policy.BlockPrivateIP {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Sprintf("禁止访问 %s 协议", u.Scheme)
+		}
+		if isPrivateHost(host) {
+			return fmt.Sprintf("禁止访问内网地址 %s", host)
+		}
+	}
+
+	if matchDomain(host, policy.DeniedDomains) {
+		return fmt.Sprintf("域名 %s 在禁止列表中", host)
+	}
+	if pattern := matchPattern(rawURL, policy.denied); pattern != "" {
+		return fmt.Sprintf("URL 命中禁止规则 %s", pattern)
+	}
+
+	if len(policy.AllowedDomains) == 0 && len(policy.AllowedPatterns) == 0 {
+		return ""
+	}
+	if matchDomain(host, policy.AllowedDomains) || matchPattern(rawURL, policy.allowed) != "" {
+		return ""
+	}
+	return fmt.Sprintf("域名 %s 不在允许列表中", host)
+}
+
+// matchPattern 返回第一个匹配的正则
+func matchPattern(rawURL string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		if re.MatchString(rawURL) {
+			return re.String()
+		}
+	}
+	return ""
+}
+
+// lookupHost 解析域名的全部地址，测试时可替换
+var lookupHost = func(host string) ([]netip.Addr, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hostLookupTimeout)
+	defer cancel()
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// reservedPrefixes IsPrivate 等方法未覆盖的保留地址段
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT
+}
+
+// isPrivateHost 判断主机是否为本机或内网地址
+//
+// IP 字面量（含十进制、十六进制、八进制与省略写法的 IPv4）直接判断，域名解析后任一地址为内网地址即视为内网；
+// 无法解析的域名同样按内网处理。浏览器在客户端另行解析域名，无法防御 DNS 重绑定
+func isPrivateHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") ||
+		strings.HasSuffix(host, ".local") || strings.HasSuffix(host, ".internal") {
+		return true
+	}
+
+	if addr, ok := parseHostIP(host); ok {
+		return isPrivateAddr(addr)
+	}
+
+	addrs, err := lookupHost(host)
+	if err != nil || len(addrs) == 0 {
+		zap.L().Warn("解析域名失败，按内网地址处理", zap.String("host", host), zap.Error(err))
+		return true
+	}
+	for _, addr := range addrs {
+		if isPrivateAddr(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHostIP 解析 IP 字面量，IPv4 兼容 inet_aton 的写法（如 2130706433、0x7f.1、0177.0.0.1）
+func parseHostIP(host string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr, true
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 0, 32)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		values[i] = v
+	}
+
+	// 前面每段占 1 字节，最后一段填充剩余字节
+	var ip uint64
+	for _, v := range values[:len(values)-1] {
+		if v > 0xff {
+			return netip.Addr{}, false
+		}
+		ip = ip<<8 | v
+	}
+	restBits := 8 * (5 - len(values))
+	last := values[len(values)-1]
+	if last >= 1<<restBits {
+		return netip.Addr{}, false
+	}
+	ip = ip<<restBits | last
+	return netip.AddrFrom4([4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}), true
+}
+
+func isPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// =========================
+// URL 策略管理
+// =========================
+
+// ListURLPolicies 管理员可查询全部策略，其他用户只能查询自己会话的策略
+func (s *BrowserAgentService) ListURLPolicies(c *gin.Context, req *request.ListURLPolicyRequest) ([]response.URLPolicyResponse, error) {
+	userID := authutils.GetUserID(c)
+	var ownerID int64
+	if !s.isAdmin(c, userID) {
+		if req.Scope == entity.URLPolicyScopeRole {
+			return nil, myerrors.NewForbiddenError("仅管理员可查看角色策略")
+		}
+		if req.ScopeID != 0 {
+			if _, err := s.getOwnConversation(c, userID, req.ScopeID); err != nil {
+				return nil, err
+			}
+		}
+		ownerID = userID
+	}
+
+	policies, err := s.BrowserAgentRepo.ListURLPolicies(c, req.Scope, req.ScopeID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]response.URLPolicyResponse, len(policies))
+	for i := range policies {
+		_ = copier.Copy(&responses[i], &policies[i])
+	}
+
+	return responses, nil
+}
+
+// SaveURLPolicy 角色策略仅管理员可保存，会话策略需能访问该会话
+func (s *BrowserAgentService) SaveURLPolicy(c *gin.Context, req *request.SaveURLPolicyRequest) error {
+	for _, pattern := range slices.Concat(req.AllowedPatterns, req.DeniedPatterns) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("无效的正则 %s: %w", pattern, err)
+		}
+	}
+
+	userID := authutils.GetUserID(c)
+	switch req.Scope {
+	case entity.URLPolicyScopeRole:
+		if !s.isAdmin(c, userID) {
+			return myerrors.NewForbiddenError("仅管理员可配置角色策略")
+		}
+	case entity.URLPolicyScopeConversation:
+		if _, err := s.getOwnConversation(c, userID, int64(req.ScopeID)); err != nil {
+			return err
+		}
+	}
+
+	return s.BrowserAgentRepo.SaveURLPolicy(c, &entity.BrowserAgentURLPolicy{
+		Scope:           req.Scope,
+		ScopeID:         int64(req.ScopeID),
+		AllowedDomains:  req.AllowedDomains,
+		DeniedDomains:   req.DeniedDomains,
+		AllowedPatterns: req.AllowedPatterns,
+		DeniedPatterns:  req.DeniedPatterns,
+		BlockPrivateIP:  req.BlockPrivateIP,
+		Remark:          req.Remark,
+	})
+}
+
+// DeleteURLPolicy 管理员可删除任意策略，其他用户只能删除自己会话的策略
+func (s *BrowserAgentService) DeleteURLPolicy(c *gin.Context, id int64) error {
+	userID := authutils.GetUserID(c)
+	var ownerID int64
+	if !s.isAdmin(c, userID) {
+		ownerID = userID
+	}
+	return s.BrowserAgentRepo.DeleteURLPolicy(c, id, ownerID)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"errors"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+// stubLookupHost 将域名解析替换为固定结果，测试结束后恢复
+func stubLookupHost(t *testing.T, hosts map[string][]string) {
+	t.Helper()
+	original := lookupHost
+	lookupHost = func(host string) ([]netip.Addr, error) {
+		ips, ok := hosts[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		addrs := make([]netip.Addr, len(ips))
+		for i, ip := range ips {
+			addrs[i] = netip.MustParseAddr(ip)
+		}
+		return addrs, nil
+	}
+	t.Cleanup(func() { lookupHost = original })
+}
+
+func TestIsPrivateHost(t *testing.T) {
+	stubLookupHost(t, map[string][]string{
+		"example.com":    {"93.184.215.14"},
+		"rebind.example": {"93.184.215.14", "10.0.0.5"},
+		"v6.example":     {"2606:2800:21f:cb07:6820:80da:af6b:8b2c"},
+		"empty.example":  {},
+	})
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"localhost", true},
+		{"api.localhost", true},
+		{"printer.local", true},
+		{"db.internal", true},
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"100.64.0.1", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"2130706433", true},
+		{"0x7f000001", true},
+		{"0177.0.0.1", true},
+		{"127.1", true},
+		{"0x7f.1", true},
+		{"8.8.8.8", false},
+		{"134744072", false},
+		{"example.com", false},
+		{"EXAMPLE.COM.", false},
+		{"v6.example", false},
+		{"rebind.example", true},
+		{"empty.example", true},
+		{"unresolvable.example", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := isPrivateHost(tt.host); got != tt.want {
+				t.Errorf("isPrivateHost(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURLPolicy(t *testing.T) {
+	stubLookupHost(t, map[string][]string{
+		"example.com":     {"93.184.215.14"},
+		"api.example.com": {"93.184.215.14"},
+		"evil.com":        {"203.0.113.10"},
+	})
+
+	tests := []struct {
+		name    string
+		policy  entity.BrowserAgentURLPolicy
+		url     string
+		blocked bool
+	}{
+		{name: "空策略不限制", url: "https://evil.com/", blocked: false},
+		{name: "禁止内网地址", policy: entity.BrowserAgentURLPolicy{BlockPrivateIP: true}, url: "http://127.0.0.1:8080/", blocked: true},
+		{name: "禁止十进制写法的内网地址", policy: entity.BrowserAgentURLPolicy{BlockPrivateIP: true}, url: "http://2130706433/", blocked: true},
+		{name: "禁止非 http 协议", policy: entity.BrowserAgentURLPolicy{BlockPrivateIP: true}, url: "file:///etc/passwd", blocked: true},
+		{name: "允许公网地址", policy: entity.BrowserAgentURLPolicy{BlockPrivateIP: true}, url: "https://example.com/", blocked: false},
+		{name: "禁止域名含子域名", policy: entity.BrowserAgentURLPolicy{DeniedDomains: []string{"example.com"}}, url: "https://api.example.com/", blocked: true},
+		{name: "命中禁止规则", policy: entity.BrowserAgentURLPolicy{DeniedPatterns: []string{`/admin`}}, url: "https://example.com/admin/users", blocked: true},
+		{name: "允许域名", policy: entity.BrowserAgentURLPolicy{AllowedDomains: []string{"example.com"}}, url: "https://api.example.com/", blocked: false},
+		{name: "不在允许域名中", policy: entity.BrowserAgentURLPolicy{AllowedDomains: []string{"example.com"}}, url: "https://evil.com/", blocked: true},
+		{name: "命中允许规则", policy: entity.BrowserAgentURLPolicy{AllowedPatterns: []string{`^https://evil\.com/public/`}}, url: "https://evil.com/public/a", blocked: false},
+		{
+			name:    "禁止优先于允许",
+			policy:  entity.BrowserAgentURLPolicy{AllowedDomains: []string{"example.com"}, DeniedPatterns: []string{`logout`}},
+			url:     "https://example.com/logout",
+			blocked: true,
+		},
+		{
+			name:    "无效正则被忽略",
+			policy:  entity.BrowserAgentURLPolicy{DeniedPatterns: []string{`(`}},
+			url:     "https://example.com/",
+			blocked: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reason := checkURLPolicy(u, tt.url, compileURLPolicy(&tt.policy))
+			if (reason != "") != tt.blocked {
+				t.Errorf("checkURLPolicy(%q) = %q, want blocked=%v", tt.url, reason, tt.blocked)
+			}
+		})
+	}
+}
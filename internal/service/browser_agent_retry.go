@@ -0,0 +1,107 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/repository/db"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// retryFailedAction 操作执行失败时，将错误与最新页面状态反馈给模型换一种方式完成这一步
+//
+// 重试预算：
+//   - 同一步最多重新规划 MaxRetriesPerStep 次，新操作的 RetryCount 为失败操作的 RetryCount + 1
+//   - 单个任务累计最多重新规划 MaxRetriesPerMessage 次
+//
+// 被新操作取代的失败操作标记为已跳过并保留错误信息；预算耗尽、客户端未回传页面状态
+// 或工作流回放（步骤之间相互依赖）时操作记为失败，任务失败
+//
+// failed 须已由 getOwnerAction 校验属于 message
+func (s *BrowserAgentService) retryFailedAction(
+	c context.Context,
+	message *entity.BrowserAgentMessage,
+	failed *entity.BrowserAgentAction,
+	msg *ws.ClientMessage,
+	actionResult *db.ActionResult,
+) (*ws.Action, bool, error) {
+	retry, exhausted, err := s.canRetry(c, message, failed, msg.PageState)
+	if err != nil {
+		return nil, false, err
+	}
+	if !retry {
+		errMsg := msg.Error
+		if exhausted != "" {
+			errMsg = fmt.Sprintf("%s（%s）", msg.Error, exhausted)
+		}
+		if err = s.GormTX.Transaction(c, func(ctx context.Context) error {
+			if err := s.BrowserAgentRepo.UpdateActionStatus(ctx, failed.ID, entity.ActionStatusFailed, actionResult); err != nil {
+				return err
+			}
+			return s.endMessage(ctx, message.ID, entity.MessageStateError, errMsg)
+		}); err != nil {
+			return nil, false, err
+		}
+		return nil, false, errors.New(errMsg)
+	}
+
+	if err = s.BrowserAgentRepo.UpdateActionStatus(c, failed.ID, entity.ActionStatusSkipped, actionResult); err != nil {
+		return nil, false, err
+	}
+
+	retryCount := failed.RetryCount + 1
+	zap.L().Warn("操作执行失败，要求模型重新规划",
+		zap.Int64("messageID", message.ID),
+		zap.Int64("actionID", failed.ID),
+		zap.Int("retry", retryCount),
+	)
+	ws.Emit(c, ws.ServerMessageRetrying, message.ID,
+		fmt.Sprintf("%s 操作执行失败，第 %d 次重新规划", failed.ActionType, retryCount))
+
+	feedback := fmt.Sprintf("上一步 %s 操作执行失败（%s）。请根据当前页面换一种方式完成这一步，"+
+		"例如改用其他 selector、先滚动使元素可见，或等待页面加载完成后再操作。", failed.ActionType, msg.Error)
+
+	action, finished, err := s.planNextAction(c, message.ID, msg.Task, msg.PageState, feedback)
+	if err != nil || finished {
+		return action, finished, err
+	}
+
+	if err = s.BrowserAgentRepo.UpdateActionRetryCount(c, action.ActionID, retryCount); err != nil {
+		return nil, false, err
+	}
+	return action, false, nil
+}
+
+// canRetry 判断失败的操作能否重新规划，重试预算耗尽时 exhausted 为耗尽原因
+func (s *BrowserAgentService) canRetry(
+	c context.Context,
+	message *entity.BrowserAgentMessage,
+	failed *entity.BrowserAgentAction,
+	pageState *ws.PageState,
+) (retry bool, exhausted string, err error) {
+	if message.WorkflowID != 0 || pageState == nil {
+		return false, "", nil
+	}
+
+	if failed.RetryCount >= s.BrowserAgentConfig.MaxRetriesPerStep {
+		return false, fmt.Sprintf("该步骤已重试 %d 次", failed.RetryCount), nil
+	}
+
+	actions, err := s.BrowserAgentRepo.ListActionsByMessageID(c, message.ID)
+	if err != nil {
+		return false, "", err
+	}
+	retries := 0
+	for _, a := range actions {
+		if a.RetryCount > 0 {
+			retries++
+		}
+	}
+	if retries >= s.BrowserAgentConfig.MaxRetriesPerMessage {
+		return false, fmt.Sprintf("任务累计已重试 %d 次", retries), nil
+	}
+	return true, "", nil
+}
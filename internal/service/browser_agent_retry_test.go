@@ -0,0 +1,59 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCanRetry(t *testing.T) {
+	page := &ws.PageState{URL: "https://example.com/"}
+
+	tests := []struct {
+		name          string
+		message       entity.BrowserAgentMessage
+		failed        entity.BrowserAgentAction
+		page          *ws.PageState
+		retryCounts   []int // 任务中已有操作的 RetryCount，nil 表示不查询操作列表
+		wantRetry     bool
+		wantExhausted string
+	}{
+		{name: "工作流回放不重试", message: entity.BrowserAgentMessage{WorkflowID: 7}, page: page},
+		{name: "未回传页面状态不重试", page: nil},
+		{name: "首次失败", page: page, retryCounts: []int{0, 0}, wantRetry: true},
+		{name: "同一步重试次数未用完", failed: entity.BrowserAgentAction{RetryCount: 1}, page: page, retryCounts: []int{0, 1}, wantRetry: true},
+		{name: "同一步重试次数用完", failed: entity.BrowserAgentAction{RetryCount: 2}, page: page, wantExhausted: "该步骤已重试 2 次"},
+		{name: "任务累计重试次数用完", page: page, retryCounts: []int{1, 2, 1, 1, 2, 0}, wantExhausted: "任务累计已重试 5 次"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			s.BrowserAgentConfig.MaxRetriesPerStep = 2
+			s.BrowserAgentConfig.MaxRetriesPerMessage = 5
+
+			tt.message.ID = 1
+			if tt.retryCounts != nil {
+				rows := sqlmock.NewRows([]string{"id", "message_id", "retry_count"})
+				for i, count := range tt.retryCounts {
+					rows.AddRow(i+1, tt.message.ID, count)
+				}
+				mock.ExpectQuery(`SELECT \* FROM "browser_agent_action" WHERE message_id = \$1`).
+					WithArgs(tt.message.ID).
+					WillReturnRows(rows)
+			}
+
+			retry, exhausted, err := s.canRetry(context.Background(), &tt.message, &tt.failed, tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if retry != tt.wantRetry || !strings.Contains(exhausted, tt.wantExhausted) || (tt.wantExhausted == "") != (exhausted == "") {
+				t.Errorf("canRetry() = %v, %q, want %v, %q", retry, exhausted, tt.wantRetry, tt.wantExhausted)
+			}
+		})
+	}
+}
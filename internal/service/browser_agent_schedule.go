@@ -0,0 +1,252 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/internal/model/response"
+	"Art-Design-Backend/pkg/authutils"
+	myerrors "Art-Design-Backend/pkg/errors"
+	"Art-Design-Backend/pkg/utils"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/copier"
+	"github.com/robfig/cron"
+	"go.uber.org/zap"
+)
+
+// runDueSchedules 执行所有到期的定时任务，并清理排队过期的任务
+func (s *BrowserAgentService) runDueSchedules() {
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := s.BrowserAgentRepo.ExpireQueuedMessages(ctx, now.Add(-s.scheduleRunExpiry())); err != nil {
+		zap.L().Error("更新过期排队任务失败", zap.Error(err))
+	}
+
+	schedules, err := s.BrowserAgentRepo.ListDueSchedules(ctx, now)
+	if err != nil {
+		zap.L().Error("查询到期定时任务失败", zap.Error(err))
+		return
+	}
+
+	for _, schedule := range schedules {
+		if err = s.runSchedule(ctx, schedule, now); err != nil {
+			zap.L().Error("执行定时任务失败", zap.Int64("scheduleID", schedule.ID), zap.Error(err))
+		}
+	}
+}
+
+// runSchedule 推进下次执行时间并生成本次执行的任务，客户端在线时立即下发，否则排队等待连接
+func (s *BrowserAgentService) runSchedule(ctx context.Context, schedule *entity.BrowserAgentSchedule, now time.Time) error {
+	sched, err := cron.ParseStandard(schedule.CronExpr)
+	if err != nil {
+		return fmt.Errorf("cron 表达式无效: %w", err)
+	}
+
+	claimed, err := s.BrowserAgentRepo.ClaimScheduleRun(ctx, schedule.ID, *schedule.NextRunAt, sched.Next(now), now)
+	if err != nil || !claimed {
+		return err
+	}
+
+	msg := &entity.BrowserAgentMessage{
+		ConversationID: schedule.ConversationID,
+		Content:        schedule.Task,
+		ExtractSchema:  schedule.ExtractSchema,
+	}
+	if schedule.WorkflowID != 0 {
+		workflow, err := s.BrowserAgentRepo.GetWorkflowByID(ctx, schedule.WorkflowID)
+		if err != nil {
+			return err
+		}
+		msg = newWorkflowMessage(workflow, schedule.ConversationID, schedule.WorkflowParams)
+	}
+	msg.State = entity.MessageStateQueued
+	msg.ScheduleID = schedule.ID
+
+	if err = s.BrowserAgentRepo.CreateMessage(ctx, msg); err != nil {
+		return err
+	}
+	if err = s.BrowserAgentRepo.UpdateScheduleLastMessage(ctx, schedule.ID, msg.ID); err != nil {
+		return err
+	}
+
+	zap.L().Info("定时任务已生成",
+		zap.Int64("scheduleID", schedule.ID),
+		zap.Int64("messageID", msg.ID),
+		zap.Int64("conversationID", schedule.ConversationID),
+	)
+
+	_, err = s.dispatchQueuedMessage(ctx, msg, schedule.Name, nil)
+	return err
+}
+
+// dispatchQueuedMessage 挑选会话所有者的一个空闲在线客户端（可能连接在其他节点上）下发排队的任务，没有空闲客户端时保持排队
+//
+// dispatched 记录本轮已下发任务的连接，这些连接不再参与挑选，可为 nil
+//
+// 先将状态从 queued 改为 running 再下发，避免定时扫描与客户端上线补发重复下发同一任务
+func (s *BrowserAgentService) dispatchQueuedMessage(ctx context.Context, msg *entity.BrowserAgentMessage, name string, dispatched map[string]struct{}) (bool, error) {
+	conv, err := s.BrowserAgentRepo.GetConversationByID(ctx, msg.ConversationID)
+	if err != nil {
+		return false, err
+	}
+
+	client := s.Hub.PickIdleClient(conv.CreateBy, conv.ID, conv.BrowserType, dispatched)
+	if client == nil {
+		return false, nil
+	}
+
+	claimed, err := s.BrowserAgentRepo.StartQueuedMessage(ctx, msg.ID)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	if s.Hub.Send(client, &ws.ServerMessage{
+		Type:           ws.ServerMessageRunTask,
+		MessageID:      msg.ID,
+		ConversationID: msg.ConversationID,
+		Task:           msg.Content,
+		Message:        fmt.Sprintf("定时任务「%s」", name),
+	}) {
+		if dispatched != nil {
+			dispatched[client.ConnID] = struct{}{}
+		}
+		return true, nil
+	}
+
+	_, err = s.BrowserAgentRepo.CompareAndUpdateMessageState(ctx, msg.ID, entity.MessageStateRunning, entity.MessageStateQueued)
+	return false, err
+}
+
+// deliverQueuedRuns 客户端连接或恢复空闲后补发该用户排队且未过期的定时任务
+//
+// 每个空闲客户端本轮只下发一个任务，没有空闲客户端的任务继续排队，等待客户端空闲后再次补发
+func (s *BrowserAgentService) deliverQueuedRuns(_, userID int64) {
+	ctx := context.Background()
+
+	messages, err := s.BrowserAgentRepo.ListQueuedMessagesByUserID(ctx, userID, time.Now().Add(-s.scheduleRunExpiry()))
+	if err != nil {
+		zap.L().Error("查询排队任务失败", zap.Int64("userID", userID), zap.Error(err))
+		return
+	}
+
+	dispatched := make(map[string]struct{})
+	for _, msg := range messages {
+		name := ""
+		if schedule, err := s.BrowserAgentRepo.GetScheduleByID(ctx, msg.ScheduleID); err == nil {
+			name = schedule.Name
+		}
+		sent, err := s.dispatchQueuedMessage(ctx, msg, name, dispatched)
+		if err != nil {
+			zap.L().Error("补发排队任务失败", zap.Int64("messageID", msg.ID), zap.Error(err))
+			return
+		}
+		if !sent {
+			// 该会话暂无匹配的空闲客户端，其他会话的任务可能仍可下发
+			continue
+		}
+		zap.L().Info("已补发排队任务", zap.Int64("messageID", msg.ID), zap.Int64("conversationID", msg.ConversationID))
+	}
+}
+
+func (s *BrowserAgentService) scheduleRunExpiry() time.Duration {
+	return utils.ParseDuration(s.BrowserAgentConfig.ScheduleRunExpiry)
+}
+
+// =========================
+// 定时任务管理
+// =========================
+
+func (s *BrowserAgentService) SaveSchedule(c *gin.Context, req *request.SaveScheduleRequest) (*response.ScheduleResponse, error) {
+	if (req.Task == "") == (req.WorkflowID == 0) {
+		return nil, errors.New("任务描述与工作流必须且只能指定一个")
+	}
+
+	sched, err := cron.ParseStandard(req.CronExpr)
+	if err != nil {
+		return nil, fmt.Errorf("cron 表达式无效: %w", err)
+	}
+
+	if _, err = s.getOwnerConversation(c, authutils.GetUserID(c), int64(req.ConversationID)); err != nil {
+		return nil, err
+	}
+
+	schedule := &entity.BrowserAgentSchedule{}
+	if req.ID != 0 {
+		if schedule, err = s.BrowserAgentRepo.GetScheduleByID(c, int64(req.ID)); err != nil {
+			return nil, err
+		}
+		if schedule.CreateBy != authutils.GetUserID(c) {
+			return nil, myerrors.NewNotFoundError("定时任务不存在")
+		}
+	}
+
+	schedule.Name = req.Name
+	schedule.ConversationID = int64(req.ConversationID)
+	schedule.CronExpr = req.CronExpr
+	schedule.Enabled = req.Enabled
+	schedule.Task, schedule.ExtractSchema = "", nil
+	schedule.WorkflowID, schedule.WorkflowParams = 0, nil
+
+	if req.WorkflowID != 0 {
+		workflow, err := s.getOwnWorkflow(c, int64(req.WorkflowID))
+		if err != nil {
+			return nil, err
+		}
+		if schedule.WorkflowParams, err = s.checkWorkflowParams(c, workflow, req.WorkflowParams); err != nil {
+			return nil, err
+		}
+		schedule.WorkflowID = workflow.ID
+	} else {
+		if len(req.ExtractSchema) > 0 {
+			if err = validateExtractSchema(req.ExtractSchema); err != nil {
+				return nil, err
+			}
+			schedule.ExtractSchema = req.ExtractSchema
+		}
+		secrets, err := s.loadUserSecrets(c, authutils.GetUserID(c))
+		if err != nil {
+			return nil, err
+		}
+		schedule.Task = maskSecrets(req.Task, secrets)
+	}
+
+	nextRunAt := sched.Next(time.Now())
+	schedule.NextRunAt = &nextRunAt
+
+	if schedule.ID == 0 {
+		err = s.BrowserAgentRepo.CreateSchedule(c, schedule)
+	} else {
+		err = s.BrowserAgentRepo.UpdateSchedule(c, schedule)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp response.ScheduleResponse
+	_ = copier.Copy(&resp, schedule)
+	return &resp, nil
+}
+
+func (s *BrowserAgentService) ListSchedules(c *gin.Context) ([]response.ScheduleResponse, error) {
+	schedules, err := s.BrowserAgentRepo.ListSchedulesByUserID(c, authutils.GetUserID(c))
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]response.ScheduleResponse, len(schedules))
+	for i := range schedules {
+		_ = copier.Copy(&responses[i], &schedules[i])
+	}
+
+	return responses, nil
+}
+
+func (s *BrowserAgentService) DeleteSchedule(c *gin.Context, id int64) error {
+	return s.BrowserAgentRepo.DeleteSchedule(c, authutils.GetUserID(c), id)
+}
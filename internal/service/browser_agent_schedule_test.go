@@ -0,0 +1,79 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// newIdleClient 在 hub 上注册一个会话所有者的空闲客户端，等待注册完成后返回
+func newIdleClient(t *testing.T, hub *ws.Hub) *ws.Client {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	client := &ws.Client{Hub: hub, ConversationID: 5, UserID: ownerID, Send: make(chan []byte, 1), Ctx: ctx, Cancel: cancel}
+	hub.Register(client)
+	for range 100 {
+		if len(hub.ClientsByUser(ownerID)) > 0 {
+			return client
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("客户端注册超时")
+	return nil
+}
+
+func TestDispatchQueuedMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		online     bool
+		claimed    bool // 状态是否仍为 queued
+		wantResult bool
+	}{
+		{name: "下发给空闲客户端并记录开始时间", online: true, claimed: true, wantResult: true},
+		{name: "没有空闲客户端时继续排队", online: false},
+		{name: "任务已被其他节点下发", online: true, claimed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			s.Hub = ws.NewHub()
+			go s.Hub.Run()
+
+			var client *ws.Client
+			if tt.online {
+				client = newIdleClient(t, s.Hub)
+			}
+
+			mock.ExpectQuery(`SELECT \* FROM "browser_agent_conversation" WHERE id = \$1`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "created_by"}).AddRow(5, ownerID))
+			if tt.online {
+				rows := int64(0)
+				if tt.claimed {
+					rows = 1
+				}
+				mock.ExpectExec(`UPDATE "browser_agent_message" SET "started_at"=\$1,"state"=\$2 WHERE id = \$3 AND state = \$4`).
+					WithArgs(sqlmock.AnyArg(), entity.MessageStateRunning, 1, entity.MessageStateQueued).
+					WillReturnResult(sqlmock.NewResult(0, rows))
+			}
+
+			msg := &entity.BrowserAgentMessage{ID: 1, ConversationID: 5, State: entity.MessageStateQueued}
+			ok, err := s.dispatchQueuedMessage(context.Background(), msg, "每日签到", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantResult {
+				t.Errorf("dispatchQueuedMessage() = %v, want %v", ok, tt.wantResult)
+			}
+			if client != nil && client.Busy() != tt.wantResult {
+				t.Errorf("client.Busy() = %v, want %v", client.Busy(), tt.wantResult)
+			}
+		})
+	}
+}
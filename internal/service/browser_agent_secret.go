@@ -0,0 +1,235 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/internal/model/response"
+	"Art-Design-Backend/pkg/authutils"
+	"Art-Design-Backend/pkg/utils"
+	"Art-Design-Backend/pkg/ws"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	// secretPlaceholderPattern 匹配 {{secret:name}} 占位符
+	secretPlaceholderPattern = regexp.MustCompile(`\{\{secret:([A-Za-z0-9_.-]+)\}\}`)
+	// secretNamePattern 凭据名称只允许字母、数字、下划线、点和短横线
+	secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// minSecretLength 凭据值的最小长度（字符数）
+//
+// 凭据真实值会在页面状态、执行结果等文本中被全文替换为占位符，过短的值（如 "1"、年份）会误伤正常内容
+const minSecretLength = 6
+
+var errSecretKeyMissing = errors.New("未配置凭据加密密钥，无法使用凭据")
+
+func secretPlaceholder(name string) string {
+	return "{{secret:" + name + "}}"
+}
+
+// loadUserSecrets 读取并解密用户的全部凭据，返回 名称 -> 明文
+func (s *BrowserAgentService) loadUserSecrets(c context.Context, userID int64) (map[string]string, error) {
+	secrets, err := s.BrowserAgentRepo.ListSecretsByUserID(c, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+	if s.BrowserAgentConfig.SecretKey == "" {
+		return nil, errSecretKeyMissing
+	}
+
+	plain := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		value, err := utils.DecryptAESGCM(s.BrowserAgentConfig.SecretKey, secret.Ciphertext)
+		if err != nil {
+			zap.L().Error("凭据解密失败", zap.Int64("userID", userID), zap.String("name", secret.Name), zap.Error(err))
+			return nil, fmt.Errorf("凭据 %s 解密失败", secret.Name)
+		}
+		plain[secret.Name] = value
+	}
+	return plain, nil
+}
+
+// loadMessageSecrets 读取任务所属会话创建者的凭据
+func (s *BrowserAgentService) loadMessageSecrets(c context.Context, messageID int64) (map[string]string, error) {
+	msg, err := s.BrowserAgentRepo.GetMessageByID(c, messageID)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := s.BrowserAgentRepo.GetConversationByID(c, msg.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadUserSecrets(c, conv.CreateBy)
+}
+
+// checkSecretRefs 校验操作引用的凭据均存在，避免下发时才发现无法替换
+func checkSecretRefs(action *ws.Action, secrets map[string]string) error {
+	if action.Value == nil {
+		return nil
+	}
+	for _, match := range secretPlaceholderPattern.FindAllStringSubmatch(*action.Value, -1) {
+		if _, ok := secrets[match[1]]; !ok {
+			return fmt.Errorf("凭据 %s 不存在", match[1])
+		}
+	}
+	return nil
+}
+
+// revealSecrets 返回将 value 中的占位符替换为真实值的操作副本，仅用于下发给客户端
+//
+// 原操作保持占位符不变，数据库、日志中始终只出现占位符；
+// 待确认的操作先以占位符展示给用户，确认后再替换
+func revealSecrets(action *ws.Action, secrets map[string]string) (*ws.Action, error) {
+	if action == nil || action.ConfirmReason != "" || action.Value == nil ||
+		!secretPlaceholderPattern.MatchString(*action.Value) {
+		return action, nil
+	}
+	if err := checkSecretRefs(action, secrets); err != nil {
+		return nil, err
+	}
+
+	value := secretPlaceholderPattern.ReplaceAllStringFunc(*action.Value, func(placeholder string) string {
+		return secrets[secretPlaceholderPattern.FindStringSubmatch(placeholder)[1]]
+	})
+	revealed := *action
+	revealed.Value = &value
+	return &revealed, nil
+}
+
+// maskSecrets 将文本中出现的凭据真实值替换回占位符，较长的值优先替换
+func maskSecrets(text string, secrets map[string]string) string {
+	if text == "" || len(secrets) == 0 {
+		return text
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name, value := range secrets {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Compare(len(secrets[b]), len(secrets[a]))
+	})
+
+	for _, name := range names {
+		text = strings.ReplaceAll(text, secrets[name], secretPlaceholder(name))
+	}
+	return text
+}
+
+func maskSecretPtr(text *string, secrets map[string]string) {
+	if text != nil {
+		*text = maskSecrets(*text, secrets)
+	}
+}
+
+// maskPageState 屏蔽客户端回传页面中的凭据（如输入框回显的密码），避免发送给模型或写入日志
+func maskPageState(pageState *ws.PageState, secrets map[string]string) {
+	if pageState == nil || len(secrets) == 0 {
+		return
+	}
+	pageState.URL = maskSecrets(pageState.URL, secrets)
+	pageState.Title = maskSecrets(pageState.Title, secrets)
+	for i := range pageState.Elements {
+		elem := &pageState.Elements[i]
+		elem.Text = maskSecrets(elem.Text, secrets)
+		maskSecretPtr(elem.Value, secrets)
+		maskSecretPtr(elem.Label, secrets)
+	}
+}
+
+// maskClientMessage 屏蔽执行结果中的凭据
+func maskClientMessage(msg *ws.ClientMessage, secrets map[string]string) {
+	msg.Error = maskSecrets(msg.Error, secrets)
+	msg.Extracted = maskSecrets(msg.Extracted, secrets)
+	maskPageState(msg.PageState, secrets)
+}
+
+func (s *BrowserAgentService) buildSecretSection(secrets map[string]string) string {
+	if len(secrets) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var sb strings.Builder
+	sb.WriteString("【可用凭据】\n")
+	sb.WriteString("需要输入密码、账号等敏感信息时，在 value 中原样填写以下占位符，执行时由系统替换为真实值；" +
+		"不要猜测、编造或向用户索取真实值：\n")
+	for _, name := range names {
+		sb.WriteString(secretPlaceholder(name) + "\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// =========================
+// 凭据管理
+// =========================
+
+func (s *BrowserAgentService) ListSecrets(c *gin.Context) ([]response.SecretResponse, error) {
+	secrets, err := s.BrowserAgentRepo.ListSecretsByUserID(c, authutils.GetUserID(c))
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]response.SecretResponse, len(secrets))
+	for i, secret := range secrets {
+		responses[i] = response.SecretResponse{
+			ID:          secret.ID,
+			Name:        secret.Name,
+			Placeholder: secretPlaceholder(secret.Name),
+			Remark:      secret.Remark,
+			UpdatedAt:   secret.UpdatedAt,
+		}
+	}
+
+	return responses, nil
+}
+
+func (s *BrowserAgentService) SaveSecret(c *gin.Context, req *request.SaveSecretRequest) error {
+	if !secretNamePattern.MatchString(req.Name) {
+		return errors.New("凭据名称只能包含字母、数字、下划线、点和短横线")
+	}
+	if utf8.RuneCountInString(req.Value) < minSecretLength {
+		return fmt.Errorf("凭据值不能少于 %d 个字符", minSecretLength)
+	}
+	if s.BrowserAgentConfig.SecretKey == "" {
+		return errSecretKeyMissing
+	}
+
+	ciphertext, err := utils.EncryptAESGCM(s.BrowserAgentConfig.SecretKey, req.Value)
+	if err != nil {
+		return fmt.Errorf("凭据加密失败: %w", err)
+	}
+
+	return s.BrowserAgentRepo.SaveSecret(c, &entity.BrowserAgentSecret{
+		UserID:     authutils.GetUserID(c),
+		Name:       req.Name,
+		Ciphertext: ciphertext,
+		Remark:     req.Remark,
+	})
+}
+
+func (s *BrowserAgentService) DeleteSecret(c *gin.Context, id int64) error {
+	return s.BrowserAgentRepo.DeleteSecret(c, authutils.GetUserID(c), id)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"Art-Design-Backend/pkg/ws"
+	"testing"
+)
+
+func TestMaskSecrets(t *testing.T) {
+	secrets := map[string]string{
+		"password":      "hunter2-secret",
+		"password_long": "hunter2-secret-extended",
+		"empty":         "",
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		secrets map[string]string
+		want    string
+	}{
+		{name: "空文本", text: "", secrets: secrets, want: ""},
+		{name: "没有凭据", text: "hunter2-secret", secrets: nil, want: "hunter2-secret"},
+		{name: "替换为占位符", text: "密码是 hunter2-secret", secrets: secrets, want: "密码是 {{secret:password}}"},
+		{name: "较长的值优先替换", text: "hunter2-secret-extended", secrets: secrets, want: "{{secret:password_long}}"},
+		{name: "多处出现", text: "hunter2-secret/hunter2-secret", secrets: secrets, want: "{{secret:password}}/{{secret:password}}"},
+		{name: "空值的凭据不参与替换", text: "普通文本", secrets: secrets, want: "普通文本"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maskSecrets(tt.text, tt.secrets); got != tt.want {
+				t.Errorf("maskSecrets(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevealSecrets(t *testing.T) {
+	secrets := map[string]string{"user": "alice", "password": "hunter2-secret"}
+
+	t.Run("不含占位符时原样返回", func(t *testing.T) {
+		for _, action := range []*ws.Action{
+			nil,
+			{Action: "click"},
+			{Action: "input", Value: ptr("plain")},
+			// 待确认的操作先以占位符展示给用户
+			{Action: "input", Value: ptr("{{secret:password}}"), ConfirmReason: "输入密码"},
+		} {
+			got, err := revealSecrets(action, secrets)
+			if err != nil || got != action {
+				t.Errorf("revealSecrets(%+v) = %+v, %v, want 原操作", action, got, err)
+			}
+		}
+	})
+
+	t.Run("替换占位符且不修改原操作", func(t *testing.T) {
+		action := &ws.Action{Action: "input", Value: ptr("{{secret:user}}:{{secret:password}}")}
+		got, err := revealSecrets(action, secrets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got.Value != "alice:hunter2-secret" {
+			t.Errorf("value = %q, want %q", *got.Value, "alice:hunter2-secret")
+		}
+		if *action.Value != "{{secret:user}}:{{secret:password}}" {
+			t.Errorf("原操作被修改为 %q", *action.Value)
+		}
+	})
+
+	t.Run("引用不存在的凭据", func(t *testing.T) {
+		if _, err := revealSecrets(&ws.Action{Action: "input", Value: ptr("{{secret:missing}}")}, secrets); err == nil {
+			t.Error("引用不存在的凭据时应返回错误")
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"Art-Design-Backend/config"
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// successAction 执行成功、执行后页面指纹为 fingerprint 的点击操作
+func successAction(selector, fingerprint string) *entity.BrowserAgentAction {
+	return &entity.BrowserAgentAction{
+		ActionType:      "click",
+		Status:          entity.ActionStatusSuccess,
+		Selector:        ptr(selector),
+		PageFingerprint: ptr(fingerprint),
+	}
+}
+
+func TestCountStalledActions(t *testing.T) {
+	page := &ws.PageState{URL: "https://example.com/", Elements: []ws.PageElement{{Tag: "button", Selector: "#ok"}}}
+	current := page.Fingerprint()
+
+	failed := successAction("#ok", current)
+	failed.Status = entity.ActionStatusFailed
+
+	tests := []struct {
+		name    string
+		actions []*entity.BrowserAgentAction
+		page    *ws.PageState
+		want    int
+	}{
+		{name: "没有页面状态", actions: []*entity.BrowserAgentAction{successAction("#ok", current)}, page: nil, want: 0},
+		{name: "没有操作", page: page, want: 0},
+		{
+			name:    "末尾连续页面不变",
+			actions: []*entity.BrowserAgentAction{successAction("#a", "other"), successAction("#b", current), successAction("#c", current)},
+			page:    page,
+			want:    2,
+		},
+		{
+			name:    "最后一次操作后页面变化",
+			actions: []*entity.BrowserAgentAction{successAction("#a", current), successAction("#b", "other")},
+			page:    page,
+			want:    0,
+		},
+		{
+			name:    "失败的操作中断统计",
+			actions: []*entity.BrowserAgentAction{successAction("#a", current), failed, successAction("#c", current)},
+			page:    page,
+			want:    1,
+		},
+		{
+			name:    "缺少指纹的操作中断统计",
+			actions: []*entity.BrowserAgentAction{successAction("#a", current), {ActionType: "click", Status: entity.ActionStatusSuccess}},
+			page:    page,
+			want:    0,
+		},
+	}
+
+	s := &BrowserAgentService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.countStalledActions(tt.actions, tt.page); got != tt.want {
+				t.Errorf("countStalledActions() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRepeatedAction(t *testing.T) {
+	page := &ws.PageState{URL: "https://example.com/list", Elements: []ws.PageElement{{Tag: "a", Selector: ".next"}}}
+	current := page.Fingerprint()
+	next := &ws.Action{Action: "click", Selector: ptr(".next")}
+
+	tests := []struct {
+		name    string
+		actions []*entity.BrowserAgentAction
+		want    bool
+	}{
+		{name: "没有历史操作", want: false},
+		{
+			name:    "未达到上限",
+			actions: []*entity.BrowserAgentAction{successAction(".next", current)},
+			want:    false,
+		},
+		{
+			name:    "连续相同且页面不变达到上限",
+			actions: []*entity.BrowserAgentAction{successAction(".next", current), successAction(".next", current)},
+			want:    true,
+		},
+		{
+			name:    "页面有变化的重复操作不计入",
+			actions: []*entity.BrowserAgentAction{successAction(".next", "page-1"), successAction(".next", "page-2")},
+			want:    false,
+		},
+		{
+			name:    "中间插入其他操作",
+			actions: []*entity.BrowserAgentAction{successAction(".next", current), successAction(".prev", current), successAction(".next", current)},
+			want:    false,
+		},
+		{
+			name: "参数不同的操作不算重复",
+			actions: []*entity.BrowserAgentAction{
+				{ActionType: "click", Selector: ptr(".next"), Value: ptr("x"), PageFingerprint: ptr(current)},
+				{ActionType: "click", Selector: ptr(".next"), Value: ptr("x"), PageFingerprint: ptr(current)},
+			},
+			want: false,
+		},
+	}
+
+	s := &BrowserAgentService{BrowserAgentConfig: &config.BrowserAgent{RepeatActionLimit: 3}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.isRepeatedAction(tt.actions, page, next); got != tt.want {
+				t.Errorf("isRepeatedAction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleTaskNotRunning(t *testing.T) {
+	for _, state := range []string{
+		entity.MessageStateFinished,
+		entity.MessageStateQueued,
+		entity.MessageStateExpired,
+		entity.MessageStateError,
+		entity.MessageStateCancelled,
+	} {
+		t.Run(state, func(t *testing.T) {
+			s, mock := newMockService(t)
+			expectOwnMessage(mock, 1, state)
+
+			if _, err := s.HandleTask(context.Background(), ownerID, 1, &ws.PageState{URL: "https://example.com/"}); err == nil {
+				t.Errorf("%s 状态的任务不应开始执行", state)
+			}
+		})
+	}
+}
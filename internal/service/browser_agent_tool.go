@@ -0,0 +1,128 @@
+package service
+
+import (
+	"Art-Design-Backend/pkg/ai"
+	"Art-Design-Backend/pkg/ws"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// browserActionTools 浏览器操作的工具定义
+//
+// 工具名即 ws.Action.Action，参数名与 ws.Action 的 JSON 字段保持一致，
+// 新增操作类型时需同步修改 validateAction 与系统提示词
+var browserActionTools = []ai.Tool{
+	newBrowserTool("goto", "跳转到指定 URL",
+		map[string]any{"url": stringParam("目标页面的完整 URL")},
+		"url"),
+	newBrowserTool("click", "点击按钮、链接、单选框或多选框",
+		withElement(map[string]any{})),
+	newBrowserTool("input", "在文本输入框中输入内容",
+		withElement(map[string]any{"value": stringParam("要输入的文本")}),
+		"value"),
+	newBrowserTool("select", "在下拉选择框中选择选项",
+		withElement(map[string]any{"value": stringParam("选项的显示文本")}),
+		"value"),
+	newBrowserTool("scroll", "滚动页面，正数向下、负数向上",
+		map[string]any{"distance": integerParam("滚动距离（像素），建议不超过 clientHeight")},
+		"distance"),
+	newBrowserTool("wait", "等待页面加载或动画完成",
+		map[string]any{"timeout": integerParam("等待时间（毫秒）")},
+		"timeout"),
+	newBrowserTool("hover", "将鼠标悬停在元素上，用于展开菜单或显示提示",
+		withElement(map[string]any{})),
+	newBrowserTool("press_key", "按下键盘按键或组合键",
+		withElement(map[string]any{"key": stringParam("按键名称，如 Enter、Tab、Escape、Control+A")}),
+		"key"),
+	newBrowserTool("open_tab", "在新标签页中打开 URL",
+		map[string]any{"url": stringParam("目标页面的完整 URL")},
+		"url"),
+	newBrowserTool("switch_tab", "切换到指定标签页",
+		map[string]any{"tab_index": integerParam("标签页序号，从 0 开始")},
+		"tab_index"),
+	newBrowserTool("close_tab", "关闭标签页",
+		map[string]any{"tab_index": integerParam("可选，标签页序号，缺省关闭当前标签页")}),
+	newBrowserTool("go_back", "返回上一页",
+		map[string]any{}),
+	newBrowserTool("upload_file", "向文件上传框上传文件",
+		withElement(map[string]any{"file_url": stringParam("待上传文件的地址")}),
+		"file_url"),
+	newBrowserTool("extract", "提取元素中的文本内容并回传给服务端",
+		withElement(map[string]any{})),
+	newBrowserTool("screenshot", "截取当前页面",
+		map[string]any{}),
+	newBrowserTool("finish_task", "任务已完成，结束执行",
+		map[string]any{}),
+}
+
+// submitResultTool 数据采集任务的结果提交工具，data 参数直接使用用户提供的 JSON Schema
+func submitResultTool(schema map[string]any) ai.Tool {
+	return newBrowserTool("submit_result", "提交符合要求的采集结果并结束任务",
+		map[string]any{"data": schema},
+		"data")
+}
+
+// toolsFor 返回本次决策可用的工具，数据采集任务额外提供 submit_result，有计划的任务额外提供计划参数
+func toolsFor(input *decisionInput) []ai.Tool {
+	tools := browserActionTools
+	if len(input.ExtractSchema) > 0 {
+		tools = append(slices.Clone(tools), submitResultTool(input.ExtractSchema))
+	}
+	if len(input.Plan) > 0 {
+		tools = withPlanParams(tools)
+	}
+	return tools
+}
+
+// withElement 为需要定位元素的工具补充 element_index 参数，由服务端解析为 selector；
+// selector 参数仅为兼容保留，提示词中不再提供元素的 selector
+func withElement(properties map[string]any) map[string]any {
+	properties["element_index"] = integerParam("【可交互元素】列表或截图标注中的元素序号（press_key 时可选，先聚焦该元素再按键）")
+	properties["selector"] = stringParam("可选，兼容参数，优先使用 element_index")
+	return properties
+}
+
+// newBrowserTool 构造工具定义，所有工具均可携带 reason 参数说明选择该操作的理由
+func newBrowserTool(name, description string, properties map[string]any, required ...string) ai.Tool {
+	properties["reason"] = stringParam("可选，一句话说明选择该操作的理由")
+	parameters := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		parameters["required"] = required
+	}
+	return ai.Tool{
+		Type: "function",
+		Function: ai.ToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}
+}
+
+func stringParam(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+func integerParam(description string) map[string]any {
+	return map[string]any{"type": "integer", "description": description}
+}
+
+// parseToolCall 将模型的工具调用转换为 ws.Action
+func parseToolCall(call *ai.ToolCall) (*ws.Action, error) {
+	var action ws.Action
+	args := strings.TrimSpace(call.Function.Arguments)
+	if args != "" {
+		if err := sonic.UnmarshalString(args, &action); err != nil {
+			return nil, fmt.Errorf("解析工具调用参数失败: %w", err)
+		}
+	}
+	action.ActionID = 0
+	action.Action = call.Function.Name
+	return &action, nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ai"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/bytedance/sonic"
+)
+
+func TestParseToolCall(t *testing.T) {
+	action, err := parseToolCall(&ai.ToolCall{
+		Function: ai.ToolCallFunction{Name: "input", Arguments: `{"action_id":"42","action":"click","element_index":3,"value":"iPhone 15","reason":"输入关键词"}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 操作类型以工具名为准，模型在参数中填写的 action_id 不生效
+	if action.Action != "input" || action.ActionID != 0 {
+		t.Errorf("action = %s, actionID = %d, want input, 0", action.Action, action.ActionID)
+	}
+	if action.ElementIndex == nil || *action.ElementIndex != 3 || action.Value == nil || *action.Value != "iPhone 15" {
+		t.Errorf("参数解析错误: %+v", action)
+	}
+
+	if action, err = parseToolCall(&ai.ToolCall{Function: ai.ToolCallFunction{Name: "go_back"}}); err != nil || action.Action != "go_back" {
+		t.Errorf("无参数的工具调用解析为 %+v, %v", action, err)
+	}
+
+	if _, err = parseToolCall(&ai.ToolCall{Function: ai.ToolCallFunction{Name: "click", Arguments: `{"element_index":`}}); err == nil {
+		t.Error("参数不是合法 JSON 时应返回错误")
+	}
+}
+
+func TestParseLLMResponse(t *testing.T) {
+	response := func(msg ai.ChatCompletionMessage) []byte {
+		data, _ := sonic.Marshal(ai.ChatCompletionResponse{Choices: []ai.ChatCompletionChoice{{Message: msg}}})
+		return data
+	}
+
+	tests := []struct {
+		name          string
+		resp          []byte
+		wantAction    string
+		wantRationale string
+		wantErr       bool
+	}{
+		{
+			name: "工具调用",
+			resp: response(ai.ChatCompletionMessage{ToolCalls: []ai.ToolCall{{
+				Function: ai.ToolCallFunction{Name: "click", Arguments: `{"element_index":1,"reason":"点击登录"}`},
+			}}}),
+			wantAction:    "click",
+			wantRationale: "点击登录",
+		},
+		{
+			name: "工具调用未给理由时取附带文本",
+			resp: response(ai.ChatCompletionMessage{Content: " 先回到上一页 ", ToolCalls: []ai.ToolCall{{
+				Function: ai.ToolCallFunction{Name: "go_back"},
+			}}}),
+			wantAction:    "go_back",
+			wantRationale: "先回到上一页",
+		},
+		{
+			name:       "没有工具调用时从文本提取 JSON",
+			resp:       response(ai.ChatCompletionMessage{Content: "```json\n{\"action\":\"scroll\",\"distance\":500}\n```"}),
+			wantAction: "scroll",
+		},
+		{name: "内容为空", resp: response(ai.ChatCompletionMessage{}), wantErr: true},
+		{name: "响应不是 JSON", resp: []byte("<html>"), wantErr: true},
+	}
+
+	s := &BrowserAgentService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, err := s.parseLLMResponse(tt.resp, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLLMResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if action.Action != tt.wantAction || action.Rationale != tt.wantRationale {
+				t.Errorf("parseLLMResponse() = %s (%q), want %s (%q)", action.Action, action.Rationale, tt.wantAction, tt.wantRationale)
+			}
+		})
+	}
+}
+
+func TestRequestWithToolFallback(t *testing.T) {
+	tools := []ai.Tool{newBrowserTool("click", "点击元素", map[string]any{})}
+
+	tests := []struct {
+		name string
+		// firstErr 携带工具时第一次请求返回的错误
+		firstErr    error
+		wantSends   []bool
+		wantErr     bool
+		wantCached  bool
+		wantNextUse bool
+	}{
+		{name: "请求成功", wantSends: []bool{true}, wantNextUse: true},
+		{
+			name:        "供应商明确不支持工具时记录模型",
+			firstErr:    &ai.StatusError{StatusCode: http.StatusBadRequest, Body: `{"error":"Tools are not supported for this model"}`},
+			wantSends:   []bool{true, false},
+			wantCached:  true,
+			wantNextUse: false,
+		},
+		{
+			name:        "与工具无关的 400 只在本次降级",
+			firstErr:    &ai.StatusError{StatusCode: http.StatusBadRequest, Body: `{"error":"context length exceeded"}`},
+			wantSends:   []bool{true, false},
+			wantNextUse: true,
+		},
+		{
+			name:        "422 同样降级",
+			firstErr:    &ai.StatusError{StatusCode: http.StatusUnprocessableEntity, Body: `function_call is invalid`},
+			wantSends:   []bool{true, false},
+			wantCached:  true,
+			wantNextUse: false,
+		},
+		{
+			name:        "服务端错误不降级",
+			firstErr:    &ai.StatusError{StatusCode: http.StatusInternalServerError, Body: `tool error`},
+			wantSends:   []bool{true},
+			wantErr:     true,
+			wantNextUse: true,
+		},
+		{
+			name:        "网络错误不降级",
+			firstErr:    errors.New("connection reset"),
+			wantSends:   []bool{true},
+			wantErr:     true,
+			wantNextUse: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &BrowserAgentService{}
+			model := &entity.AIModel{Model: "mock-model"}
+			model.ID = 1
+
+			var sends []bool
+			send := func(tools []ai.Tool) ([]byte, error) {
+				sends = append(sends, len(tools) > 0)
+				if len(sends) == 1 && tt.firstErr != nil {
+					return nil, tt.firstErr
+				}
+				return []byte("{}"), nil
+			}
+
+			_, err := s.requestWithToolFallback(model, tools, send)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("requestWithToolFallback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(sends, tt.wantSends) {
+				t.Errorf("各次请求是否携带工具 = %v, want %v", sends, tt.wantSends)
+			}
+			if _, cached := s.toolCallUnsupported.Load(model.ID); cached != tt.wantCached {
+				t.Errorf("cached = %v, want %v", cached, tt.wantCached)
+			}
+
+			// 下一次请求是否仍携带工具
+			sends = sends[:0]
+			_, _ = s.requestWithToolFallback(model, tools, func(tools []ai.Tool) ([]byte, error) {
+				sends = append(sends, len(tools) > 0)
+				return []byte("{}"), nil
+			})
+			if sends[0] != tt.wantNextUse {
+				t.Errorf("下一次请求携带工具 = %v, want %v", sends[0], tt.wantNextUse)
+			}
+		})
+	}
+}
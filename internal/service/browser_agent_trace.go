@@ -0,0 +1,281 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/internal/model/response"
+	"Art-Design-Backend/pkg/authutils"
+	"Art-Design-Backend/pkg/constant/prompt"
+	"Art-Design-Backend/pkg/ws"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/copier"
+	"go.uber.org/zap"
+)
+
+// traceTruncatedSuffix 轨迹字段超出长度上限时追加的标记
+const traceTruncatedSuffix = "...[已截断]"
+
+// newLLMCall 开始记录一次模型调用
+func newLLMCall(model *entity.AIModel, promptText string, vision bool) *entity.LLMCall {
+	return &entity.LLMCall{
+		Model:     model.Model,
+		Vision:    vision,
+		Prompt:    promptText,
+		StartedAt: time.Now(),
+	}
+}
+
+// finishLLMCall 记录模型响应、耗时与 token 用量
+func finishLLMCall(call *entity.LLMCall, model *entity.AIModel, respJSON []byte, err error) {
+	call.DurationMs = int(time.Since(call.StartedAt).Milliseconds())
+	call.Response = string(respJSON)
+	recordUsage(call, model, respJSON)
+	failLLMCall(call, err)
+}
+
+// failLLMCall 记录调用、解析或校验失败的原因，err 为 nil 时不做处理
+func failLLMCall(call *entity.LLMCall, err error) {
+	if call != nil && err != nil {
+		call.Error = err.Error()
+	}
+}
+
+// recordLLMCall 追加本次决策的模型调用记录，并将费用计入任务
+func (s *BrowserAgentService) recordLLMCall(c context.Context, input *decisionInput, call *entity.LLMCall) {
+	if call == nil {
+		return
+	}
+	input.LLMCalls = append(input.LLMCalls, call)
+	s.chargeMessage(c, input.MessageID, call)
+}
+
+// saveActionTrace 保存操作的决策轨迹：决策时的页面状态与此前累计的模型调用记录
+//
+// 轨迹仅用于排查问题，保存失败不影响任务执行
+func (s *BrowserAgentService) saveActionTrace(c context.Context, input *decisionInput, actionID int64) {
+	calls := input.LLMCalls
+	input.LLMCalls = nil
+	if s.BrowserAgentConfig.DisableTrace {
+		return
+	}
+
+	limit := s.BrowserAgentConfig.TraceMaxFieldBytes
+	trace := &entity.BrowserAgentActionTrace{
+		MessageID: input.MessageID,
+		ActionID:  actionID,
+		PageState: truncateBytes(snapshotPageState(input.PageState), limit),
+		LLMCalls:  make([]entity.LLMCall, len(calls)),
+	}
+	// 上传失败时截图以 data URL 传给模型，内容过大不保存
+	if input.ImageURL != "" && !strings.HasPrefix(input.ImageURL, "data:") {
+		trace.ImageURL = &input.ImageURL
+	}
+	for i, call := range calls {
+		trace.LLMCalls[i] = *call
+		trace.LLMCalls[i].Prompt = truncateBytes(call.Prompt, limit)
+		trace.LLMCalls[i].Response = truncateBytes(call.Response, limit)
+	}
+
+	if err := s.BrowserAgentRepo.CreateActionTrace(c, trace); err != nil {
+		zap.L().Warn("保存操作决策轨迹失败", zap.Int64("actionID", actionID), zap.Error(err))
+	}
+}
+
+// snapshotPageState 序列化页面状态，截图已单独上传，不重复保存
+func snapshotPageState(pageState *ws.PageState) string {
+	if pageState == nil {
+		return ""
+	}
+	snapshot := *pageState
+	snapshot.Screenshot = ""
+	data, err := sonic.MarshalString(&snapshot)
+	if err != nil {
+		zap.L().Warn("序列化页面状态失败", zap.Error(err))
+		return ""
+	}
+	return data
+}
+
+// truncateBytes 按字节数截断字符串，不截断多字节字符
+func truncateBytes(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + traceTruncatedSuffix
+}
+
+// cleanupActionTraces 删除超过保留天数的决策轨迹
+func (s *BrowserAgentService) cleanupActionTraces() {
+	cutoff := time.Now().AddDate(0, 0, -s.BrowserAgentConfig.TraceRetentionDays)
+	deleted, err := s.BrowserAgentRepo.DeleteActionTracesBefore(context.Background(), cutoff)
+	if err != nil {
+		zap.L().Error("清理过期决策轨迹失败", zap.Error(err))
+		return
+	}
+	zap.L().Info("已清理过期决策轨迹", zap.Int64("deleted", deleted), zap.Time("cutoff", cutoff))
+}
+
+// =========================
+// 轨迹导出
+// =========================
+
+// GetMessageTrace 组装任务的完整执行轨迹，按操作顺序关联决策轨迹
+func (s *BrowserAgentService) GetMessageTrace(c *gin.Context, messageID int64) (*response.MessageTraceResponse, error) {
+	msg, _, err := s.getOwnMessage(c, authutils.GetUserID(c), messageID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := s.BrowserAgentRepo.ListActionsByMessageID(c, messageID)
+	if err != nil {
+		return nil, err
+	}
+	traces, err := s.BrowserAgentRepo.ListActionTracesByMessageID(c, messageID)
+	if err != nil {
+		return nil, err
+	}
+	traceByAction := make(map[int64]*entity.BrowserAgentActionTrace, len(traces))
+	for _, trace := range traces {
+		traceByAction[trace.ActionID] = trace
+	}
+
+	resp := &response.MessageTraceResponse{
+		SystemPrompt: prompt.BrowserSystemPrompt,
+		Steps:        make([]response.TraceStepResponse, len(actions)),
+		ExportedAt:   time.Now(),
+	}
+	_ = copier.Copy(&resp.Message, msg)
+
+	for i, action := range actions {
+		step := &resp.Steps[i]
+		step.Index = i + 1
+		_ = copier.Copy(&step.Action, action)
+
+		trace := traceByAction[action.ID]
+		if trace == nil {
+			continue
+		}
+		step.PageState = rawJSONOrString(trace.PageState)
+		step.ImageURL = trace.ImageURL
+		step.LLMCalls = make([]response.LLMCallResponse, len(trace.LLMCalls))
+		for j := range trace.LLMCalls {
+			_ = copier.Copy(&step.LLMCalls[j], &trace.LLMCalls[j])
+			step.DecisionTime += trace.LLMCalls[j].DurationMs
+		}
+	}
+	return resp, nil
+}
+
+// rawJSONOrString 合法的 JSON 原样嵌入导出内容，被截断的 JSON 以字符串保留
+func rawJSONOrString(data string) any {
+	if data == "" {
+		return nil
+	}
+	if json.Valid([]byte(data)) {
+		return json.RawMessage(data)
+	}
+	return data
+}
+
+// ExportMessageTrace 导出任务的完整执行轨迹，format 支持 json（默认）与 zip
+func (s *BrowserAgentService) ExportMessageTrace(c *gin.Context, req *request.ExportMessageTraceRequest) (*ExportFile, error) {
+	trace, err := s.GetMessageTrace(c, req.MessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.MarshalIndent(trace, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化执行轨迹失败: %w", err)
+	}
+
+	if req.Format != "zip" {
+		return &ExportFile{
+			FileName:    fmt.Sprintf("message_trace_%d.json", req.MessageID),
+			ContentType: "application/json; charset=utf-8",
+			Content:     content,
+		}, nil
+	}
+
+	archive, err := traceToZip(trace, content)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportFile{
+		FileName:    fmt.Sprintf("message_trace_%d.zip", req.MessageID),
+		ContentType: "application/zip",
+		Content:     archive,
+	}, nil
+}
+
+// traceToZip 将执行轨迹打包为压缩包：trace.json 为完整内容，每步操作的页面状态、提示词与模型响应拆分为单独文件便于查看
+//
+//	trace.json
+//	system_prompt.txt
+//	steps/001_click/action.json
+//	steps/001_click/page_state.json
+//	steps/001_click/llm_1_prompt.txt
+//	steps/001_click/llm_1_response.json
+func traceToZip(trace *response.MessageTraceResponse, content []byte) ([]byte, error) {
+	type zipEntry struct {
+		name string
+		data []byte
+	}
+	entries := []zipEntry{
+		{"trace.json", content},
+		{"system_prompt.txt", []byte(trace.SystemPrompt)},
+	}
+	for _, step := range trace.Steps {
+		dir := fmt.Sprintf("steps/%03d_%s/", step.Index, step.Action.ActionType)
+
+		action, err := json.MarshalIndent(step.Action, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("序列化操作失败: %w", err)
+		}
+		entries = append(entries, zipEntry{dir + "action.json", action})
+
+		if step.PageState != nil {
+			pageState, err := json.MarshalIndent(step.PageState, "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("序列化页面状态失败: %w", err)
+			}
+			entries = append(entries, zipEntry{dir + "page_state.json", pageState})
+		}
+		for i, call := range step.LLMCalls {
+			prefix := fmt.Sprintf("%sllm_%d_", dir, i+1)
+			entries = append(entries,
+				zipEntry{prefix + "prompt.txt", []byte(call.Prompt)},
+				zipEntry{prefix + "response.json", []byte(call.Response)},
+			)
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			return nil, fmt.Errorf("写入压缩包失败: %w", err)
+		}
+		if _, err = w.Write(entry.data); err != nil {
+			return nil, fmt.Errorf("写入压缩包失败: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("写入压缩包失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
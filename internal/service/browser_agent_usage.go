@@ -0,0 +1,59 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ai"
+	"context"
+
+	"github.com/bytedance/sonic"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// tokensPerPriceUnit 模型单价按每百万 token 计
+var tokensPerPriceUnit = decimal.NewFromInt(1_000_000)
+
+// recordUsage 从模型响应中读取 token 用量并按模型单价计算费用，供应商未返回用量时记为 0
+func recordUsage(call *entity.LLMCall, model *entity.AIModel, respJSON []byte) {
+	if len(respJSON) == 0 {
+		return
+	}
+	var resp struct {
+		Usage *ai.ChatCompletionUsage `json:"usage"`
+	}
+	if err := sonic.Unmarshal(respJSON, &resp); err != nil || resp.Usage == nil {
+		return
+	}
+	call.PromptTokens = resp.Usage.PromptTokens
+	call.CompletionTokens = resp.Usage.CompletionTokens
+	call.Cost = llmCost(model, call.PromptTokens, call.CompletionTokens)
+}
+
+// llmCost 按模型的输入、输出单价（每百万 token）计算费用
+func llmCost(model *entity.AIModel, promptTokens, completionTokens int) decimal.Decimal {
+	prompt := model.PricePromptPer1M.Mul(decimal.NewFromInt(int64(promptTokens)))
+	completion := model.PriceCompletionPer1M.Mul(decimal.NewFromInt(int64(completionTokens)))
+	return prompt.Add(completion).Div(tokensPerPriceUnit)
+}
+
+// sumUsage 汇总多次模型调用的 token 用量与费用
+func sumUsage(calls []*entity.LLMCall) (promptTokens, completionTokens int, cost decimal.Decimal) {
+	for _, call := range calls {
+		promptTokens += call.PromptTokens
+		completionTokens += call.CompletionTokens
+		cost = cost.Add(call.Cost)
+	}
+	return
+}
+
+// chargeMessage 将一次模型调用的用量累加到任务，包括未生成操作的调用（结束任务、解析失败等）
+//
+// 离线评估没有任务记录（messageID 为 0），不做处理；记账失败不影响任务执行
+func (s *BrowserAgentService) chargeMessage(c context.Context, messageID int64, call *entity.LLMCall) {
+	if messageID == 0 || (call.PromptTokens == 0 && call.CompletionTokens == 0) {
+		return
+	}
+	if err := s.BrowserAgentRepo.AddMessageUsage(c, messageID, call.PromptTokens, call.CompletionTokens, call.Cost); err != nil {
+		zap.L().Warn("累计任务 token 用量失败", zap.Int64("messageID", messageID), zap.Error(err))
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/pkg/ai"
+	"Art-Design-Backend/pkg/constant/llmid"
+	"Art-Design-Backend/pkg/ws"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// prepareScreenshot 为截图标注元素序号并上传 OSS，返回多模态模型可访问的图片地址
+//
+// 没有截图或截图无法解析时返回空字符串，本步退回纯文本决策；
+// 上传失败时以 data URL 形式直接传给模型
+func (s *BrowserAgentService) prepareScreenshot(c context.Context, pageState *ws.PageState) string {
+	if pageState == nil || pageState.Screenshot == "" {
+		return ""
+	}
+
+	img, err := decodeScreenshot(pageState.Screenshot)
+	if err != nil {
+		zap.L().Warn("页面截图解码失败，本步不使用截图", zap.Error(err))
+		return ""
+	}
+
+	annotated, err := ws.AnnotateScreenshot(img, pageState.Elements, pageState.DevicePixelRatio)
+	if err != nil {
+		zap.L().Debug("截图未标注元素序号", zap.Error(err))
+	} else {
+		img = annotated
+	}
+
+	imageURL, err := s.OssClient.UploadBrowserAgentScreenshot(c, "vision.png", bytes.NewReader(img))
+	if err != nil {
+		zap.L().Warn("上传页面截图失败，使用 data URL", zap.Error(err))
+		return "data:" + http.DetectContentType(img) + ";base64," + base64.StdEncoding.EncodeToString(img)
+	}
+	return imageURL
+}
+
+// callVisionLLM 调用多模态模型，结合标注截图决策下一步操作
+func (s *BrowserAgentService) callVisionLLM(
+	c context.Context,
+	systemPrompt,
+	promptText,
+	imageURL string,
+	tools []ai.Tool,
+	onThinking func(delta string),
+) (*ws.Action, *entity.LLMCall, error) {
+
+	multiModel, err := s.AIModelRepo.GetAIModelByIDWithCache(c, llmid.MultiModelID)
+	if err != nil {
+		zap.L().Error("获取多模态模型失败", zap.Error(err))
+		return nil, nil, fmt.Errorf("获取多模态模型失败: %w", err)
+	}
+
+	provider, err := s.AIProviderRepo.GetAIProviderByIDWithCache(c, multiModel.ProviderID)
+	if err != nil {
+		zap.L().Error("获取多模态模型供应商失败", zap.Error(err))
+		return nil, nil, fmt.Errorf("获取多模态模型供应商失败: %w", err)
+	}
+
+	chatReq := ai.DefaultMultiModeChatRequest(
+		multiModel.Model,
+		[]ai.MultiModeChatMessage{
+			{
+				Role:    "system",
+				Content: []ai.MultiModeChatContent{{Type: "text", Text: systemPrompt}},
+			},
+			{
+				Role: "user",
+				Content: []ai.MultiModeChatContent{
+					{Type: "image_url", ImageURL: imageURL},
+					{Type: "text", Text: promptText},
+				},
+			},
+		},
+	)
+
+	call := newLLMCall(multiModel, promptText, true)
+	respJSON, err := s.requestWithToolFallback(multiModel, tools, func(tools []ai.Tool) ([]byte, error) {
+		chatReq.Tools, chatReq.ToolChoice = nil, ""
+		if len(tools) > 0 {
+			chatReq.Tools, chatReq.ToolChoice = tools, "auto"
+		}
+		if onThinking != nil {
+			return s.AIModelClient.MultiModeChatStreamRequest(c, provider.BaseURL+multiModel.APIPath, provider.APIKey, chatReq, onThinking)
+		}
+		return s.AIModelClient.MultiModeChatRequest(c, provider.BaseURL+multiModel.APIPath, provider.APIKey, chatReq)
+	})
+	finishLLMCall(call, multiModel, respJSON, err)
+	if err != nil {
+		return nil, call, fmt.Errorf("调用多模态模型失败: %w", err)
+	}
+
+	action, err := s.parseLLMResponse(respJSON, promptText)
+	failLLMCall(call, err)
+	return action, call, err
+}
+
+// resolveElementIndex 将模型给出的元素序号解析为对应元素的 selector，同时给出 selector 时以序号为准
+func resolveElementIndex(action *ws.Action, pageState *ws.PageState) error {
+	if action.ElementIndex == nil {
+		return nil
+	}
+
+	idx := *action.ElementIndex
+	if pageState == nil || idx < 1 || idx > len(pageState.Elements) {
+		return fmt.Errorf("element_index 超出范围: %d", idx)
+	}
+
+	selector := pageState.Elements[idx-1].Selector
+	action.Selector = &selector
+	return nil
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"Art-Design-Backend/internal/model/request"
+	"Art-Design-Backend/internal/model/response"
+	"Art-Design-Backend/pkg/authutils"
+	myerrors "Art-Design-Backend/pkg/errors"
+	"Art-Design-Backend/pkg/ws"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/copier"
+	"go.uber.org/zap"
+)
+
+// paramPlaceholderPattern 匹配工作流步骤中的 {{param:name}} 参数占位符
+var paramPlaceholderPattern = regexp.MustCompile(`\{\{param:([A-Za-z0-9_.-]+)\}\}`)
+
+func paramPlaceholder(name string) string {
+	return "{{param:" + name + "}}"
+}
+
+// recordWorkflowSteps 将任务中执行成功的操作转为工作流步骤，输入/选择的值提取为参数
+//
+// 只引用凭据占位符的值保持原样，回放时照常替换为凭据
+func recordWorkflowSteps(actions []*entity.BrowserAgentAction) ([]entity.WorkflowStep, []entity.WorkflowParam) {
+	var (
+		steps  []entity.WorkflowStep
+		params []entity.WorkflowParam
+	)
+	for _, a := range actions {
+		if a.Status != entity.ActionStatusSuccess || a.ActionType == "finish_task" || a.ActionType == "submit_result" {
+			continue
+		}
+
+		step := entity.WorkflowStep{
+			Action:   a.ActionType,
+			URL:      a.URL,
+			Selector: a.Selector,
+			Value:    a.Value,
+			Distance: a.Distance,
+			Timeout:  a.Timeout,
+			Key:      a.Key,
+			TabIndex: a.TabIndex,
+			FileURL:  a.FileURL,
+		}
+
+		if (a.ActionType == "input" || a.ActionType == "select") && a.Value != nil &&
+			strings.TrimSpace(secretPlaceholderPattern.ReplaceAllString(*a.Value, "")) != "" {
+			name := fmt.Sprintf("%s_%d", a.ActionType, len(params)+1)
+			description := fmt.Sprintf("第 %d 步 %s 的值", len(steps)+1, a.ActionType)
+			if a.Selector != nil {
+				description += "（" + *a.Selector + "）"
+			}
+			params = append(params, entity.WorkflowParam{Name: name, Description: description, Default: *a.Value})
+			placeholder := paramPlaceholder(name)
+			step.Value = &placeholder
+		}
+
+		steps = append(steps, step)
+	}
+	return steps, params
+}
+
+// renderWorkflowStep 用参数值替换步骤中的参数占位符，生成下发的操作
+func renderWorkflowStep(step entity.WorkflowStep, values map[string]string) *ws.Action {
+	render := func(text *string) *string {
+		if text == nil {
+			return nil
+		}
+		rendered := paramPlaceholderPattern.ReplaceAllStringFunc(*text, func(placeholder string) string {
+			name := paramPlaceholderPattern.FindStringSubmatch(placeholder)[1]
+			if value, ok := values[name]; ok {
+				return value
+			}
+			return placeholder
+		})
+		return &rendered
+	}
+
+	return &ws.Action{
+		Action:   step.Action,
+		URL:      render(step.URL),
+		Selector: step.Selector,
+		Value:    render(step.Value),
+		Distance: step.Distance,
+		Timeout:  step.Timeout,
+		Key:      step.Key,
+		TabIndex: step.TabIndex,
+		FileURL:  step.FileURL,
+	}
+}
+
+// workflowParamValues 合并参数默认值与本次回放传入的参数
+func workflowParamValues(workflow *entity.BrowserAgentWorkflow, params map[string]string) map[string]string {
+	values := make(map[string]string, len(workflow.Params))
+	for _, p := range workflow.Params {
+		values[p.Name] = p.Default
+	}
+	for name, value := range params {
+		values[name] = value
+	}
+	return values
+}
+
+// nextWorkflowStep 回放工作流的下一步，不调用模型；
+// 步骤的 selector 在当前页面中已找不到时，由模型结合原步骤重新规划这一步
+func (s *BrowserAgentService) nextWorkflowStep(
+	c context.Context,
+	message *entity.BrowserAgentMessage,
+	input *decisionInput,
+) (*ws.Action, bool, error) {
+	workflow, err := s.BrowserAgentRepo.GetWorkflowByID(c, message.WorkflowID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	idx := message.WorkflowStep
+	if idx >= len(workflow.Steps) {
+		zap.L().Info("工作流回放完成", zap.Int64("messageID", message.ID), zap.Int64("workflowID", workflow.ID))
+		return nil, true, s.finishMessage(c, message.ID, nil)
+	}
+
+	action := renderWorkflowStep(workflow.Steps[idx], workflowParamValues(workflow, message.WorkflowParams))
+	stage := fmt.Sprintf("工作流回放 %d/%d", idx+1, len(workflow.Steps))
+
+	if action.Selector != nil && findElementBySelector(input.PageState, action.Selector) == nil {
+		zap.L().Warn("工作流步骤元素已失效，交由模型重新规划",
+			zap.Int64("messageID", message.ID),
+			zap.Int("step", idx+1),
+			zap.String("selector", *action.Selector),
+		)
+		input.Task = workflow.Task
+		input.WorkflowHint = buildWorkflowHint(idx, len(workflow.Steps), action)
+		ws.Emit(c, ws.ServerMessageRetrying, message.ID, fmt.Sprintf("工作流第 %d 步元素已失效，重新规划", idx+1))
+		action, _, err = s.decideWithPolicy(c, input, func(c context.Context, input *decisionInput) (*ws.Action, bool, error) {
+			action, err := s.decideAction(c, input)
+			return action, false, err
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		// 模型判断任务已完成时按普通任务结束，submit_result 同时保存采集结果
+		if action.Action == "finish_task" || action.Action == "submit_result" {
+			return nil, true, s.finishMessage(c, message.ID, action)
+		}
+		stage += "（重新规划）"
+	} else if err = s.validateAction(action, input.URLPolicies); err != nil {
+		var violation *urlPolicyViolation
+		if errors.As(err, &violation) {
+			if _, recordErr := s.recordPolicyViolation(c, message.ID, violation); recordErr != nil {
+				return nil, false, recordErr
+			}
+		}
+		if updateErr := s.endMessage(c, message.ID, entity.MessageStateError, err.Error()); updateErr != nil {
+			return nil, false, updateErr
+		}
+		return nil, false, err
+	}
+
+	if err = s.createAction(c, input, action, stage); err != nil {
+		return nil, false, err
+	}
+	if err = s.BrowserAgentRepo.UpdateMessageWorkflowStep(c, message.ID, idx+1); err != nil {
+		return nil, false, err
+	}
+
+	revealed, err := revealSecrets(action, input.Secrets)
+	return revealed, false, err
+}
+
+func buildWorkflowHint(idx, total int, action *ws.Action) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("正在回放录制的工作流，第 %d/%d 步原定执行 %s 操作", idx+1, total, action.Action))
+	if action.Selector != nil {
+		sb.WriteString(" selector=\"" + *action.Selector + "\"")
+	}
+	if action.Value != nil {
+		sb.WriteString(" value=\"" + *action.Value + "\"")
+	}
+	sb.WriteString("，但当前页面中找不到该元素，页面结构可能已经变化。")
+	return sb.String()
+}
+
+func (s *BrowserAgentService) buildWorkflowSection(hint string) string {
+	if hint == "" {
+		return ""
+	}
+
+	return "【工作流回放】\n" +
+		hint + "\n" +
+		"请根据当前页面完成与该步骤等效的一步操作，后续步骤仍按工作流执行，不要提前结束任务。\n\n"
+}
+
+// =========================
+// 工作流管理
+// =========================
+
+func (s *BrowserAgentService) CreateWorkflow(c *gin.Context, req *request.CreateWorkflowRequest) (*response.WorkflowResponse, error) {
+	msg, _, err := s.getOwnMessage(c, authutils.GetUserID(c), int64(req.MessageID))
+	if err != nil {
+		return nil, err
+	}
+	if msg.State != entity.MessageStateFinished {
+		return nil, errors.New("只能将已完成的任务保存为工作流")
+	}
+
+	actions, err := s.BrowserAgentRepo.ListActionsByMessageID(c, msg.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, params := recordWorkflowSteps(actions)
+	if len(steps) == 0 {
+		return nil, errors.New("任务中没有可录制的成功操作")
+	}
+
+	task := msg.Content
+	if msg.WorkflowID != 0 {
+		source, err := s.BrowserAgentRepo.GetWorkflowByID(c, msg.WorkflowID)
+		if err == nil {
+			task = source.Task
+		}
+	}
+
+	workflow := &entity.BrowserAgentWorkflow{
+		Name:            req.Name,
+		Description:     req.Description,
+		Task:            task,
+		SourceMessageID: msg.ID,
+		Steps:           steps,
+		Params:          params,
+	}
+	if err = s.BrowserAgentRepo.CreateWorkflow(c, workflow); err != nil {
+		return nil, err
+	}
+
+	var resp response.WorkflowResponse
+	_ = copier.Copy(&resp, workflow)
+	return &resp, nil
+}
+
+func (s *BrowserAgentService) ListWorkflows(c *gin.Context) ([]response.WorkflowResponse, error) {
+	workflows, err := s.BrowserAgentRepo.ListWorkflowsByUserID(c, authutils.GetUserID(c))
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]response.WorkflowResponse, len(workflows))
+	for i := range workflows {
+		_ = copier.Copy(&responses[i], &workflows[i])
+	}
+
+	return responses, nil
+}
+
+func (s *BrowserAgentService) DeleteWorkflow(c *gin.Context, id int64) error {
+	return s.BrowserAgentRepo.DeleteWorkflow(c, authutils.GetUserID(c), id)
+}
+
+// getOwnWorkflow 获取当前用户创建的工作流，属于其他用户时与不存在一样返回 404
+func (s *BrowserAgentService) getOwnWorkflow(c *gin.Context, id int64) (*entity.BrowserAgentWorkflow, error) {
+	workflow, err := s.BrowserAgentRepo.GetWorkflowByID(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if workflow.CreateBy != authutils.GetUserID(c) {
+		return nil, myerrors.NewNotFoundError("工作流不存在")
+	}
+	return workflow, nil
+}
+
+// checkWorkflowParams 校验回放参数均为工作流定义的参数，参数中出现的凭据真实值以占位符保存
+func (s *BrowserAgentService) checkWorkflowParams(c *gin.Context, workflow *entity.BrowserAgentWorkflow, params map[string]string) (map[string]string, error) {
+	defined := make(map[string]bool, len(workflow.Params))
+	for _, p := range workflow.Params {
+		defined[p.Name] = true
+	}
+	secrets, err := s.loadUserSecrets(c, authutils.GetUserID(c))
+	if err != nil {
+		return nil, err
+	}
+
+	checked := make(map[string]string, len(params))
+	for name, value := range params {
+		if !defined[name] {
+			return nil, fmt.Errorf("工作流没有参数 %s", name)
+		}
+		checked[name] = maskSecrets(value, secrets)
+	}
+	return checked, nil
+}
+
+func newWorkflowMessage(workflow *entity.BrowserAgentWorkflow, conversationID int64, params map[string]string) *entity.BrowserAgentMessage {
+	return &entity.BrowserAgentMessage{
+		ConversationID: conversationID,
+		Content:        fmt.Sprintf("执行工作流「%s」", workflow.Name),
+		WorkflowID:     workflow.ID,
+		WorkflowParams: params,
+	}
+}
+
+// RunWorkflow 创建一条工作流回放任务，客户端随后按普通任务通过 WebSocket 发起执行
+func (s *BrowserAgentService) RunWorkflow(c *gin.Context, req *request.RunWorkflowRequest) (*response.MessageResponse, error) {
+	workflow, err := s.getOwnWorkflow(c, int64(req.WorkflowID))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = s.getOwnerConversation(c, authutils.GetUserID(c), int64(req.ConversationID)); err != nil {
+		return nil, err
+	}
+
+	params, err := s.checkWorkflowParams(c, workflow, req.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := newWorkflowMessage(workflow, int64(req.ConversationID), params)
+	if err = s.BrowserAgentRepo.CreateMessage(c, msg); err != nil {
+		return nil, err
+	}
+
+	var msgResp response.MessageResponse
+	_ = copier.Copy(&msgResp, msg)
+	return &msgResp, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"Art-Design-Backend/internal/model/entity"
+	"reflect"
+	"testing"
+)
+
+func TestRecordWorkflowSteps(t *testing.T) {
+	action := func(actionType, status string, selector, value *string) *entity.BrowserAgentAction {
+		return &entity.BrowserAgentAction{ActionType: actionType, Status: status, Selector: selector, Value: value}
+	}
+
+	tests := []struct {
+		name       string
+		actions    []*entity.BrowserAgentAction
+		wantSteps  []entity.WorkflowStep
+		wantParams []entity.WorkflowParam
+	}{
+		{name: "没有操作"},
+		{
+			name: "跳过未成功与结束操作",
+			actions: []*entity.BrowserAgentAction{
+				action("click", entity.ActionStatusFailed, ptr("#a"), nil),
+				action("click", entity.ActionStatusSkipped, ptr("#b"), nil),
+				action("click", entity.ActionStatusSuccess, ptr("#c"), nil),
+				action("finish_task", entity.ActionStatusSuccess, nil, nil),
+				action("submit_result", entity.ActionStatusSuccess, nil, nil),
+			},
+			wantSteps: []entity.WorkflowStep{{Action: "click", Selector: ptr("#c")}},
+		},
+		{
+			name: "输入值提取为参数",
+			actions: []*entity.BrowserAgentAction{
+				{ActionType: "goto", Status: entity.ActionStatusSuccess, URL: ptr("https://example.com/")},
+				action("input", entity.ActionStatusSuccess, ptr("#q"), ptr("iPhone 15")),
+				action("select", entity.ActionStatusSuccess, nil, ptr("128GB")),
+			},
+			wantSteps: []entity.WorkflowStep{
+				{Action: "goto", URL: ptr("https://example.com/")},
+				{Action: "input", Selector: ptr("#q"), Value: ptr("{{param:input_1}}")},
+				{Action: "select", Value: ptr("{{param:select_2}}")},
+			},
+			wantParams: []entity.WorkflowParam{
+				{Name: "input_1", Description: "第 2 步 input 的值（#q）", Default: "iPhone 15"},
+				{Name: "select_2", Description: "第 3 步 select 的值", Default: "128GB"},
+			},
+		},
+		{
+			name: "只引用凭据的值保持原样",
+			actions: []*entity.BrowserAgentAction{
+				action("input", entity.ActionStatusSuccess, ptr("#password"), ptr(" {{secret:password}} ")),
+			},
+			wantSteps: []entity.WorkflowStep{{Action: "input", Selector: ptr("#password"), Value: ptr(" {{secret:password}} ")}},
+		},
+		{
+			name: "凭据与普通文本混合时提取为参数",
+			actions: []*entity.BrowserAgentAction{
+				action("input", entity.ActionStatusSuccess, ptr("#user"), ptr("{{secret:user}}@example.com")),
+			},
+			wantSteps: []entity.WorkflowStep{{Action: "input", Selector: ptr("#user"), Value: ptr("{{param:input_1}}")}},
+			wantParams: []entity.WorkflowParam{
+				{Name: "input_1", Description: "第 1 步 input 的值（#user）", Default: "{{secret:user}}@example.com"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, params := recordWorkflowSteps(tt.actions)
+			if !reflect.DeepEqual(steps, tt.wantSteps) {
+				t.Errorf("steps = %+v, want %+v", steps, tt.wantSteps)
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("params = %+v, want %+v", params, tt.wantParams)
+			}
+		})
+	}
+}
@@ -14,6 +14,16 @@ import (
 	"go.uber.org/zap"
 )
 
+// StatusError 模型接口返回非 200 状态码时的错误，保留状态码供调用方判断是否降级重试
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad response status: %d, response body: %s", e.StatusCode, e.Body)
+}
+
 // ChatRequest 普通非流式请求，返回完整响应体 []byte 或错误
 func (c *AIModelClient) ChatRequest(ctx context.Context, url, token string, reqData ChatRequest) ([]byte, error) {
 	body, err := sonic.Marshal(reqData)
@@ -36,7 +46,8 @@ func (c *AIModelClient) ChatRequest(ctx context.Context, url, token string, reqD
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad response status: %d", resp.StatusCode)
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	return io.ReadAll(resp.Body)
@@ -64,7 +75,8 @@ func (c *AIModelClient) MultiModeChatRequest(ctx context.Context, url, token str
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad response status: %d", resp.StatusCode)
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	return io.ReadAll(resp.Body)
@@ -175,3 +187,124 @@ func EstimateTokens(text string) int {
 
 	return tokens
 }
+
+// ChatStreamRequest 流式请求，思考过程与文本增量实时回调 onDelta，
+// 结束后将工具调用片段拼接完整，返回与 ChatRequest 相同结构的完整响应体
+func (c *AIModelClient) ChatStreamRequest(ctx context.Context, url, token string, reqData ChatRequest, onDelta func(delta string)) ([]byte, error) {
+	reqData.Stream = true
+	if reqData.StreamOptions == nil {
+		reqData.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	body, err := sonic.Marshal(reqData)
+	if err != nil {
+		return nil, err
+	}
+	return c.streamToResponse(ctx, url, token, body, onDelta)
+}
+
+// MultiModeChatStreamRequest 多模态流式请求，回调与返回值同 ChatStreamRequest
+func (c *AIModelClient) MultiModeChatStreamRequest(ctx context.Context, url, token string, reqData MultiModeChatRequest, onDelta func(delta string)) ([]byte, error) {
+	reqData.Stream = true
+	if reqData.StreamOptions == nil {
+		reqData.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	body, err := sonic.Marshal(reqData)
+	if err != nil {
+		return nil, err
+	}
+	return c.streamToResponse(ctx, url, token, body, onDelta)
+}
+
+// streamToResponse 读取 SSE 响应，回调增量内容，并将全部数据块合并为一个非流式响应
+func (c *AIModelClient) streamToResponse(ctx context.Context, url, token string, body []byte, onDelta func(delta string)) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	var (
+		content   strings.Builder
+		toolCalls []ToolCall
+		full      = ChatCompletionResponse{Object: "chat.completion"}
+		reader    = bufio.NewReader(resp.Body)
+	)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		eof := err == io.EOF
+
+		line = bytes.TrimSpace(line)
+		line = bytes.TrimPrefix(line, []byte("data:"))
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && string(line) != "[DONE]" {
+			var chunk ChatCompletionStreamResponse
+			if err = sonic.Unmarshal(line, &chunk); err != nil {
+				zap.L().Error("Failed to parse response", zap.Error(err), zap.String("raw", string(line)))
+				return nil, err
+			}
+
+			full.ID, full.Created, full.Model = chunk.ID, chunk.Created, chunk.Model
+			if chunk.Usage != nil {
+				full.Usage = chunk.Usage
+			}
+			for _, choice := range chunk.Choices {
+				delta := choice.Delta
+				if onDelta != nil && delta.ReasoningContent != "" {
+					onDelta(delta.ReasoningContent)
+				}
+				if delta.Content != "" {
+					content.WriteString(delta.Content)
+					if onDelta != nil {
+						onDelta(delta.Content)
+					}
+				}
+				toolCalls = mergeToolCallDeltas(toolCalls, delta.ToolCalls)
+			}
+		}
+
+		if eof || string(line) == "[DONE]" {
+			break
+		}
+	}
+
+	full.Choices = []ChatCompletionChoice{{
+		Message: ChatCompletionMessage{Role: "assistant", Content: content.String(), ToolCalls: toolCalls},
+	}}
+	return sonic.Marshal(full)
+}
+
+// mergeToolCallDeltas 将工具调用片段按 Index 拼接到已有的工具调用中
+func mergeToolCallDeltas(calls []ToolCall, deltas []ToolCallDelta) []ToolCall {
+	for _, d := range deltas {
+		for len(calls) <= d.Index {
+			calls = append(calls, ToolCall{})
+		}
+		call := &calls[d.Index]
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		if d.Type != "" {
+			call.Type = d.Type
+		}
+		call.Function.Name += d.Function.Name
+		call.Function.Arguments += d.Function.Arguments
+	}
+	return calls
+}
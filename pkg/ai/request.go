@@ -38,8 +38,21 @@ type ResponseFormat struct {
 	Type string `json:"type"`
 }
 
+// Tool 工具定义（OpenAI function calling 格式）
+type Tool struct {
+	Type     string       `json:"type"` // 固定为 "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 工具函数描述，Parameters 为 JSON Schema
+type ToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
 type StreamOptions struct {
-	// 根据具体 API 文档进行结构定义，这里暂为占位
+	IncludeUsage bool `json:"include_usage,omitempty"` // 在最后一个数据块中返回 token 使用情况
 }
 
 type ChatRequest struct {
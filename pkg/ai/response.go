@@ -2,12 +2,13 @@ package ai
 
 // ChatCompletionStreamResponse 表示流式聊天完成API的响应
 type ChatCompletionStreamResponse struct {
-	ID                string         `json:"id"`
-	Object            string         `json:"object"`
-	Created           int64          `json:"created"`
-	Model             string         `json:"model"`
-	SystemFingerprint string         `json:"system_fingerprint,omitempty"`
-	Choices           []StreamChoice `json:"choices"`
+	ID                string               `json:"id"`
+	Object            string               `json:"object"`
+	Created           int64                `json:"created"`
+	Model             string               `json:"model"`
+	SystemFingerprint string               `json:"system_fingerprint,omitempty"`
+	Choices           []StreamChoice       `json:"choices"`
+	Usage             *ChatCompletionUsage `json:"usage,omitempty"` // 部分供应商在最后一个数据块中返回
 }
 
 // StreamChoice 表示流式响应中的一个选择
@@ -20,8 +21,18 @@ type StreamChoice struct {
 
 // DeltaContent 表示流式响应中的增量内容
 type DeltaContent struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role             string          `json:"role,omitempty"`
+	Content          string          `json:"content,omitempty"`
+	ReasoningContent string          `json:"reasoning_content,omitempty"` // 推理模型的思考过程
+	ToolCalls        []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// ToolCallDelta 流式响应中的工具调用片段，同一 Index 的片段按顺序拼接为完整的工具调用
+type ToolCallDelta struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
 }
 
 // isEnd 判断是否是流式响应的最后一个数据块
@@ -54,8 +65,22 @@ type ChatCompletionChoice struct {
 
 // ChatCompletionMessage 消息结构体
 type ChatCompletionMessage struct {
-	Role    string `json:"role"`    // "user" / "assistant" / "system"
-	Content string `json:"content"` // 生成的文本
+	Role      string     `json:"role"`                 // "user" / "assistant" / "system"
+	Content   string     `json:"content"`              // 生成的文本
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"` // 工具调用
+}
+
+// ToolCall 模型发起的一次工具调用
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 工具调用的函数名与参数，Arguments 为 JSON 字符串
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // ChatCompletionUsage token 使用情况
@@ -72,3 +97,11 @@ func (r *ChatCompletionResponse) FirstText() string {
 	}
 	return ""
 }
+
+// FirstToolCall 获取第一条生成结果中的第一个工具调用，不存在时返回 nil
+func (r *ChatCompletionResponse) FirstToolCall() *ToolCall {
+	if len(r.Choices) > 0 && len(r.Choices[0].Message.ToolCalls) > 0 {
+		return &r.Choices[0].Message.ToolCalls[0]
+	}
+	return nil
+}
@@ -51,3 +51,7 @@ func (o *OssClient) UploadKnowledgeBaseFile(c context.Context, filename string,
 func (o *OssClient) UploadChatMessageImage(c context.Context, filename string, reader io.Reader) (string, error) {
 	return o.uploadToFolder(c, "chat_message_image", filename, reader)
 }
+
+func (o *OssClient) UploadBrowserAgentScreenshot(c context.Context, filename string, reader io.Reader) (string, error) {
+	return o.uploadToFolder(c, "browser_agent_screenshot", filename, reader)
+}
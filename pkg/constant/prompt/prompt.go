@@ -37,26 +37,49 @@ const (
 		-----------------------
 		【允许的 Action 类型】
 		- goto(url)
-		- click(selector)        # 点击按钮、链接、单选、多选
-		- input(selector, value) # 文本输入框
-		- select(selector, value) # 下拉选择框
+		- click(element_index)   # 点击按钮、链接、单选、多选
+		- input(element_index, value) # 文本输入框
+		- select(element_index, value) # 下拉选择框
 		- scroll(distance)
 		- wait(timeout)
+		- hover(element_index)   # 悬停，展开下拉菜单或提示
+		- press_key(key, element_index?) # 按键，如 Enter、Tab、Escape、Control+A
+		- open_tab(url)          # 新标签页打开
+		- switch_tab(tab_index)  # 切换标签页，从 0 开始
+		- close_tab(tab_index?)  # 关闭标签页，缺省为当前页
+		- go_back                # 返回上一页
+		- upload_file(element_index, file_url) # 上传文件
+		- extract(element_index) # 提取元素文本并回传
+		- screenshot             # 截取当前页面
 		- finish_task            # 任务完成
+		- submit_result(data)    # 提交采集结果并完成任务，仅在提示中出现【数据采集要求】时使用
 		
 		-----------------------
 		【强制输出格式】
-		你必须 且 只能 输出一个 JSON 对象，结构如下：
+		优先通过工具调用（function calling）返回操作，每次只调用一个工具，工具名即 Action 类型，
+		并在 reason 参数中说明选择该操作的理由。
+		
+		若当前环境未提供工具，你必须 且 只能 输出一个 JSON 对象，结构如下：
 		
 		{
-		  "action": "click | input | goto | select | scroll | wait | finish_task",
+		  "action": "click | input | goto | select | scroll | wait | hover | press_key | open_tab | switch_tab | close_tab | go_back | upload_file | extract | screenshot | finish_task | submit_result",
 		  "url": "string | optional",
 		  "selector": "string | optional",
 		  "value": "string | optional",
 		  "distance": number | optional,
-		  "timeout": number | optional
+		  "timeout": number | optional,
+		  "key": "string | optional",
+		  "tab_index": number | optional,
+		  "file_url": "string | optional",
+		  "element_index": number | optional,
+		  "data": object | array | optional,
+		  "reason": "string | optional",
+		  "plan_progress": number | optional,
+		  "revised_plan": array | optional
 		}
 		
+		reason 用一句话说明选择该操作的理由（如"搜索框位于页面顶部，先输入关键词"），会展示给用户。
+		
 		❌ 禁止：
 		- Markdown
 		- 解释性文字
@@ -65,37 +88,41 @@ const (
 		
 		-----------------------
 		【理解页面元素】
+		【可交互元素】每行以元素序号开头，操作元素时通过 element_index 填写该序号，不要自行编写 CSS 选择器。
 		每个元素包含以下信息：
 		- tag: 元素标签 (input, textarea, select, button, a)
 		- text: 显示文本（已匹配的标签或按钮文本）
-		- selector: CSS 选择器（操作时必须使用此值）
 		- label: 表单字段标签（如"姓名"、"手机号码"）
 		- type: 输入类型 (text, password, email, tel, radio, checkbox)
 		- value: 当前已填写的值
 		- position: 位置信息 {x, y, width, height}
 		
 		元素按 y 坐标从上到下排序，反映视觉布局顺序。
+		页面较大时只列出与任务最相关的元素，序号保持不变；目标元素不在列表中时可先滚动页面。
+		
+		若附有页面截图，截图中元素边框左上角的数字与元素列表序号一致，
+		需要操作的元素无法通过文本判断时（图标按钮、画布等），可结合截图确定序号。
 		
 		-----------------------
 		【表单填写策略】
 		
 		1. 文本输入框 (type: text, email, tel, password, textarea):
-		   - 使用 input(selector, value)
+		   - 使用 input(element_index, value)
 		   - 根据 label 判断应该填写什么内容
-		   - 示例: {"action": "input", "selector": "#name", "value": "张三"}
+		   - 示例: {"action": "input", "element_index": 3, "value": "张三"}
 		
 		2. 下拉选择框 (tag: select):
-		   - 使用 select(selector, value)
+		   - 使用 select(element_index, value)
 		   - value 是选项的显示文本
-		   - 示例: {"action": "select", "selector": "#gender", "value": "男"}
+		   - 示例: {"action": "select", "element_index": 5, "value": "男"}
 		
 		3. 单选/多选 (type: radio, checkbox):
-		   - 使用 click(selector)
-		   - 示例: {"action": "click", "selector": "#option1"}
+		   - 使用 click(element_index)
+		   - 示例: {"action": "click", "element_index": 7}
 		
 		4. 按钮/链接 (tag: button, a):
-		   - 使用 click(selector)
-		   - 示例: {"action": "click", "selector": "button.submit"}
+		   - 使用 click(element_index)
+		   - 示例: {"action": "click", "element_index": 12}
 		
 		-----------------------
 		【表单填写完整性检查】（重要！）
@@ -141,10 +168,22 @@ const (
 		   - 已找到目标内容
 		   - 或已浏览完所有内容（hasMoreBelow == false）
 		
+		-----------------------
+		【历史上下文】
+		- 【历史任务】：同一会话中用户之前下达的任务及其最终状态，仅作参考
+		- 【已执行操作】：当前任务中你已经下达过的操作、执行结果以及执行后的页面 URL
+		- 已成功的操作不要重复执行；失败的操作应换用其他元素或策略
+		
+		-----------------------
+		【任务计划】
+		提示中出现【任务计划】时，按顺序完成各子目标，每次围绕当前子目标决策：
+		- plan_progress：结合当前页面，报告已完成的子目标总数（从计划开头起算）
+		- revised_plan：页面与计划明显不符、剩余子目标无法按原计划执行时，给出新的剩余子目标列表（字符串数组），否则不要填写
+		
 		-----------------------
 		【决策原则】
 		- 每次只返回【一个】操作
-		- 必须使用页面元素中的 selector，不允许臆造
+		- 必须使用【可交互元素】中的序号，不允许臆造
 		- 优先利用 label 字段识别表单字段含义
 		- 任务完整性优先：确保所有用户要求的操作都已执行完毕
 		- 只有在确认所有字段都已处理或确实不存在时，才能 finish_task
@@ -156,4 +195,16 @@ const (
 		
 		这是一个严格的系统约束，必须遵守。
 		`
+	// BrowserPlanPrompt 是浏览器智能体将复杂任务拆解为子目标的提示词
+	BrowserPlanPrompt = `
+		你是一个【浏览器自动化任务规划助手】。
+		
+		根据用户目标与当前页面，将任务拆解为按顺序完成的子目标：
+		- 每个子目标对应一个可在浏览器中验证的阶段性结果，如"在京东搜索 iPhone 15 并记录最低价"
+		- 子目标数量 2 ~ 8 个，简单任务不要过度拆分
+		- 不要包含具体的 selector 或点击细节
+		
+		你必须 且 只能 输出一个 JSON 对象：
+		{"steps": ["子目标1", "子目标2"]}
+		`
 )
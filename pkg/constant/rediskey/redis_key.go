@@ -49,3 +49,13 @@ const (
 const (
 	KeyStats = "KEY_STATS"
 )
+
+// WebSocket 集群相关
+const (
+	WSNodeAlive      = "WS:NODE:ALIVE:"   // nodeID -> 节点存活标记
+	WSNodeAliveTTL   = 30 * time.Second   // 节点心跳间隔为 TTL 的三分之一
+	WSNodeChannel    = "WS:NODE:CHANNEL:" // nodeID -> 节点订阅的消息频道
+	WSPresence       = "WS:PRESENCE:"     // userID -> hash(connID -> 客户端在线信息)
+	WSClientOwner    = "WS:CLIENT:OWNER:" // conversationID:clientID -> 当前有效的连接
+	WSClientOwnerTTL = 24 * time.Hour
+)
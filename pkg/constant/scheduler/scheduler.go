@@ -11,4 +11,10 @@ const (
 
 	// BrowserAgentMessageMaxDuration 消息最大允许执行时间
 	BrowserAgentMessageMaxDuration = 60 * time.Minute
+
+	// BrowserAgentScheduleTickCron 扫描到期定时任务的频率，定时任务的最小粒度为分钟
+	BrowserAgentScheduleTickCron = "0 * * * * *"
+
+	// BrowserAgentTraceCleanupCron 清理过期决策轨迹，每天凌晨执行
+	BrowserAgentTraceCleanupCron = "0 30 3 * * *"
 )
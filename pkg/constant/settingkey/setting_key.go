@@ -0,0 +1,6 @@
+package settingkey
+
+// 浏览器智能体相关
+const (
+	BrowserAgentDefaultModelID = "browser_agent.default_model_id" // 会话未指定模型时使用的默认 AI 模型ID
+)
@@ -18,4 +18,10 @@ var (
 	BrowserAgentConversationTableName = "browser_agent_conversation"
 	BrowserAgentMessageTableName      = "browser_agent_message"
 	BrowserAgentActionTableName       = "browser_agent_action"
+	SystemSettingTableName            = "system_setting"
+	BrowserAgentURLPolicyTableName    = "browser_agent_url_policy"
+	BrowserAgentSecretTableName       = "browser_agent_secret"
+	BrowserAgentWorkflowTableName     = "browser_agent_workflow"
+	BrowserAgentScheduleTableName     = "browser_agent_schedule"
+	BrowserAgentActionTraceTableName  = "browser_agent_action_trace"
 )
@@ -0,0 +1,29 @@
+package errors
+
+import "net/http"
+
+// AccessError 资源不存在或无权访问，Code 作为响应码返回给前端
+type AccessError struct {
+	Code    int
+	Message string
+}
+
+// NewNotFoundError 资源不存在
+func NewNotFoundError(message string) error {
+	return &AccessError{
+		Code:    http.StatusNotFound,
+		Message: message,
+	}
+}
+
+// NewForbiddenError 资源存在但当前用户无权访问
+func NewForbiddenError(message string) error {
+	return &AccessError{
+		Code:    http.StatusForbidden,
+		Message: message,
+	}
+}
+
+func (e *AccessError) Error() string {
+	return e.Message
+}
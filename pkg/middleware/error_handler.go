@@ -90,6 +90,11 @@ func handleDBErrors(c *gin.Context, gormErr *myerrors.DBError) {
 	result.FailWithMessage(gormErr.Message, c)
 }
 
+// handleAccessErrors 处理资源不存在或无权访问错误
+func handleAccessErrors(c *gin.Context, accessErr *myerrors.AccessError) {
+	result.Result(accessErr.Code, map[string]any{}, accessErr.Message, c)
+}
+
 // handleGenericErrors 处理除验证错误和Gorm错误之外的所有其他错误
 func handleGenericErrors(c *gin.Context, err error) {
 	zap.L().Error("request failed",
@@ -113,6 +118,7 @@ func (m *Middlewares) ErrorHandlerMiddleware() gin.HandlerFunc {
 
 		var veErr validator.ValidationErrors
 		var dbErr *myerrors.DBError
+		var accessErr *myerrors.AccessError
 		for _, ginErr := range c.Errors {
 
 			switch {
@@ -122,6 +128,9 @@ func (m *Middlewares) ErrorHandlerMiddleware() gin.HandlerFunc {
 			case errors.As(ginErr.Err, &dbErr):
 				handleDBErrors(c, dbErr)
 				return
+			case errors.As(ginErr.Err, &accessErr):
+				handleAccessErrors(c, accessErr)
+				return
 			default:
 				handleGenericErrors(c, ginErr.Err)
 				return
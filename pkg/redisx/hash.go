@@ -0,0 +1,34 @@
+package redisx
+
+import (
+	"context"
+)
+
+// HSet 设置哈希字段
+func (r *RedisWrapper) HSet(key, field, value string) (err error) {
+	timeout, cancelFunc := context.WithTimeout(context.Background(), r.operationTimeout)
+	defer cancelFunc()
+	return r.client.HSet(timeout, key, field, value).Err()
+}
+
+// HDel 删除哈希字段
+func (r *RedisWrapper) HDel(key string, fields ...string) (err error) {
+	timeout, cancelFunc := context.WithTimeout(context.Background(), r.operationTimeout)
+	defer cancelFunc()
+	return r.client.HDel(timeout, key, fields...).Err()
+}
+
+// HGetAll 获取哈希的全部字段，key 不存在时返回空 map
+func (r *RedisWrapper) HGetAll(key string) (val map[string]string, err error) {
+	timeout, cancelFunc := context.WithTimeout(context.Background(), r.operationTimeout)
+	defer cancelFunc()
+	return r.client.HGetAll(timeout, key).Result()
+}
+
+// Exists 判断键是否存在
+func (r *RedisWrapper) Exists(key string) (bool, error) {
+	timeout, cancelFunc := context.WithTimeout(context.Background(), r.operationTimeout)
+	defer cancelFunc()
+	n, err := r.client.Exists(timeout, key).Result()
+	return n > 0, err
+}
@@ -0,0 +1,21 @@
+package redisx
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Publish 向频道发布消息
+func (r *RedisWrapper) Publish(channel string, message any) (err error) {
+	timeout, cancelFunc := context.WithTimeout(context.Background(), r.operationTimeout)
+	defer cancelFunc()
+	return r.client.Publish(timeout, channel, message).Err()
+}
+
+// Subscribe 订阅频道，返回的 PubSub 需由调用方在不再使用时关闭
+//
+// 订阅是长连接，不受 operationTimeout 限制，生命周期由 ctx 控制
+func (r *RedisWrapper) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return r.client.Subscribe(ctx, channels...)
+}
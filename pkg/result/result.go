@@ -11,9 +11,11 @@ type Response struct {
 }
 
 const (
-	ERROR   = 500
-	NOAUTH  = 401
-	SUCCESS = 200
+	ERROR     = 500
+	NOTFOUND  = 404
+	FORBIDDEN = 403
+	NOAUTH    = 401
+	SUCCESS   = 200
 )
 
 func Result(code int, data any, msg string, c *gin.Context) {
@@ -0,0 +1,147 @@
+package ws
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"strconv"
+)
+
+// 标注使用的 3x5 点阵数字，每行低 3 位从左到右表示像素
+var digitGlyphs = [10][5]uint8{
+	{0b111, 0b101, 0b101, 0b101, 0b111}, // 0
+	{0b010, 0b110, 0b010, 0b010, 0b111}, // 1
+	{0b111, 0b001, 0b111, 0b100, 0b111}, // 2
+	{0b111, 0b001, 0b111, 0b001, 0b111}, // 3
+	{0b101, 0b101, 0b111, 0b001, 0b001}, // 4
+	{0b111, 0b100, 0b111, 0b001, 0b111}, // 5
+	{0b111, 0b100, 0b111, 0b101, 0b111}, // 6
+	{0b111, 0b001, 0b010, 0b010, 0b010}, // 7
+	{0b111, 0b101, 0b111, 0b101, 0b111}, // 8
+	{0b111, 0b101, 0b111, 0b001, 0b111}, // 9
+}
+
+const (
+	glyphScale   = 3 // 点阵放大倍数
+	glyphSpacing = 1 // 数字间距（放大前）
+	labelPadding = 2 // 标签内边距（像素）
+	boxThickness = 2 // 元素边框粗细（像素）
+)
+
+// 标注颜色轮换，相邻元素使用不同颜色便于区分
+var annotateColors = []color.RGBA{
+	{R: 230, G: 25, B: 75, A: 255},
+	{R: 0, G: 130, B: 200, A: 255},
+	{R: 60, G: 180, B: 75, A: 255},
+	{R: 245, G: 130, B: 48, A: 255},
+	{R: 145, G: 30, B: 180, A: 255},
+}
+
+// AnnotateScreenshot 在截图上为带位置信息的元素绘制边框和序号
+//
+// 序号从 1 开始，与提示词中【可交互元素】列表的编号一致；
+// scale 为设备像素比，元素坐标为 CSS 像素，乘以 scale 后对应截图像素；
+// 输出统一编码为 PNG
+func AnnotateScreenshot(data []byte, elements []PageElement, scale float64) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解析截图失败: %w", err)
+	}
+	if scale <= 0 {
+		scale = 1
+	}
+
+	bounds := src.Bounds()
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, src, bounds.Min, draw.Src)
+
+	annotated := 0
+	for i, elem := range elements {
+		if elem.Position == nil || elem.Position.Width <= 0 || elem.Position.Height <= 0 {
+			continue
+		}
+		rect := image.Rect(
+			int(elem.Position.X*scale),
+			int(elem.Position.Y*scale),
+			int((elem.Position.X+elem.Position.Width)*scale),
+			int((elem.Position.Y+elem.Position.Height)*scale),
+		).Add(bounds.Min)
+		if !rect.Overlaps(bounds) {
+			continue
+		}
+
+		c := annotateColors[i%len(annotateColors)]
+		drawBox(canvas, rect.Intersect(bounds), c)
+		drawLabel(canvas, rect.Min, strconv.Itoa(i+1), c)
+		annotated++
+	}
+	if annotated == 0 {
+		return nil, errors.New("没有可标注的元素位置")
+	}
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("编码标注截图失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func drawBox(canvas *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	fill := image.NewUniform(c)
+	t := boxThickness
+	edges := []image.Rectangle{
+		image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+t),
+		image.Rect(rect.Min.X, rect.Max.Y-t, rect.Max.X, rect.Max.Y),
+		image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+t, rect.Max.Y),
+		image.Rect(rect.Max.X-t, rect.Min.Y, rect.Max.X, rect.Max.Y),
+	}
+	for _, edge := range edges {
+		draw.Draw(canvas, edge.Intersect(rect), fill, image.Point{}, draw.Over)
+	}
+}
+
+// drawLabel 在元素左上角绘制带底色的序号标签，超出画布时向内收
+func drawLabel(canvas *image.RGBA, at image.Point, text string, c color.RGBA) {
+	glyphWidth := 3 * glyphScale
+	textWidth := len(text)*glyphWidth + (len(text)-1)*glyphSpacing*glyphScale
+	textHeight := 5 * glyphScale
+
+	label := image.Rect(0, 0, textWidth+2*labelPadding, textHeight+2*labelPadding).Add(at)
+	bounds := canvas.Bounds()
+	if label.Max.X > bounds.Max.X {
+		label = label.Sub(image.Pt(label.Max.X-bounds.Max.X, 0))
+	}
+	if label.Max.Y > bounds.Max.Y {
+		label = label.Sub(image.Pt(0, label.Max.Y-bounds.Max.Y))
+	}
+	if label.Min.X < bounds.Min.X {
+		label = label.Add(image.Pt(bounds.Min.X-label.Min.X, 0))
+	}
+	if label.Min.Y < bounds.Min.Y {
+		label = label.Add(image.Pt(0, bounds.Min.Y-label.Min.Y))
+	}
+	draw.Draw(canvas, label, image.NewUniform(c), image.Point{}, draw.Src)
+
+	white := image.NewUniform(color.White)
+	x := label.Min.X + labelPadding
+	y := label.Min.Y + labelPadding
+	for _, ch := range text {
+		glyph := digitGlyphs[ch-'0']
+		for row := range 5 {
+			for col := range 3 {
+				if glyph[row]&(1<<(2-col)) == 0 {
+					continue
+				}
+				px := image.Rect(0, 0, glyphScale, glyphScale).
+					Add(image.Pt(x+col*glyphScale, y+row*glyphScale))
+				draw.Draw(canvas, px, white, image.Point{}, draw.Src)
+			}
+		}
+		x += glyphWidth + glyphSpacing*glyphScale
+	}
+}
@@ -2,6 +2,8 @@ package ws
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -13,23 +15,72 @@ const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 65536
+	maxMessageSize = 8 << 20 // 执行结果可能携带截图，放宽单条消息上限
 )
 
 type BrowserAgentService interface {
-	HandleTask(ctx context.Context, messageID int64, pageState *PageState) (*Action, error)
-	HandleResult(ctx context.Context, msg *ClientMessage) (*Action, bool, error)
+	HandleTask(ctx context.Context, userID, messageID int64, pageState *PageState) (*Action, error)
+	HandleResult(ctx context.Context, userID int64, msg *ClientMessage) (*Action, bool, error)
+	HandleConfirm(ctx context.Context, userID int64, msg *ClientMessage) (*Action, bool, error)
+	CancelMessage(ctx context.Context, userID, messageID int64) error
+	PauseMessage(ctx context.Context, userID, messageID int64) error
+	ResumeMessage(ctx context.Context, userID int64, msg *ClientMessage) (*Action, bool, error)
+}
+
+// 任务已被用户取消或暂停时，Service 返回以下错误，客户端据此停止执行而不是按失败处理
+var (
+	ErrTaskCancelled = errors.New("任务已取消")
+	ErrTaskPaused    = errors.New("任务已暂停")
+)
+
+// ClientInfo 客户端能力元数据，建立连接时由客户端通过查询参数上报
+type ClientInfo struct {
+	ClientID       string    `json:"client_id"`       // 客户端实例标识，同一浏览器重连时保持不变
+	BrowserType    string    `json:"browser_type"`    // 浏览器类型，如 chrome、edge
+	BrowserVersion string    `json:"browser_version"` // 浏览器版本
+	Capabilities   []string  `json:"capabilities"`    // 客户端支持的可选能力，如 screenshot、upload_file
+	ConnectedAt    time.Time `json:"connected_at"`
 }
 
 type Client struct {
 	Hub            *Hub
 	Conn           *websocket.Conn
+	ConnID         string // 连接标识，注册时由 Hub 生成
 	ConversationID int64
 	UserID         int64
+	Info           ClientInfo
 	Send           chan []byte
 	Service        BrowserAgentService
 	Ctx            context.Context
 	Cancel         context.CancelFunc
+
+	// activeMessageID 客户端正在执行的任务ID，0 表示空闲
+	activeMessageID atomic.Int64
+}
+
+// Assign 标记客户端开始执行任务，messageID 为 0 表示任务结束恢复空闲
+func (c *Client) Assign(messageID int64) {
+	if c.activeMessageID.Swap(messageID) != messageID && c.Hub != nil {
+		c.Hub.notifyState(c)
+		if messageID == 0 {
+			c.Hub.notifyIdle(c)
+		}
+	}
+}
+
+// Release 标记客户端任务结束，恢复空闲
+func (c *Client) Release() {
+	c.Assign(0)
+}
+
+// Busy 客户端是否正在执行任务
+func (c *Client) Busy() bool {
+	return c.activeMessageID.Load() != 0
+}
+
+// ActiveMessageID 客户端正在执行的任务ID，空闲时为 0
+func (c *Client) ActiveMessageID() int64 {
+	return c.activeMessageID.Load()
 }
 
 func (c *Client) ReadPump() {
@@ -63,6 +114,14 @@ func (c *Client) ReadPump() {
 			c.handleTask(&clientMsg)
 		case "result":
 			c.handleResult(&clientMsg)
+		case "confirm":
+			c.handleConfirm(&clientMsg)
+		case "cancel":
+			c.handleCancel(&clientMsg)
+		case "pause":
+			c.handlePause(&clientMsg)
+		case "resume":
+			c.handleResume(&clientMsg)
 		default:
 			c.sendError("未知的消息类型")
 		}
@@ -103,39 +162,127 @@ func (c *Client) Close() {
 }
 
 func (c *Client) handleTask(msg *ClientMessage) {
-	action, err := c.Service.HandleTask(c.Ctx, msg.MessageID, msg.PageState)
+	c.Assign(msg.MessageID)
+	action, err := c.Service.HandleTask(c.eventCtx(), c.UserID, msg.MessageID, msg.PageState)
 	if err != nil {
-		c.sendError(err.Error())
+		c.fail(msg.MessageID, err)
 		return
 	}
 	c.sendAction(action)
 }
 
 func (c *Client) handleResult(msg *ClientMessage) {
-	action, finished, err := c.Service.HandleResult(c.Ctx, msg)
+	action, finished, err := c.Service.HandleResult(c.eventCtx(), c.UserID, msg)
 	if err != nil {
+		c.fail(msg.MessageID, err)
+		return
+	}
+	if finished {
+		c.Release()
+		c.sendFinish("任务已完成")
+		return
+	}
+	c.sendAction(action)
+}
+
+// handleConfirm 处理用户对敏感操作的确认（批准 / 拒绝 / 修改）
+func (c *Client) handleConfirm(msg *ClientMessage) {
+	action, finished, err := c.Service.HandleConfirm(c.eventCtx(), c.UserID, msg)
+	if err != nil {
+		// 确认消息校验失败（如修改后的操作不合法）时任务仍在等待确认，不释放客户端
+		if errors.Is(err, ErrTaskCancelled) || errors.Is(err, ErrTaskPaused) {
+			c.fail(c.ActiveMessageID(), err)
+			return
+		}
 		c.sendError(err.Error())
 		return
 	}
 	if finished {
+		c.Release()
 		c.sendFinish("任务已完成")
 		return
 	}
 	c.sendAction(action)
 }
 
+// handleCancel 取消任务，客户端应立即停止执行
+func (c *Client) handleCancel(msg *ClientMessage) {
+	if err := c.Service.CancelMessage(c.Ctx, c.UserID, msg.MessageID); err != nil {
+		c.sendError(err.Error())
+		return
+	}
+	c.fail(msg.MessageID, ErrTaskCancelled)
+}
+
+// handlePause 暂停任务，客户端正在执行的操作完成后服务端不再下发后续操作
+func (c *Client) handlePause(msg *ClientMessage) {
+	if err := c.Service.PauseMessage(c.Ctx, c.UserID, msg.MessageID); err != nil {
+		c.sendError(err.Error())
+		return
+	}
+	c.fail(msg.MessageID, ErrTaskPaused)
+}
+
+// handleResume 恢复已暂停的任务，客户端需携带当前页面状态，服务端据此规划下一步
+func (c *Client) handleResume(msg *ClientMessage) {
+	c.Assign(msg.MessageID)
+	action, finished, err := c.Service.ResumeMessage(c.eventCtx(), c.UserID, msg)
+	if err != nil {
+		c.fail(msg.MessageID, err)
+		return
+	}
+	if finished {
+		c.Release()
+		c.sendFinish("任务已完成")
+		return
+	}
+	c.sendAction(action)
+}
+
+// fail 任务无法继续时释放客户端；任务被取消或暂停时下发对应状态消息而不是错误
+func (c *Client) fail(messageID int64, err error) {
+	if c.ActiveMessageID() == messageID {
+		c.Release()
+	}
+	switch {
+	case errors.Is(err, ErrTaskCancelled):
+		c.sendState(ServerMessageCancelled, messageID, err.Error())
+	case errors.Is(err, ErrTaskPaused):
+		c.sendState(ServerMessagePaused, messageID, err.Error())
+	default:
+		c.sendError(err.Error())
+	}
+}
+
+// sendAction 下发操作，敏感操作改为下发 confirm 消息，等待用户确认
 func (c *Client) sendAction(action *Action) {
+	if action.ConfirmReason != "" {
+		c.sendConfirm(action)
+		return
+	}
 	msg := ServerMessage{Type: "action", Action: action}
 	data, _ := sonic.Marshal(msg)
 	c.Send <- data
 }
 
+func (c *Client) sendConfirm(action *Action) {
+	msg := ServerMessage{Type: "confirm", Action: action, Message: action.ConfirmReason}
+	data, _ := sonic.Marshal(msg)
+	c.Send <- data
+}
+
 func (c *Client) sendFinish(message string) {
 	msg := ServerMessage{Type: "finish", Message: message}
 	data, _ := sonic.Marshal(msg)
 	c.Send <- data
 }
 
+func (c *Client) sendState(msgType string, messageID int64, message string) {
+	msg := ServerMessage{Type: msgType, MessageID: messageID, Message: message}
+	data, _ := sonic.Marshal(msg)
+	c.Send <- data
+}
+
 func (c *Client) sendError(message string) {
 	msg := ServerMessage{Type: "error", Message: message}
 	data, _ := sonic.Marshal(msg)
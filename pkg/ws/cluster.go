@@ -0,0 +1,239 @@
+package ws
+
+import (
+	"Art-Design-Backend/pkg/constant/rediskey"
+	"Art-Design-Backend/pkg/redisx"
+	"Art-Design-Backend/pkg/utils"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+)
+
+// Presence 客户端在线信息
+//
+// 集群模式下记录在 Redis 中，任一节点都能查询到连接在其他节点上的客户端
+type Presence struct {
+	NodeID          string     `json:"node_id"` // 客户端所在节点
+	ConnID          string     `json:"conn_id"` // 连接标识，每次建立连接时生成
+	UserID          int64      `json:"user_id,string"`
+	ConversationID  int64      `json:"conversation_id,string"`
+	Info            ClientInfo `json:"info"`
+	ActiveMessageID int64      `json:"active_message_id,string"` // 正在执行的任务ID，0 表示空闲
+}
+
+// Busy 客户端是否正在执行任务
+func (p *Presence) Busy() bool {
+	return p.ActiveMessageID != 0
+}
+
+// 节点间转发的消息类型
+const (
+	envelopeSend = "send" // 将 ServerMessage 投递给本节点上的连接
+	envelopeKick = "kick" // 关闭本节点上已被其他节点新连接替换的旧连接
+)
+
+// envelope 通过 Redis 频道在节点间转发的消息
+type envelope struct {
+	Kind    string         `json:"kind"`
+	ConnID  string         `json:"conn_id"`
+	Message *ServerMessage `json:"message,omitempty"`
+}
+
+// claimClientScript 将会话中 ClientID 对应的有效连接改为新连接，返回被替换的旧连接（没有时返回空字符串）
+var claimClientScript = `
+	local old = redis.call('GET', KEYS[1])
+	redis.call('SET', KEYS[1], ARGV[1], 'EX', ARGV[2])
+	return old or ''
+`
+
+// releaseClientScript 仅当有效连接仍是自己时删除，避免误删其他节点的新连接
+var releaseClientScript = `
+	if redis.call('GET', KEYS[1]) == ARGV[1] then
+		return redis.call('DEL', KEYS[1])
+	end
+	return 0
+`
+
+// Cluster 基于 Redis 的 WebSocket 集群支持
+//
+// 设计说明：
+//   - 在线信息：每个连接以 connID 为字段写入 WSPresence:<userID> 哈希，断开时删除
+//   - 节点存活：节点定时续期 WSNodeAlive:<nodeID>，读取在线信息时过滤已失联节点的连接，
+//     因此节点异常退出后残留的在线信息会在 TTL 过期后失效并被清理
+//   - 消息转发：每个节点订阅自己的频道 WSNodeChannel:<nodeID>，
+//     向其他节点上的连接发送消息时发布到该节点的频道
+//   - 重复连接：WSClientOwner:<conversationID>:<clientID> 记录当前有效的连接，
+//     以最后注册的连接为准，被替换的旧连接无论在哪个节点都会被关闭
+type Cluster struct {
+	redis  *redisx.RedisWrapper
+	nodeID string
+}
+
+func NewCluster(redis *redisx.RedisWrapper) *Cluster {
+	return &Cluster{
+		redis:  redis,
+		nodeID: utils.CompactUUID(),
+	}
+}
+
+// NodeID 当前节点标识
+func (c *Cluster) NodeID() string {
+	return c.nodeID
+}
+
+func presenceKey(userID int64) string {
+	return fmt.Sprintf("%s%d", rediskey.WSPresence, userID)
+}
+
+func ownerKey(client *Client) string {
+	return fmt.Sprintf("%s%d:%s", rediskey.WSClientOwner, client.ConversationID, client.Info.ClientID)
+}
+
+// ownerValue 有效连接的值，格式为 nodeID/connID
+func ownerValue(nodeID, connID string) string {
+	return nodeID + "/" + connID
+}
+
+// register 写入在线信息并登记为会话中该 ClientID 的有效连接，
+// 被替换的旧连接在其他节点上时通知该节点关闭
+func (c *Cluster) register(client *Client) {
+	c.savePresence(client)
+
+	if client.Info.ClientID == "" {
+		return
+	}
+	ttl := int64(rediskey.WSClientOwnerTTL / time.Second)
+	old, err := c.redis.Eval(claimClientScript, []string{ownerKey(client)}, ownerValue(c.nodeID, client.ConnID), ttl)
+	if err != nil {
+		zap.L().Error("登记WebSocket有效连接失败", zap.String("connID", client.ConnID), zap.Error(err))
+		return
+	}
+
+	oldValue, _ := old.(string)
+	nodeID, connID, ok := strings.Cut(oldValue, "/")
+	if !ok || nodeID == c.nodeID {
+		// 同一节点上的旧连接已由 Hub 在注册时关闭
+		return
+	}
+	c.publish(nodeID, &envelope{Kind: envelopeKick, ConnID: connID})
+}
+
+// unregister 删除在线信息，并在自己仍是有效连接时解除登记
+func (c *Cluster) unregister(client *Client) {
+	if err := c.redis.HDel(presenceKey(client.UserID), client.ConnID); err != nil {
+		zap.L().Error("删除WebSocket在线信息失败", zap.String("connID", client.ConnID), zap.Error(err))
+	}
+	if client.Info.ClientID == "" {
+		return
+	}
+	if _, err := c.redis.Eval(releaseClientScript, []string{ownerKey(client)}, ownerValue(c.nodeID, client.ConnID)); err != nil {
+		zap.L().Error("解除WebSocket有效连接失败", zap.String("connID", client.ConnID), zap.Error(err))
+	}
+}
+
+// savePresence 写入或更新连接的在线信息
+func (c *Cluster) savePresence(client *Client) {
+	data, err := sonic.MarshalString(c.presenceOf(client))
+	if err != nil {
+		return
+	}
+	if err = c.redis.HSet(presenceKey(client.UserID), client.ConnID, data); err != nil {
+		zap.L().Error("写入WebSocket在线信息失败", zap.String("connID", client.ConnID), zap.Error(err))
+	}
+}
+
+func (c *Cluster) presenceOf(client *Client) *Presence {
+	return &Presence{
+		NodeID:          c.nodeID,
+		ConnID:          client.ConnID,
+		UserID:          client.UserID,
+		ConversationID:  client.ConversationID,
+		Info:            client.Info,
+		ActiveMessageID: client.ActiveMessageID(),
+	}
+}
+
+// presences 查询用户在所有节点上的在线连接，顺带清理已失联节点残留的在线信息
+func (c *Cluster) presences(userID int64) ([]*Presence, error) {
+	key := presenceKey(userID)
+	fields, err := c.redis.HGetAll(key)
+	if err != nil {
+		return nil, err
+	}
+
+	alive := map[string]bool{c.nodeID: true}
+	presences := make([]*Presence, 0, len(fields))
+	for connID, data := range fields {
+		var p Presence
+		if err = sonic.UnmarshalString(data, &p); err != nil {
+			_ = c.redis.HDel(key, connID)
+			continue
+		}
+
+		nodeAlive, checked := alive[p.NodeID]
+		if !checked {
+			if nodeAlive, err = c.redis.Exists(rediskey.WSNodeAlive + p.NodeID); err != nil {
+				return nil, err
+			}
+			alive[p.NodeID] = nodeAlive
+		}
+		if !nodeAlive {
+			_ = c.redis.HDel(key, connID)
+			continue
+		}
+
+		presences = append(presences, &p)
+	}
+	return presences, nil
+}
+
+// forward 将消息转发给其他节点上的连接，只保证成功发布，不保证对方节点送达
+func (c *Cluster) forward(p *Presence, msg *ServerMessage) bool {
+	return c.publish(p.NodeID, &envelope{Kind: envelopeSend, ConnID: p.ConnID, Message: msg})
+}
+
+func (c *Cluster) publish(nodeID string, env *envelope) bool {
+	data, err := sonic.MarshalString(env)
+	if err != nil {
+		return false
+	}
+	if err = c.redis.Publish(rediskey.WSNodeChannel+nodeID, data); err != nil {
+		zap.L().Error("转发WebSocket消息失败", zap.String("nodeID", nodeID), zap.String("kind", env.Kind), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// heartbeat 续期节点存活标记，并重写本节点连接的在线信息，
+// 避免 Redis 重启或误删后其他节点查不到本节点的连接；由 Hub 事件循环定时调用
+func (c *Cluster) heartbeat(clients []*Client) {
+	if err := c.redis.Set(rediskey.WSNodeAlive+c.nodeID, "1", rediskey.WSNodeAliveTTL); err != nil {
+		zap.L().Error("续期WebSocket节点存活标记失败", zap.String("nodeID", c.nodeID), zap.Error(err))
+	}
+	for _, client := range clients {
+		c.savePresence(client)
+	}
+}
+
+// subscribe 订阅本节点频道，处理其他节点转发来的消息；订阅断开后自动重连
+func (c *Cluster) subscribe(h *Hub) {
+	for {
+		pubsub := c.redis.Subscribe(context.Background(), rediskey.WSNodeChannel+c.nodeID)
+		for msg := range pubsub.Channel() {
+			var env envelope
+			if err := sonic.UnmarshalString(msg.Payload, &env); err != nil {
+				zap.L().Error("解析WebSocket转发消息失败", zap.Error(err))
+				continue
+			}
+			h.handleEnvelope(&env)
+		}
+		_ = pubsub.Close()
+
+		zap.L().Warn("WebSocket节点频道订阅断开，稍后重试", zap.String("nodeID", c.nodeID))
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/bytedance/sonic"
+)
+
+// 任务执行过程中下发的进度事件类型，仅用于界面展示，客户端无需响应
+const (
+	ServerMessageThinking   = "thinking"   // 模型推理过程的增量文本
+	ServerMessagePlanning   = "planning"   // 开始规划下一步操作
+	ServerMessageValidating = "validating" // 校验模型给出的操作
+	ServerMessageRetrying   = "retrying"   // 操作不可用，重新规划
+	ServerMessagePlan       = "plan"       // 任务计划生成、推进或修订，data 为完整的子目标列表
+)
+
+// EventSink 接收进度事件的回调
+type EventSink func(msg *ServerMessage)
+
+type eventSinkKey struct{}
+
+// WithEventSink 在上下文中附加进度事件回调，Service 通过 Emit 发送事件而无需感知连接
+func WithEventSink(ctx context.Context, sink EventSink) context.Context {
+	return context.WithValue(ctx, eventSinkKey{}, sink)
+}
+
+// Emit 发送进度事件，上下文中没有回调（如定时任务、REST 调用）时忽略
+func Emit(ctx context.Context, msgType string, messageID int64, text string) {
+	EmitData(ctx, msgType, messageID, text, nil)
+}
+
+// EmitData 发送携带数据的进度事件
+func EmitData(ctx context.Context, msgType string, messageID int64, text string, data any) {
+	if sink, ok := ctx.Value(eventSinkKey{}).(EventSink); ok {
+		sink(&ServerMessage{Type: msgType, MessageID: messageID, Message: text, Data: data})
+	}
+}
+
+// HasEventSink 上下文中是否附加了进度事件回调
+func HasEventSink(ctx context.Context) bool {
+	_, ok := ctx.Value(eventSinkKey{}).(EventSink)
+	return ok
+}
+
+// emit 下发进度事件；发送队列已满时丢弃，进度事件不能阻塞任务执行
+func (c *Client) emit(msg *ServerMessage) {
+	data, _ := sonic.Marshal(msg)
+	select {
+	case c.Send <- data:
+	default:
+	}
+}
+
+// eventCtx 附加进度事件回调的请求上下文
+func (c *Client) eventCtx() context.Context {
+	return WithEventSink(c.Ctx, c.emit)
+}
@@ -1,26 +1,45 @@
 package ws
 
-import "sync"
+import (
+	"Art-Design-Backend/pkg/constant/rediskey"
+	"Art-Design-Backend/pkg/redisx"
+	"Art-Design-Backend/pkg/utils"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+)
 
 // Hub 是 WebSocket 连接的中心管理器（Connection Hub）
 //
 // 设计职责：
 //  1. 统一管理所有 WebSocket Client 的生命周期
-//  2. 保证同一个 ConversationID 同一时间只存在一个连接
-//  3. 处理 Client 的注册与注销（线程安全）
+//  2. 按会话、用户两个维度索引连接，同一用户可同时连接多个浏览器客户端
+//  3. 同一会话中 ClientID 相同的连接只保留最新的一个（客户端重连时替换旧连接）
+//  4. 处理 Client 的注册与注销（线程安全），并支持服务端主动推送消息
+//  5. 集群模式下通过 Redis 共享在线信息并转发消息，任一节点都能向其他节点上的客户端推送
 //
 // 并发模型说明：
-//   - register / unregister 通过 channel 串行化处理
-//   - clients map 通过 RWMutex 保证并发安全
+//   - register / unregister / touch 通过 channel 串行化处理
+//   - 索引 map 通过 RWMutex 保证并发安全
 //   - Run 方法应在单独 goroutine 中长期运行
 type Hub struct {
 
-	// clients 保存当前在线的 WebSocket 客户端
+	// byConversation 按会话索引本节点在线的客户端
 	// key   : ConversationID
-	// value : 对应的 Client 连接
-	clients map[int64]*Client
+	// value : 连接到该会话的 Client 集合
+	byConversation map[int64]map[*Client]struct{}
+
+	// byUser 按用户索引本节点在线的客户端
+	// key   : UserID
+	// value : 该用户的 Client 集合
+	byUser map[int64]map[*Client]struct{}
 
-	// clientsMux 用于保护 clients map 的并发读写
+	// byConn 按连接标识索引本节点在线的客户端，用于处理其他节点转发的消息
+	byConn map[string]*Client
+
+	// clientsMux 用于保护索引 map 的并发读写
 	clientsMux sync.RWMutex
 
 	// register 用于接收新连接的注册请求
@@ -30,21 +49,45 @@ type Hub struct {
 	// unregister 用于接收连接断开的注销请求
 	// Client 主动关闭或异常退出时发送
 	unregister chan *Client
+
+	// touch 用于接收连接状态（是否忙碌）变更，集群模式下同步到 Redis
+	touch chan *Client
+
+	// onRegister 客户端注册成功后的回调（在独立 goroutine 中执行）
+	// 用于向新连接补发离线期间排队的任务，受 clientsMux 保护
+	onRegister func(conversationID, userID int64)
+
+	// onIdle 客户端任务结束恢复空闲后的回调（在独立 goroutine 中执行）
+	// 用于下发排队等待空闲客户端的任务，受 clientsMux 保护
+	onIdle func(conversationID, userID int64)
+
+	// cluster 集群支持，为 nil 时为单节点模式，所有查询与推送只在本节点进行
+	cluster *Cluster
 }
 
-// NewHub 创建一个新的 Hub 实例
+// NewHub 创建一个单节点模式的 Hub 实例
 //
 // 注意：
 //   - 创建后必须调用 go hub.Run() 启动事件循环
 //   - Hub 本身不启动 goroutine，交由调用方控制
 func NewHub() *Hub {
 	return &Hub{
-		clients:    make(map[int64]*Client),
-		register:   make(chan *Client, 256),
-		unregister: make(chan *Client, 256),
+		byConversation: make(map[int64]map[*Client]struct{}),
+		byUser:         make(map[int64]map[*Client]struct{}),
+		byConn:         make(map[string]*Client),
+		register:       make(chan *Client, 256),
+		unregister:     make(chan *Client, 256),
+		touch:          make(chan *Client, 256),
 	}
 }
 
+// NewClusterHub 创建一个基于 Redis 共享在线信息的集群模式 Hub 实例，多副本部署时使用
+func NewClusterHub(redis *redisx.RedisWrapper) *Hub {
+	h := NewHub()
+	h.cluster = NewCluster(redis)
+	return h
+}
+
 // Run 启动 Hub 的事件循环
 //
 // 该方法会阻塞执行，通常应在单独的 goroutine 中启动：
@@ -52,9 +95,20 @@ func NewHub() *Hub {
 //	go hub.Run()
 //
 // 功能说明：
-//   - 监听 register / unregister channel
-//   - 保证对 clients map 的修改是串行且安全的
+//   - 监听 register / unregister / touch channel
+//   - 保证对索引 map 及 Redis 在线信息的修改是串行且安全的
+//   - 集群模式下定时续期节点存活标记，并订阅本节点频道接收其他节点转发的消息
 func (h *Hub) Run() {
+	var heartbeat <-chan time.Time
+	if h.cluster != nil {
+		ticker := time.NewTicker(rediskey.WSNodeAliveTTL / 3)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+
+		h.cluster.heartbeat(nil)
+		go h.cluster.subscribe(h)
+	}
+
 	for {
 		select {
 
@@ -62,36 +116,100 @@ func (h *Hub) Run() {
 		case client := <-h.register:
 			h.clientsMux.Lock()
 
-			// 如果同一个 ConversationID 已存在连接
-			// 则主动关闭旧连接，保证唯一性
-			if old, ok := h.clients[client.ConversationID]; ok {
-				old.Close()
+			// 同一会话中已存在相同 ClientID 的连接（客户端重连）
+			// 则主动关闭并移除旧连接
+			var replaced []*Client
+			for old := range h.byConversation[client.ConversationID] {
+				if old.Info.ClientID != "" && old.Info.ClientID == client.Info.ClientID {
+					h.remove(old)
+					old.Close()
+					replaced = append(replaced, old)
+				}
 			}
 
 			// 注册新连接
-			h.clients[client.ConversationID] = client
+			addIndex(h.byConversation, client.ConversationID, client)
+			addIndex(h.byUser, client.UserID, client)
+			h.byConn[client.ConnID] = client
+			onRegister := h.onRegister
 			h.clientsMux.Unlock()
 
+			// 其他节点上相同 ClientID 的旧连接由集群通知关闭
+			if h.cluster != nil {
+				for _, old := range replaced {
+					h.cluster.unregister(old)
+				}
+				h.cluster.register(client)
+			}
+
+			if onRegister != nil {
+				go onRegister(client.ConversationID, client.UserID)
+			}
+
 		// 处理客户端注销
 		case client := <-h.unregister:
 			h.clientsMux.Lock()
+			h.remove(client)
+			h.clientsMux.Unlock()
 
-			// 仅当当前连接与传入的 client 一致时才删除
-			// 防止误删已被新连接替换的 Client
-			if c, ok := h.clients[client.ConversationID]; ok && c == client {
-				delete(h.clients, client.ConversationID)
+			if h.cluster != nil {
+				h.cluster.unregister(client)
 			}
 
-			h.clientsMux.Unlock()
+		// 同步连接状态，已注销的连接忽略，避免写回过期的在线信息
+		case client := <-h.touch:
+			h.clientsMux.RLock()
+			registered := h.byConn[client.ConnID] == client
+			h.clientsMux.RUnlock()
+
+			if registered {
+				h.cluster.savePresence(client)
+			}
+
+		case <-heartbeat:
+			h.cluster.heartbeat(h.localClients())
 		}
 	}
 }
 
+// remove 从索引中移除连接，调用方需持有写锁
+func (h *Hub) remove(client *Client) {
+	removeIndex(h.byConversation, client.ConversationID, client)
+	removeIndex(h.byUser, client.UserID, client)
+	if h.byConn[client.ConnID] == client {
+		delete(h.byConn, client.ConnID)
+	}
+}
+
+func addIndex(index map[int64]map[*Client]struct{}, key int64, client *Client) {
+	set, ok := index[key]
+	if !ok {
+		set = make(map[*Client]struct{})
+		index[key] = set
+	}
+	set[client] = struct{}{}
+}
+
+func removeIndex(index map[int64]map[*Client]struct{}, key int64, client *Client) {
+	set, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(set, client)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
+
 // Register 向 Hub 注册一个新的 WebSocket Client
 //
 // 该方法是并发安全的：
 //   - 实际注册逻辑在 Run() 的事件循环中执行
+//   - 未设置 ConnID 时生成一个新的连接标识
 func (h *Hub) Register(client *Client) {
+	if client.ConnID == "" {
+		client.ConnID = utils.CompactUUID()
+	}
 	h.register <- client
 }
 
@@ -104,3 +222,223 @@ func (h *Hub) Register(client *Client) {
 func (h *Hub) Unregister(client *Client) {
 	h.unregister <- client
 }
+
+// notifyState 连接忙碌状态变化后调用，集群模式下同步到 Redis
+func (h *Hub) notifyState(client *Client) {
+	if h.cluster == nil {
+		return
+	}
+	select {
+	case h.touch <- client:
+	default:
+		// 队列已满时放弃本次同步，下次心跳会重写在线信息
+	}
+}
+
+// OnRegister 设置客户端注册成功后的回调，重复设置时覆盖
+func (h *Hub) OnRegister(fn func(conversationID, userID int64)) {
+	h.clientsMux.Lock()
+	h.onRegister = fn
+	h.clientsMux.Unlock()
+}
+
+// OnIdle 设置客户端恢复空闲后的回调，重复设置时覆盖
+func (h *Hub) OnIdle(fn func(conversationID, userID int64)) {
+	h.clientsMux.Lock()
+	h.onIdle = fn
+	h.clientsMux.Unlock()
+}
+
+// notifyIdle 客户端任务结束后调用，触发 onIdle 回调
+func (h *Hub) notifyIdle(client *Client) {
+	h.clientsMux.RLock()
+	onIdle := h.onIdle
+	h.clientsMux.RUnlock()
+	if onIdle != nil {
+		go onIdle(client.ConversationID, client.UserID)
+	}
+}
+
+// ClientsByUser 返回用户在本节点在线的全部客户端
+func (h *Hub) ClientsByUser(userID int64) []*Client {
+	h.clientsMux.RLock()
+	defer h.clientsMux.RUnlock()
+	return collect(h.byUser[userID])
+}
+
+// ClientsByConversation 返回本节点连接到会话的全部客户端
+func (h *Hub) ClientsByConversation(conversationID int64) []*Client {
+	h.clientsMux.RLock()
+	defer h.clientsMux.RUnlock()
+	return collect(h.byConversation[conversationID])
+}
+
+func (h *Hub) localClients() []*Client {
+	h.clientsMux.RLock()
+	defer h.clientsMux.RUnlock()
+	clients := make([]*Client, 0, len(h.byConn))
+	for _, client := range h.byConn {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+func collect(set map[*Client]struct{}) []*Client {
+	clients := make([]*Client, 0, len(set))
+	for client := range set {
+		if client.Ctx.Err() == nil {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// Presences 返回用户在所有节点上在线的客户端
+//
+// 单节点模式下只包含本节点的客户端；集群模式下读取 Redis 失败时退化为本节点的客户端
+func (h *Hub) Presences(userID int64) []*Presence {
+	if h.cluster != nil {
+		presences, err := h.cluster.presences(userID)
+		if err == nil {
+			return presences
+		}
+		zap.L().Error("查询WebSocket在线信息失败，仅返回本节点客户端", zap.Int64("userID", userID), zap.Error(err))
+	}
+
+	clients := h.ClientsByUser(userID)
+	presences := make([]*Presence, len(clients))
+	for i, client := range clients {
+		presences[i] = h.presenceOf(client)
+	}
+	return presences
+}
+
+func (h *Hub) presenceOf(client *Client) *Presence {
+	if h.cluster != nil {
+		return h.cluster.presenceOf(client)
+	}
+	return &Presence{
+		ConnID:          client.ConnID,
+		UserID:          client.UserID,
+		ConversationID:  client.ConversationID,
+		Info:            client.Info,
+		ActiveMessageID: client.ActiveMessageID(),
+	}
+}
+
+// PickClient 为任务挑选一个可用的客户端（可能在其他节点上），没有可用客户端时返回 nil
+//
+// 只在该用户的客户端中挑选，browserType 非空时要求浏览器类型一致；
+// 优先级：空闲优先于忙碌，连接到目标会话的优先于连接到其他会话的，同等条件下取最早连接的
+func (h *Hub) PickClient(userID, conversationID int64, browserType string) *Presence {
+	return h.pickClient(userID, conversationID, browserType, false, nil)
+}
+
+// PickIdleClient 与 PickClient 相同，但只挑选空闲的客户端，并跳过 excludeConnIDs 中的连接
+//
+// 集群模式下忙碌状态经 Redis 同步存在延迟，连续下发多个任务时调用方需排除本轮已下发的连接
+func (h *Hub) PickIdleClient(userID, conversationID int64, browserType string, excludeConnIDs map[string]struct{}) *Presence {
+	return h.pickClient(userID, conversationID, browserType, true, excludeConnIDs)
+}
+
+func (h *Hub) pickClient(userID, conversationID int64, browserType string, idleOnly bool, excludeConnIDs map[string]struct{}) *Presence {
+	var (
+		best      *Presence
+		bestScore = -1
+	)
+	for _, p := range h.Presences(userID) {
+		if browserType != "" && p.Info.BrowserType != "" && p.Info.BrowserType != browserType {
+			continue
+		}
+		if _, excluded := excludeConnIDs[p.ConnID]; excluded || (idleOnly && p.Busy()) {
+			continue
+		}
+		score := 0
+		if !p.Busy() {
+			score += 2
+		}
+		if p.ConversationID == conversationID {
+			score++
+		}
+		if score > bestScore || (score == bestScore && p.Info.ConnectedAt.Before(best.Info.ConnectedAt)) {
+			best, bestScore = p, score
+		}
+	}
+	return best
+}
+
+// Send 向客户端推送服务端主动发起的消息，客户端在其他节点上时经 Redis 转发
+//
+// 返回 false 表示确定未送达（本节点连接已关闭、发送队列已满或转发失败）；
+// 转发到其他节点时只保证成功发布，由对方节点尽力投递
+func (h *Hub) Send(p *Presence, msg *ServerMessage) bool {
+	if p == nil {
+		return false
+	}
+	if h.cluster != nil && p.NodeID != h.cluster.NodeID() {
+		return h.cluster.forward(p, msg)
+	}
+
+	h.clientsMux.RLock()
+	client := h.byConn[p.ConnID]
+	h.clientsMux.RUnlock()
+	return h.SendToClient(client, msg)
+}
+
+// SendToClient 向本节点上的客户端推送服务端主动发起的消息
+//
+// 连接已关闭或发送队列已满时返回 false，由调用方决定是否排队重试；
+// 推送 run_task 成功后客户端标记为忙碌
+func (h *Hub) SendToClient(client *Client, msg *ServerMessage) bool {
+	if client == nil || client.Ctx.Err() != nil {
+		return false
+	}
+
+	data, err := sonic.Marshal(msg)
+	if err != nil {
+		return false
+	}
+
+	select {
+	case client.Send <- data:
+	default:
+		return false
+	}
+
+	if msg.Type == ServerMessageRunTask {
+		client.Assign(msg.MessageID)
+	}
+	return true
+}
+
+// SendToUser 向用户在所有节点上的在线客户端推送消息（如配置更新），返回成功送达或转发的客户端数量
+func (h *Hub) SendToUser(userID int64, msg *ServerMessage) int {
+	sent := 0
+	for _, p := range h.Presences(userID) {
+		if h.Send(p, msg) {
+			sent++
+		}
+	}
+	return sent
+}
+
+// handleEnvelope 处理其他节点转发来的消息，目标连接已不在本节点时忽略
+func (h *Hub) handleEnvelope(env *envelope) {
+	h.clientsMux.RLock()
+	client := h.byConn[env.ConnID]
+	h.clientsMux.RUnlock()
+	if client == nil {
+		return
+	}
+
+	switch env.Kind {
+	case envelopeSend:
+		if env.Message != nil && !h.SendToClient(client, env.Message) {
+			zap.L().Warn("转发的WebSocket消息投递失败", zap.String("connID", env.ConnID), zap.String("type", env.Message.Type))
+		}
+	case envelopeKick:
+		zap.L().Info("连接已被其他节点上的新连接替换，关闭旧连接", zap.String("connID", env.ConnID))
+		h.Unregister(client)
+		client.Close()
+	}
+}
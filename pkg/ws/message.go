@@ -1,13 +1,30 @@
 package ws
 
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
 type Action struct {
-	ActionID int64   `json:"action_id,string"`
-	Action   string  `json:"action"`
-	Selector *string `json:"selector,omitempty"`
-	Value    *string `json:"value,omitempty"`
-	URL      *string `json:"url,omitempty"`
-	Distance *int    `json:"distance,omitempty"`
-	Timeout  *int    `json:"timeout,omitempty"`
+	ActionID     int64   `json:"action_id,string"`
+	Action       string  `json:"action"`
+	Selector     *string `json:"selector,omitempty"`      // click/input/select/hover/extract/upload_file
+	Value        *string `json:"value,omitempty"`         // input/select
+	URL          *string `json:"url,omitempty"`           // goto/open_tab
+	Distance     *int    `json:"distance,omitempty"`      // scroll
+	Timeout      *int    `json:"timeout,omitempty"`       // wait
+	Key          *string `json:"key,omitempty"`           // press_key，如 Enter、Tab、Escape、Control+A
+	TabIndex     *int    `json:"tab_index,omitempty"`     // switch_tab/close_tab，从 0 开始
+	FileURL      *string `json:"file_url,omitempty"`      // upload_file，客户端下载后上传到文件框
+	ElementIndex *int    `json:"element_index,omitempty"` // 元素序号（从 1 开始），服务端解析为 selector
+	Data         any     `json:"data,omitempty"`          // submit_result，符合用户 Schema 的采集结果，仅服务端使用
+	Rationale    string  `json:"reason,omitempty"`        // 模型选择该操作的理由，供界面展示
+
+	PlanProgress *int     `json:"plan_progress,omitempty"` // 模型报告已完成的子目标数，仅服务端使用
+	RevisedPlan  []string `json:"revised_plan,omitempty"`  // 页面偏离计划时模型给出的剩余子目标，仅服务端使用
+
+	ConfirmReason string `json:"-"` // 非空时为敏感操作，需用户确认后才能执行
 }
 
 type Position struct {
@@ -36,10 +53,40 @@ type ScrollInfo struct {
 }
 
 type PageState struct {
-	URL        string        `json:"url"`
-	Title      string        `json:"title"`
-	Elements   []PageElement `json:"elements"`
-	ScrollInfo *ScrollInfo   `json:"scrollInfo,omitempty"`
+	URL              string        `json:"url"`
+	Title            string        `json:"title"`
+	Elements         []PageElement `json:"elements"`
+	ScrollInfo       *ScrollInfo   `json:"scrollInfo,omitempty"`
+	Screenshot       string        `json:"screenshot,omitempty"`       // 可选，当前视口截图（base64，可带 data URL 前缀），存在时使用多模态模型决策
+	DevicePixelRatio float64       `json:"devicePixelRatio,omitempty"` // 截图像素与 CSS 像素之比，缺省为 1
+}
+
+// Fingerprint 计算页面指纹（URL + 可交互元素集合）
+//
+// 元素按内容排序后参与计算，顺序变化不影响结果；
+// 用于判断操作执行前后页面是否发生了实质变化
+func (p *PageState) Fingerprint() string {
+	if p == nil {
+		return ""
+	}
+
+	keys := make([]string, len(p.Elements))
+	for i, elem := range p.Elements {
+		key := elem.Tag + "|" + elem.Selector + "|" + elem.Text
+		if elem.Value != nil {
+			key += "|" + *elem.Value
+		}
+		keys[i] = key
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(p.URL))
+	for _, key := range keys {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(key))
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
 }
 
 type ClientMessage struct {
@@ -51,10 +98,41 @@ type ClientMessage struct {
 	Error         string     `json:"error,omitempty"`
 	ExecutionTime int        `json:"execution_time,omitempty"`
 	Task          string     `json:"task,omitempty"`
+	Extracted     string     `json:"extracted,omitempty"`     // extract 操作提取到的文本
+	Screenshot    string     `json:"screenshot,omitempty"`    // screenshot 操作截取的图片（base64，可带 data URL 前缀）
+	Decision      string     `json:"decision,omitempty"`      // confirm：approve / reject / edit
+	EditedAction  *Action    `json:"edited_action,omitempty"` // confirm：decision 为 edit 时修改后的操作
+	Comment       string     `json:"comment,omitempty"`       // confirm：拒绝或修改的说明，会反馈给模型
 }
 
+// 用户确认决定
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+	DecisionEdit    = "edit"
+)
+
+// 服务端主动推送的消息类型
+const (
+	ServerMessageRunTask      = "run_task"      // 下发新任务，客户端收到后按普通任务发送 task 消息开始执行
+	ServerMessageCancel       = "cancel"        // 取消正在执行的任务
+	ServerMessagePause        = "pause"         // 暂停任务，当前操作完成后不再继续
+	ServerMessageResume       = "resume"        // 请求客户端携带当前页面状态发送 resume 消息，恢复已暂停的任务
+	ServerMessageConfigUpdate = "config_update" // 配置变更通知
+)
+
+// 任务停止执行时下发的状态消息类型
+const (
+	ServerMessageCancelled = "cancelled" // 任务已取消
+	ServerMessagePaused    = "paused"    // 任务已暂停
+)
+
 type ServerMessage struct {
-	Type    string  `json:"type"`
-	Action  *Action `json:"action,omitempty"`
-	Message string  `json:"message,omitempty"`
+	Type           string  `json:"type"`
+	Action         *Action `json:"action,omitempty"`
+	Message        string  `json:"message,omitempty"`
+	MessageID      int64   `json:"message_id,string,omitempty"`      // run_task / cancel：服务端发起或取消的任务ID
+	ConversationID int64   `json:"conversation_id,string,omitempty"` // run_task：任务所属会话，可能与当前连接的会话不同
+	Task           string  `json:"task,omitempty"`                   // run_task：任务描述
+	Data           any     `json:"data,omitempty"`                   // config_update：变更的配置内容；plan：任务计划
 }